@@ -7,39 +7,164 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 
 	"reshape/internal/core"
 	"reshape/internal/formats"
+	"reshape/internal/report"
+)
+
+// Exit codes returned by the CLI.
+const (
+	exitCodeError      = 1
+	exitCodeUsage      = 2
+	exitCodeLossBudget = 3
+	exitCodeValidation = 4
 )
 
 func main() {
-	fromFlag := flag.String("from", "", "input format: json or csv")
-	toFlag := flag.String("to", "", "output format: json or csv")
+	registry := formats.DefaultRegistry()
+	fromFlag := flag.String("from", "", "input format: "+strings.Join(registry.DecoderNames(), ", ")+" (defaults to the input file extension)")
+	toFlag := flag.String("to", "", "output format: "+strings.Join(registry.EncoderNames(), ", "))
 	planPath := flag.String("plan", "", "path to conversion plan JSON")
+	shapePath := flag.String("shape", "", "path to a shape JSON whose field constraints are enforced")
 	inferPlan := flag.Bool("infer-plan", false, "infer a conversion plan")
 	inspect := flag.Bool("inspect", false, "print shape and lossy decisions, then exit")
+	inferConstraints := flag.Bool("infer-constraints", false, "with --inspect, propose field constraints from the data")
+	enumThreshold := flag.Int("enum-threshold", core.DefaultEnumThreshold, "most distinct values of a string field proposed as an enum by --infer-constraints (0 disables enums)")
+	profile := flag.Bool("profile", false, "print per-path statistics of the input, then exit")
+	topK := flag.Int("top-k", core.DefaultProfileTopK, "most frequent values reported per path by --profile")
+	distinctLimit := flag.Int("distinct-limit", core.DefaultProfileDistinctLimit, "distinct values tracked exactly per path by --profile; counts beyond it are estimated")
+	fieldOrder := flag.String("field-order", "", "output field order: sorted or input (overrides the plan field_order)")
+	workers := flag.Int("workers", 1, "goroutines applying per-record steps; output is identical for any count (overrides the plan parallel workers; not allowed with a streaming plan)")
+	fromOptions := formatOptionsFlag{}
+	toOptions := formatOptionsFlag{}
+	flag.Var(fromOptions, "from-option", "input format option as key=value (repeatable)")
+	flag.Var(toOptions, "to-option", "output format option as key=value (repeatable)")
+	warningsFormatFlag := flag.String("warnings-format", "text", "warnings output format: text, json, or ndjson")
+	warningsFile := flag.String("warnings-file", "", "write warnings to this file instead of stderr")
+	failOnWarning := flag.Bool("fail-on-warning", false, "fail when any warning is emitted (overrides the plan loss budget)")
+	forbidStrategies := flag.String("forbid-strategies", "", "comma-separated lossy strategies to forbid (overrides the plan loss budget)")
+	maxAffected := strategyLimitsFlag{}
+	flag.Var(&maxAffected, "max-affected", "strategy=N limit on affected records (repeatable, overrides the plan loss budget)")
 	flag.Parse()
 
+	warningsFormat, err := report.ParseWarningsFormat(*warningsFormatFlag)
+	if err != nil {
+		exitWithUsageError(err)
+	}
+
+	inputPath := ""
+	args := flag.Args()
+	if len(args) > 1 {
+		exitWithUsageError(errors.New("only one input path argument is supported"))
+	}
+	if len(args) == 1 {
+		inputPath = args[0]
+	}
+
+	if *fromFlag == "" && inputPath != "" {
+		if format, ok := registry.LookupExtension(filepath.Ext(inputPath)); ok && format.Decoder != nil {
+			*fromFlag = format.Name
+		}
+	}
 	if *fromFlag == "" {
-		exitWithError(errors.New("--from is required"))
+		exitWithUsageError(errors.New("--from is required"))
+	}
+	if *inspect && *profile {
+		exitWithUsageError(errors.New("--inspect and --profile cannot be used together"))
 	}
-	if !*inspect && *toFlag == "" {
-		exitWithError(errors.New("--to is required unless --inspect is set"))
+	if *topK < 0 {
+		exitWithUsageError(errors.New("--top-k must not be negative"))
+	}
+	if *distinctLimit < 2 {
+		exitWithUsageError(errors.New("--distinct-limit must be at least 2"))
+	}
+	if !*inspect && !*profile && *toFlag == "" {
+		exitWithUsageError(errors.New("--to is required unless --inspect or --profile is set"))
 	}
 	if *inspect && *inferPlan && *toFlag == "" {
-		exitWithError(errors.New("--to is required with --infer-plan"))
+		exitWithUsageError(errors.New("--to is required with --infer-plan"))
+	}
+	if *inferConstraints && !*inspect {
+		exitWithUsageError(errors.New("--infer-constraints requires --inspect"))
+	}
+	if *workers < 1 {
+		exitWithUsageError(errors.New("--workers must be at least 1"))
+	}
+	if *enumThreshold < 0 {
+		exitWithUsageError(errors.New("--enum-threshold must not be negative"))
 	}
 	if *inferPlan && *planPath != "" {
-		exitWithError(errors.New("--plan and --infer-plan cannot be used together"))
+		exitWithUsageError(errors.New("--plan and --infer-plan cannot be used together"))
+	}
+	forbidden, err := parseStrategies(*forbidStrategies)
+	if err != nil {
+		exitWithUsageError(err)
+	}
+	if format, ok := registry.Lookup(*toFlag); ok {
+		*toFlag = format.Name
 	}
 
-	inputPath := ""
-	args := flag.Args()
-	if len(args) > 1 {
-		exitWithError(errors.New("only one input path argument is supported"))
+	plan := core.ConversionPlan{}
+	if *planPath != "" {
+		planBytes, err := os.ReadFile(*planPath)
+		if err != nil {
+			exitWithError(err)
+		}
+		if err := json.Unmarshal(planBytes, &plan); err != nil {
+			exitWithError(err)
+		}
 	}
-	if len(args) == 1 {
-		inputPath = args[0]
+	shapeConstraints := []core.ConstraintRule{}
+	if *shapePath != "" {
+		shapeBytes, err := os.ReadFile(*shapePath)
+		if err != nil {
+			exitWithError(err)
+		}
+		shape, err := parseShapeFile(shapeBytes)
+		if err != nil {
+			exitWithError(err)
+		}
+		shapeConstraints = core.ConstraintRulesFromShape(shape)
+	}
+	applyFlags := func(plan *core.ConversionPlan) {
+		plan.Constraints = append(plan.Constraints, shapeConstraints...)
+		flag.Visit(func(set *flag.Flag) {
+			switch set.Name {
+			case "fail-on-warning":
+				ensureLossBudget(plan).FailOnWarning = *failOnWarning
+			case "forbid-strategies":
+				ensureLossBudget(plan).ForbiddenStrategies = forbidden
+			case "max-affected":
+				ensureLossBudget(plan).StrategyLimits = maxAffected
+			case "field-order":
+				plan.FieldOrder = core.FieldOrder(*fieldOrder)
+			case "workers":
+				ensureParallel(plan).Workers = *workers
+			}
+		})
+	}
+
+	if plan.Streaming != nil && !*inspect && !*profile {
+		if flagSet("workers") {
+			exitWithUsageError(errors.New("--workers cannot be used with a streaming plan"))
+		}
+		applyFlags(&plan)
+		warnings, err := runStream(registry, streamConfig{
+			from:        *fromFlag,
+			fromOptions: formats.Options(fromOptions),
+			to:          *toFlag,
+			toOptions:   formats.Options(toOptions),
+			inputPath:   inputPath,
+		}, plan)
+		exitOnTransformError(err, warnings, warningsFormat, *warningsFile)
+		if err := writeWarnings(warnings, warningsFormat, *warningsFile); err != nil {
+			exitWithError(err)
+		}
+		return
 	}
 
 	inputBytes, err := readInput(inputPath)
@@ -47,27 +172,33 @@ func main() {
 		exitWithError(err)
 	}
 
-	inputData, err := parseInput(*fromFlag, inputBytes)
+	inputData, err := parseInput(registry, *fromFlag, formats.Options(fromOptions), inputBytes)
 	if err != nil {
 		exitWithError(err)
 	}
 
-	plan := core.ConversionPlan{}
-	if *planPath != "" {
-		planBytes, err := os.ReadFile(*planPath)
+	if *profile {
+		output, err := json.Marshal(core.ProfileRecords(inputData.Values.Records, core.ProfileOptions{TopK: *topK, DistinctLimit: *distinctLimit}))
 		if err != nil {
 			exitWithError(err)
 		}
-		if err := json.Unmarshal(planBytes, &plan); err != nil {
+		if _, err := os.Stdout.Write(output); err != nil {
 			exitWithError(err)
 		}
+		return
 	}
+
 	if *inferPlan {
 		plan = core.InferConversionPlan(inputData, *toFlag)
 	}
+	applyFlags(&plan)
 
 	if *inspect {
-		output, err := inspectOutput(inputData, plan)
+		shape := inputData.Shape
+		if *inferConstraints {
+			shape = core.InferConstraints(shape, inputData.Values.Records, core.ConstraintInferenceOptions{EnumThreshold: *enumThreshold})
+		}
+		output, err := inspectOutput(shape, plan)
 		if err != nil {
 			exitWithError(err)
 		}
@@ -78,23 +209,15 @@ func main() {
 	}
 
 	transformed, warnings, err := core.TransformData(inputData, plan)
-	if err != nil {
-		exitWithError(err)
-	}
+	exitOnTransformError(err, warnings, warningsFormat, *warningsFile)
 
-	outputBytes, err := renderOutput(*toFlag, transformed)
+	outputBytes, err := renderOutput(registry, *toFlag, formats.Options(toOptions), transformed)
 	if err != nil {
 		exitWithError(err)
 	}
 
-	if len(warnings) > 0 {
-		for _, warning := range warnings {
-			message := fmt.Sprintf("warning: %s", warning.Message)
-			if warning.Path != "" {
-				message = fmt.Sprintf("%s (path: %s)", message, warning.Path)
-			}
-			fmt.Fprintln(os.Stderr, message)
-		}
+	if err := writeWarnings(warnings, warningsFormat, *warningsFile); err != nil {
+		exitWithError(err)
 	}
 
 	if _, err := os.Stdout.Write(outputBytes); err != nil {
@@ -102,6 +225,30 @@ func main() {
 	}
 }
 
+// exitOnTransformError exits when a transformation failed. Loss budget and
+// constraint failures write their warnings first and exit with their own
+// codes.
+func exitOnTransformError(err error, warnings []core.Warning, format report.WarningsFormat, path string) {
+	if err == nil {
+		return
+	}
+	var budgetErr *core.LossBudgetError
+	if errors.As(err, &budgetErr) {
+		if err := writeWarnings(warnings, format, path); err != nil {
+			exitWithError(err)
+		}
+		exitWithCode(err, exitCodeLossBudget)
+	}
+	var constraintErr *core.ConstraintError
+	if errors.As(err, &constraintErr) {
+		if err := writeWarnings(warnings, format, path); err != nil {
+			exitWithError(err)
+		}
+		exitWithCode(err, exitCodeValidation)
+	}
+	exitWithError(err)
+}
+
 func readInput(path string) ([]byte, error) {
 	if path == "" {
 		return io.ReadAll(os.Stdin)
@@ -109,41 +256,157 @@ func readInput(path string) ([]byte, error) {
 	return os.ReadFile(path)
 }
 
-func parseInput(format string, input []byte) (core.CanonicalData, error) {
-	switch format {
-	case "json":
-		return formats.ParseJSON(input)
-	case "csv":
-		return formats.ParseCSV(input)
-	default:
-		return core.CanonicalData{}, errors.New("unsupported --from format")
+type formatOptionsFlag map[string]string
+
+func (f formatOptionsFlag) String() string {
+	return fmt.Sprint(map[string]string(f))
+}
+
+func (f formatOptionsFlag) Set(value string) error {
+	key, optionValue, ok := strings.Cut(value, "=")
+	if !ok || strings.TrimSpace(key) == "" {
+		return errors.New("format option must be key=value")
+	}
+	f[strings.TrimSpace(key)] = optionValue
+	return nil
+}
+
+type strategyLimitsFlag []core.StrategyLimit
+
+func (f *strategyLimitsFlag) String() string {
+	return fmt.Sprint([]core.StrategyLimit(*f))
+}
+
+func (f *strategyLimitsFlag) Set(value string) error {
+	strategy, limit, ok := strings.Cut(value, "=")
+	if !ok || strings.TrimSpace(strategy) == "" {
+		return errors.New("max-affected must be strategy=N")
+	}
+	parsed, err := strconv.Atoi(strings.TrimSpace(limit))
+	if err != nil || parsed < 0 {
+		return errors.New("max-affected limit must be a non-negative integer")
+	}
+	parsedStrategy, err := core.ParseStrategy(strings.TrimSpace(strategy))
+	if err != nil {
+		return err
+	}
+	*f = append(*f, core.StrategyLimit{Strategy: parsedStrategy, MaxAffectedRecords: parsed})
+	return nil
+}
+
+func parseStrategies(value string) ([]core.Strategy, error) {
+	strategies := []core.Strategy{}
+	for _, item := range strings.Split(value, ",") {
+		if trimmed := strings.TrimSpace(item); trimmed != "" {
+			strategy, err := core.ParseStrategy(trimmed)
+			if err != nil {
+				return nil, errors.New("--forbid-strategies: " + err.Error())
+			}
+			strategies = append(strategies, strategy)
+		}
+	}
+	return strategies, nil
+}
+
+func ensureLossBudget(plan *core.ConversionPlan) *core.LossBudget {
+	if plan.LossBudget == nil {
+		plan.LossBudget = &core.LossBudget{}
+	}
+	return plan.LossBudget
+}
+
+func ensureParallel(plan *core.ConversionPlan) *core.ParallelOptions {
+	if plan.Parallel == nil {
+		plan.Parallel = &core.ParallelOptions{}
+	}
+	return plan.Parallel
+}
+
+func parseInput(registry *formats.Registry, format string, options formats.Options, input []byte) (core.CanonicalData, error) {
+	if _, ok := registry.Lookup(format); !ok {
+		return core.CanonicalData{}, errors.New("unsupported --from format: " + format)
+	}
+	decoder, err := registry.DecoderWithOptions(format, options)
+	if err != nil {
+		return core.CanonicalData{}, err
 	}
+	return decoder.Decode(input)
 }
 
-func renderOutput(format string, data core.CanonicalData) ([]byte, error) {
-	switch format {
-	case "json":
-		return formats.RenderJSON(data)
-	case "csv":
-		return formats.RenderCSV(data)
-	default:
-		return nil, errors.New("unsupported --to format")
+func renderOutput(registry *formats.Registry, format string, options formats.Options, data core.CanonicalData) ([]byte, error) {
+	if _, ok := registry.Lookup(format); !ok {
+		return nil, errors.New("unsupported --to format: " + format)
+	}
+	encoder, err := registry.EncoderWithOptions(format, options)
+	if err != nil {
+		return nil, err
 	}
+	return encoder.Encode(data)
 }
 
-func inspectOutput(data core.CanonicalData, plan core.ConversionPlan) ([]byte, error) {
+// parseShapeFile reads a DataShape, or the output of --inspect with the
+// shape under a "shape" key.
+func parseShapeFile(input []byte) (core.DataShape, error) {
+	var document struct {
+		core.DataShape
+		Shape *core.DataShape `json:"shape"`
+	}
+	if err := json.Unmarshal(input, &document); err != nil {
+		return core.DataShape{}, err
+	}
+	if document.Shape != nil {
+		return *document.Shape, nil
+	}
+	return document.DataShape, nil
+}
+
+func inspectOutput(shape core.DataShape, plan core.ConversionPlan) ([]byte, error) {
 	normalized := core.NormalizePlan(plan)
 	payload := struct {
 		Shape          core.DataShape       `json:"shape"`
 		LossyDecisions []core.LossyDecision `json:"lossy_decisions,omitempty"`
 	}{
-		Shape:          data.Shape,
+		Shape:          shape,
 		LossyDecisions: normalized.LossyDecisions,
 	}
 	return json.Marshal(payload)
 }
 
+func writeWarnings(warnings []core.Warning, format report.WarningsFormat, path string) error {
+	rendered, err := report.RenderWarnings(warnings, format)
+	if err != nil {
+		return err
+	}
+	if path != "" {
+		return os.WriteFile(path, rendered, 0o644)
+	}
+	if len(warnings) == 0 {
+		return nil
+	}
+	_, err = os.Stderr.Write(rendered)
+	return err
+}
+
 func exitWithError(err error) {
+	exitWithCode(err, exitCodeError)
+}
+
+// flagSet reports whether the named flag was given on the command line.
+func flagSet(name string) bool {
+	set := false
+	flag.Visit(func(visited *flag.Flag) {
+		if visited.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+func exitWithUsageError(err error) {
+	exitWithCode(err, exitCodeUsage)
+}
+
+func exitWithCode(err error, code int) {
 	fmt.Fprintln(os.Stderr, "error:", err.Error())
-	os.Exit(1)
+	os.Exit(code)
 }
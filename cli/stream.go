@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"os"
+
+	"reshape/internal/core"
+	"reshape/internal/formats"
+)
+
+// streamConfig names the formats and input of a streaming conversion.
+type streamConfig struct {
+	from        string
+	fromOptions formats.Options
+	to          string
+	toOptions   formats.Options
+	inputPath   string
+}
+
+// runStream converts the input one record at a time, writing output as
+// records arrive. A two_pass plan reads the input file twice and fails
+// before writing anything when the first pass fails. Other plans that only
+// learn of a failure after the last record, from a loss budget or an
+// error-policy constraint, spool their output to a temporary file and copy
+// it to stdout once the stream finishes cleanly.
+func runStream(registry *formats.Registry, config streamConfig, plan core.ConversionPlan) ([]core.Warning, error) {
+	if _, ok := registry.Lookup(config.from); !ok {
+		return nil, errors.New("unsupported --from format: " + config.from)
+	}
+	if _, ok := registry.Lookup(config.to); !ok {
+		return nil, errors.New("unsupported --to format: " + config.to)
+	}
+	var shape *core.DataShape
+	switch plan.Streaming.Shape {
+	case core.StreamShapeDeclared:
+		shape = plan.Streaming.DeclaredShape
+	case core.StreamShapeTwoPass:
+		if config.inputPath == "" {
+			return nil, errors.New("streaming shape two_pass requires an input file path")
+		}
+		measured, warnings, err := measureShape(registry, config, plan)
+		if err != nil {
+			return warnings, err
+		}
+		shape = &measured
+	}
+	stream, err := core.NewRecordStream(plan, shape)
+	if err != nil {
+		return nil, err
+	}
+
+	input, err := openInput(config.inputPath)
+	if err != nil {
+		return nil, err
+	}
+	defer input.Close()
+	reader, err := registry.RecordReader(config.from, input, config.fromOptions)
+	if err != nil {
+		return nil, err
+	}
+	destination := io.Writer(os.Stdout)
+	var spool *os.File
+	if failsAtFinish(plan) {
+		spool, err = os.CreateTemp("", "reshape-stream-*")
+		if err != nil {
+			return nil, err
+		}
+		defer os.Remove(spool.Name())
+		defer spool.Close()
+		destination = spool
+	}
+	output := bufio.NewWriter(destination)
+	writer, err := registry.RecordWriter(config.to, output, stream.Shape(), config.toOptions)
+	if err != nil {
+		return nil, err
+	}
+	if err := readRecords(reader, func(record core.Record) error {
+		return stream.Process(record, writer.Write)
+	}); err != nil {
+		return nil, err
+	}
+	warnings, err := stream.Finish()
+	if err != nil {
+		return warnings, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	if err := output.Flush(); err != nil {
+		return nil, err
+	}
+	if spool != nil {
+		if _, err := spool.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		if _, err := io.Copy(os.Stdout, spool); err != nil {
+			return nil, err
+		}
+	}
+	return warnings, nil
+}
+
+// failsAtFinish reports whether the plan can fail only once every record
+// was read, after output was written: a loss budget or an error-policy
+// constraint not already checked by a two_pass first pass.
+func failsAtFinish(plan core.ConversionPlan) bool {
+	if plan.Streaming.Shape == core.StreamShapeTwoPass {
+		return false
+	}
+	if plan.LossBudget != nil {
+		return true
+	}
+	for _, rule := range plan.Constraints {
+		if rule.OnViolation == "" || rule.OnViolation == core.ConstraintPolicyError {
+			return true
+		}
+	}
+	return false
+}
+
+// measureShape runs the first pass of a two_pass plan.
+func measureShape(registry *formats.Registry, config streamConfig, plan core.ConversionPlan) (core.DataShape, []core.Warning, error) {
+	pass, err := core.NewShapePass(plan)
+	if err != nil {
+		return core.DataShape{}, nil, err
+	}
+	input, err := openInput(config.inputPath)
+	if err != nil {
+		return core.DataShape{}, nil, err
+	}
+	defer input.Close()
+	reader, err := registry.RecordReader(config.from, input, config.fromOptions)
+	if err != nil {
+		return core.DataShape{}, nil, err
+	}
+	if err := readRecords(reader, pass.Process); err != nil {
+		return core.DataShape{}, nil, err
+	}
+	return pass.Finish(reader.SourceOrder())
+}
+
+func readRecords(reader formats.RecordReader, process func(core.Record) error) error {
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := process(record); err != nil {
+			return err
+		}
+	}
+}
+
+func openInput(path string) (io.ReadCloser, error) {
+	if path == "" {
+		return io.NopCloser(os.Stdin), nil
+	}
+	return os.Open(path)
+}
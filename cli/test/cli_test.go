@@ -2,9 +2,14 @@ package cli_test
 
 import (
 	"bytes"
+	"encoding/json"
+	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 	"testing"
+
+	"reshape/internal/core"
 )
 
 func TestCLIInferPlanJSONToCSV(t *testing.T) {
@@ -36,8 +41,339 @@ func TestCLIInspectShape(t *testing.T) {
 		t.Fatalf("cli error: %v\n%s", err, string(output))
 	}
 
-	expected := `{"shape":{"fields":[{"path":"tags","type":"string","nullable":true,"repeated":true},{"path":"user","type":"object","nullable":false,"repeated":false},{"path":"user.id","type":"number","nullable":false,"repeated":false}]}}`
+	expected := `{"shape":{"fields":[{"path":"tags","type":"string","nullable":true,"repeated":true},{"path":"user","type":"object","nullable":false,"repeated":false},{"path":"user.id","type":"integer","nullable":false,"repeated":false}]}}`
+	if string(output) != expected {
+		t.Fatalf("unexpected output\nexpected: %q\nactual: %q", expected, string(output))
+	}
+}
+
+func TestCLIInfersFromFormatByExtension(t *testing.T) {
+	inputPath := filepath.Join(t.TempDir(), "people.json")
+	if err := os.WriteFile(inputPath, []byte(`[{"name":"Ada"},{"name":"Linus"}]`), 0o600); err != nil {
+		t.Fatalf("write input: %v", err)
+	}
+
+	cmd := exec.Command("go", "run", "./cli", "--to", "csv", inputPath)
+	cmd.Dir = filepath.Join("..", "..")
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("cli error: %v\n%s", err, string(output))
+	}
+
+	expected := "name\nAda\nLinus\n"
+	if string(output) != expected {
+		t.Fatalf("unexpected output\nexpected: %q\nactual: %q", expected, string(output))
+	}
+}
+
+func TestCLIJSONOutputEnvelope(t *testing.T) {
+	cmd := exec.Command("go", "run", "./cli", "--from", "csv", "--to", "json", "--to-option", "envelope=array")
+	cmd.Dir = filepath.Join("..", "..")
+	cmd.Stdin = bytes.NewBufferString("name\nAda\n")
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("cli error: %v\n%s", err, string(output))
+	}
+
+	expected := `[{"name":"Ada"}]`
+	if string(output) != expected {
+		t.Fatalf("unexpected output\nexpected: %q\nactual: %q", expected, string(output))
+	}
+}
+
+func TestCLIWarningsFileJSON(t *testing.T) {
+	warningsPath := filepath.Join(t.TempDir(), "warnings.json")
+	cmd := exec.Command("go", "run", "./cli", "--from", "json", "--to", "csv", "--infer-plan", "--warnings-format", "json", "--warnings-file", warningsPath)
+	cmd.Dir = filepath.Join("..", "..")
+	cmd.Stdin = bytes.NewBufferString(`{"tags":["a","b"]}`)
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("cli error: %v\n%s", err, string(output))
+	}
+	if string(output) != "tags\n\"a,b\"\n" {
+		t.Fatalf("unexpected output: %q", string(output))
+	}
+
+	warnings, err := os.ReadFile(warningsPath)
+	if err != nil {
+		t.Fatalf("read warnings: %v", err)
+	}
+	expected := `[{"code":"join_array","message":"joined array into string","path":"tags","affected_count":1,"record_indices":[0],"samples":[{"record_index":0,"before":["a","b"],"after":"a,b"}]}]` + "\n"
+	if string(warnings) != expected {
+		t.Fatalf("unexpected warnings\nexpected: %q\nactual: %q", expected, string(warnings))
+	}
+}
+
+func TestCLIUsageErrorExitCode(t *testing.T) {
+	cmd := exec.Command("go", "run", "./cli", "--to", "csv")
+	cmd.Dir = filepath.Join("..", "..")
+	cmd.Stdin = bytes.NewBufferString(`{}`)
+
+	output, err := cmd.CombinedOutput()
+	exitErr, ok := err.(*exec.ExitError)
+	if !ok {
+		t.Fatalf("expected exit error, got %v\n%s", err, string(output))
+	}
+	if exitErr.ExitCode() != 1 {
+		t.Fatalf("expected go run to report failure, got %d", exitErr.ExitCode())
+	}
+	if !strings.Contains(string(output), "--from is required") || !strings.Contains(string(output), "exit status 2") {
+		t.Fatalf("unexpected output: %s", string(output))
+	}
+}
+
+func TestCLILossBudgetExitCode(t *testing.T) {
+	cmd := exec.Command("go", "run", "./cli", "--from", "json", "--to", "csv", "--infer-plan", "--max-affected", "join_array=0")
+	cmd.Dir = filepath.Join("..", "..")
+	cmd.Stdin = bytes.NewBufferString(`{"tags":["a","b"]}`)
+
+	output, err := cmd.CombinedOutput()
+	if err == nil {
+		t.Fatalf("expected loss budget failure\n%s", string(output))
+	}
+	if !strings.Contains(string(output), "loss budget exceeded: strategy join_array affected 1 records (max 0)") {
+		t.Fatalf("unexpected output: %s", string(output))
+	}
+	if !strings.Contains(string(output), "warning: joined array into string (path: tags, records: 1)") {
+		t.Fatalf("expected warnings before failure: %s", string(output))
+	}
+	if !strings.Contains(string(output), "exit status 3") {
+		t.Fatalf("expected exit status 3: %s", string(output))
+	}
+}
+
+func TestCLIRejectsUnknownStrategies(t *testing.T) {
+	cases := map[string][]string{
+		"unsupported strategy: joinarray":                       {"--max-affected", "joinarray=0"},
+		"--forbid-strategies: unsupported strategy: join-array": {"--forbid-strategies", "drop_field,join-array"},
+	}
+	for expected, flags := range cases {
+		cmd := exec.Command("go", append([]string{"run", "./cli", "--from", "json", "--to", "csv", "--infer-plan"}, flags...)...)
+		cmd.Dir = filepath.Join("..", "..")
+		cmd.Stdin = bytes.NewBufferString(`{"tags":["a","b"]}`)
+
+		output, err := cmd.CombinedOutput()
+		if err == nil {
+			t.Fatalf("expected usage failure for %v\n%s", flags, string(output))
+		}
+		if !strings.Contains(string(output), expected) || !strings.Contains(string(output), "exit status 2") {
+			t.Fatalf("unexpected output for %v: %s", flags, string(output))
+		}
+	}
+}
+
+func TestCLIFieldOrderInputKeepsCSVColumns(t *testing.T) {
+	cmd := exec.Command("go", "run", "./cli", "--from", "csv", "--to", "csv", "--field-order", "input")
+	cmd.Dir = filepath.Join("..", "..")
+	cmd.Stdin = bytes.NewBufferString("name,id,email\nAda,1,ada@example.com\n")
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("cli error: %v\n%s", err, string(output))
+	}
+
+	expected := "name,id,email\nAda,1,ada@example.com\n"
 	if string(output) != expected {
 		t.Fatalf("unexpected output\nexpected: %q\nactual: %q", expected, string(output))
 	}
 }
+
+func TestCLIInferPlanCSVToJSONKeepsExactNumbers(t *testing.T) {
+	cmd := exec.Command("go", "run", "./cli", "--from", "csv", "--to", "json", "--infer-plan")
+	cmd.Dir = filepath.Join("..", "..")
+	cmd.Stdin = bytes.NewBufferString("id,price\n9007199254740993,0.10\n")
+
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("cli error: %v\n%s", err, string(output))
+	}
+
+	expected := `{"id":9007199254740993,"price":0.10}`
+	if string(output) != expected {
+		t.Fatalf("unexpected output\nexpected: %q\nactual: %q", expected, string(output))
+	}
+}
+
+func TestCLIShapeConstraintsExitCode(t *testing.T) {
+	shapePath := filepath.Join(t.TempDir(), "shape.json")
+	shape := `{"fields":[{"path":"age","type":"integer","nullable":false,"repeated":false,"constraints":{"minimum":0}}]}`
+	if err := os.WriteFile(shapePath, []byte(shape), 0o600); err != nil {
+		t.Fatalf("write shape: %v", err)
+	}
+
+	cmd := exec.Command("go", "run", "./cli", "--from", "json", "--to", "csv", "--shape", shapePath)
+	cmd.Dir = filepath.Join("..", "..")
+	cmd.Stdin = bytes.NewBufferString(`[{"age":30},{"age":-1}]`)
+
+	output, err := cmd.CombinedOutput()
+	if err == nil {
+		t.Fatalf("expected validation failure\n%s", string(output))
+	}
+	if !strings.Contains(string(output), "constraint violations: record 1 path age violates minimum (value: -1)") {
+		t.Fatalf("unexpected output: %s", string(output))
+	}
+	if !strings.Contains(string(output), "warning: value violates constraint (path: age, rule: minimum, records: 1)") {
+		t.Fatalf("expected warnings before failure: %s", string(output))
+	}
+	if !strings.Contains(string(output), "exit status 4") {
+		t.Fatalf("expected exit status 4: %s", string(output))
+	}
+}
+
+func TestCLIInferConstraintsFeedsShape(t *testing.T) {
+	inspect := exec.Command("go", "run", "./cli", "--from", "json", "--inspect", "--infer-constraints", "--enum-threshold", "2")
+	inspect.Dir = filepath.Join("..", "..")
+	inspect.Stdin = bytes.NewBufferString(`[{"age":30,"plan":"free"},{"age":41,"plan":"pro"},{"age":35,"plan":"free"}]`)
+
+	output, err := inspect.Output()
+	if err != nil {
+		t.Fatalf("cli error: %v\n%s", err, string(output))
+	}
+	expected := `{"shape":{"fields":[{"path":"age","type":"integer","nullable":false,"repeated":false,"constraints":{"minimum":30,"maximum":41}},{"path":"plan","type":"string","nullable":false,"repeated":false,"constraints":{"enum":["free","pro"]}}]}}`
+	if string(output) != expected {
+		t.Fatalf("unexpected output\nexpected: %q\nactual: %q", expected, string(output))
+	}
+
+	shapePath := filepath.Join(t.TempDir(), "inspect.json")
+	if err := os.WriteFile(shapePath, output, 0o600); err != nil {
+		t.Fatalf("write shape: %v", err)
+	}
+	convert := exec.Command("go", "run", "./cli", "--from", "json", "--to", "csv", "--shape", shapePath)
+	convert.Dir = filepath.Join("..", "..")
+	convert.Stdin = bytes.NewBufferString(`[{"age":30,"plan":"team"}]`)
+
+	converted, err := convert.CombinedOutput()
+	if err == nil || !strings.Contains(string(converted), "path plan violates enum (value: team)") {
+		t.Fatalf("expected enum violation from inspected shape: %v\n%s", err, string(converted))
+	}
+}
+
+func TestCLIProfile(t *testing.T) {
+	cmd := exec.Command("go", "run", "./cli", "--from", "csv", "--profile", "--top-k", "1")
+	cmd.Dir = filepath.Join("..", "..")
+	cmd.Stdin = bytes.NewBufferString("plan,seats\nfree,1\npro,\nfree,3\n")
+
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("cli error: %v\n%s", err, string(output))
+	}
+
+	var profile core.Profile
+	if err := json.Unmarshal(output, &profile); err != nil {
+		t.Fatalf("decode profile: %v\n%s", err, string(output))
+	}
+	if profile.RecordCount != 3 || len(profile.Fields) != 2 {
+		t.Fatalf("unexpected profile: %s", string(output))
+	}
+	plan := profile.Fields[0]
+	if plan.Path != "plan" || plan.DistinctCount != 2 || len(plan.TopValues) != 1 || plan.TopValues[0] != (core.ValueCount{Value: "free", Count: 2}) {
+		t.Fatalf("unexpected plan profile: %s", string(output))
+	}
+	seats := profile.Fields[1]
+	if seats.Numeric == nil || seats.Numeric.Minimum != "1" || seats.Numeric.Maximum != "3" || seats.Numeric.Mean != 2 {
+		t.Fatalf("unexpected seats profile: %s", string(output))
+	}
+}
+
+func TestCLIStreamingTwoPassMatchesBatch(t *testing.T) {
+	dir := t.TempDir()
+	inputPath := filepath.Join(dir, "input.ndjson")
+	input := "{\"name\":\"Ada\",\"tags\":[\"a\",\"b\"],\"id\":1}\n{\"name\":\"Lin\",\"tags\":[\"c\"],\"id\":2,\"team\":\"core\"}\n"
+	if err := os.WriteFile(inputPath, []byte(input), 0o600); err != nil {
+		t.Fatalf("write input: %v", err)
+	}
+	plan := `{"explode_arrays":["tags"],"field_order":"input","lossy_decisions":[]}`
+	batchPlanPath := filepath.Join(dir, "batch.json")
+	streamPlanPath := filepath.Join(dir, "stream.json")
+	if err := os.WriteFile(batchPlanPath, []byte(plan), 0o600); err != nil {
+		t.Fatalf("write plan: %v", err)
+	}
+	streamPlan := strings.Replace(plan, "{", `{"streaming":{"shape":"two_pass"},`, 1)
+	if err := os.WriteFile(streamPlanPath, []byte(streamPlan), 0o600); err != nil {
+		t.Fatalf("write plan: %v", err)
+	}
+
+	outputs := []string{}
+	for _, planPath := range []string{batchPlanPath, streamPlanPath} {
+		cmd := exec.Command("go", "run", "./cli", "--to", "csv", "--plan", planPath, inputPath)
+		cmd.Dir = filepath.Join("..", "..")
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("cli error: %v\n%s", err, string(output))
+		}
+		outputs = append(outputs, string(output))
+	}
+	expected := "name,tags,id,team\nAda,a,1,\nAda,b,1,\nLin,c,2,core\n"
+	if outputs[0] != expected || outputs[1] != expected {
+		t.Fatalf("unexpected output\nexpected: %q\nbatch: %q\nstream: %q", expected, outputs[0], outputs[1])
+	}
+}
+
+func TestCLIStreamingFromStdin(t *testing.T) {
+	planPath := filepath.Join(t.TempDir(), "plan.json")
+	if err := os.WriteFile(planPath, []byte(`{"streaming":{},"lossy_decisions":[]}`), 0o600); err != nil {
+		t.Fatalf("write plan: %v", err)
+	}
+	cmd := exec.Command("go", "run", "./cli", "--from", "json", "--to", "ndjson", "--plan", planPath)
+	cmd.Dir = filepath.Join("..", "..")
+	cmd.Stdin = bytes.NewBufferString(`[{"b":1,"a":"x"},{"a":"y"}]`)
+
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("cli error: %v\n%s", err, string(output))
+	}
+	expected := "{\"a\":\"x\",\"b\":1}\n{\"a\":\"y\"}\n"
+	if string(output) != expected {
+		t.Fatalf("unexpected output\nexpected: %q\nactual: %q", expected, string(output))
+	}
+}
+
+func TestCLIStreamingWritesNothingWhenBudgetFails(t *testing.T) {
+	planPath := filepath.Join(t.TempDir(), "plan.json")
+	plan := `{"streaming":{},"join_arrays":[{"path":"tags","delimiter":","}],` +
+		`"lossy_decisions":[{"field_path":"tags","reason":"format_limit","strategy":"join_array"}],` +
+		`"loss_budget":{"strategy_limits":[{"strategy":"join_array","max_affected_records":1}]}}`
+	if err := os.WriteFile(planPath, []byte(plan), 0o600); err != nil {
+		t.Fatalf("write plan: %v", err)
+	}
+	records := strings.Repeat(`{"tags":["a","b"],"pad":"`+strings.Repeat("x", 100)+`"},`, 200)
+	cmd := exec.Command("go", "run", "./cli", "--from", "json", "--to", "ndjson", "--plan", planPath)
+	cmd.Dir = filepath.Join("..", "..")
+	cmd.Stdin = bytes.NewBufferString("[" + strings.TrimSuffix(records, ",") + "]")
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	if err := cmd.Run(); err == nil {
+		t.Fatalf("expected loss budget failure")
+	}
+	if stdout.Len() != 0 {
+		t.Fatalf("expected no output before the budget was checked, got %d bytes", stdout.Len())
+	}
+	if !strings.Contains(stderr.String(), "strategy join_array affected 200 records (max 1)") || !strings.Contains(stderr.String(), "exit status 3") {
+		t.Fatalf("unexpected stderr: %s", stderr.String())
+	}
+}
+
+func TestCLIRejectsWorkersWithStreamingPlan(t *testing.T) {
+	planPath := filepath.Join(t.TempDir(), "plan.json")
+	if err := os.WriteFile(planPath, []byte(`{"streaming":{}}`), 0o600); err != nil {
+		t.Fatalf("write plan: %v", err)
+	}
+	cmd := exec.Command("go", "run", "./cli", "--from", "json", "--to", "ndjson", "--plan", planPath, "--workers", "4")
+	cmd.Dir = filepath.Join("..", "..")
+	cmd.Stdin = bytes.NewBufferString(`[{"a":1}]`)
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+
+	if err := cmd.Run(); err == nil {
+		t.Fatalf("expected --workers to be rejected")
+	}
+	if !strings.Contains(stderr.String(), "--workers cannot be used with a streaming plan") || !strings.Contains(stderr.String(), "exit status 2") {
+		t.Fatalf("unexpected stderr: %s", stderr.String())
+	}
+}
@@ -0,0 +1,165 @@
+package core
+
+import (
+	"math"
+	"math/big"
+	"regexp"
+	"sort"
+)
+
+// DefaultEnumThreshold is the cardinality limit used by the CLI when no
+// --enum-threshold is given.
+const DefaultEnumThreshold = 10
+
+// ConstraintInferenceOptions controls which constraints InferConstraints
+// proposes.
+type ConstraintInferenceOptions struct {
+	// EnumThreshold is the most distinct values a string field may have to be
+	// proposed as an enum. Zero or less disables enum inference.
+	EnumThreshold int
+}
+
+// inferredPatterns lists the common string shapes InferConstraints
+// recognizes, most specific first.
+var inferredPatterns = []string{
+	`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`,
+	`^[0-9]{4}-[0-9]{2}-[0-9]{2}$`,
+	`^[0-9]{4}-[0-9]{2}-[0-9]{2}T[0-9]{2}:[0-9]{2}:[0-9]{2}(\.[0-9]+)?(Z|[+-][0-9]{2}:[0-9]{2})$`,
+	`^[^@\s]+@[^@\s]+\.[^@\s]+$`,
+	`^https?://\S+$`,
+	`^[0-9]+$`,
+}
+
+var compiledInferredPatterns = compileInferredPatterns()
+
+func compileInferredPatterns() []*regexp.Regexp {
+	compiled := make([]*regexp.Regexp, len(inferredPatterns))
+	for index, pattern := range inferredPatterns {
+		compiled[index] = regexp.MustCompile(pattern)
+	}
+	return compiled
+}
+
+// InferConstraints returns shape with constraints proposed from the observed
+// values: minimum and maximum for numeric fields, an enum for string fields
+// whose values repeat and have at most EnumThreshold distinct values, and
+// otherwise a pattern when every value has a common shape such as a UUID,
+// ISO date, email address, URL, or digit string. The proposals describe the
+// records as given and always hold for them; object fields, fields with no
+// non-null values, and fields with mixed value types get none. Values are
+// gathered with the traversal ProfileRecords uses, tracking at most
+// EnumThreshold+1 distinct values per path, so memory does not grow with the
+// number of records.
+func InferConstraints(shape DataShape, records []Record, options ConstraintInferenceOptions) DataShape {
+	distinctLimit := options.EnumThreshold + 1
+	if distinctLimit < 2 {
+		distinctLimit = 2
+	}
+	statsByPath := collectRecordStats(records, &ProfileOptions{DistinctLimit: distinctLimit, matchPatterns: true})
+	fields := make([]FieldDefinition, len(shape.Fields))
+	for index, field := range shape.Fields {
+		fields[index] = field
+		stats, exists := statsByPath.stats[field.Path]
+		if !exists || field.Type == LogicalTypeObject {
+			continue
+		}
+		if constraints := stats.inferConstraints(options); constraints != nil {
+			fields[index].Constraints = constraints
+		}
+	}
+	shape.Fields = fields
+	return shape
+}
+
+// inferConstraints proposes constraints from the non-null scalar values of
+// a path. Object items and nested arrays share the path of their array but
+// are not its values, so they do not make the types mixed.
+func (s *fieldStats) inferConstraints(options ConstraintInferenceOptions) *FieldConstraints {
+	texts, numbers := 0, 0
+	for logicalType, count := range s.typeCounts {
+		switch logicalType {
+		case LogicalTypeObject, LogicalTypeArray:
+		case LogicalTypeString:
+			texts += count
+		case LogicalTypeNumber, LogicalTypeDecimal, LogicalTypeInteger:
+			numbers += count
+		default:
+			return nil
+		}
+	}
+	switch {
+	case texts > 0 && numbers == 0:
+		return s.profile.stringConstraints(texts, options)
+	case numbers > 0 && texts == 0:
+		return s.profile.numericConstraints(numbers)
+	default:
+		return nil
+	}
+}
+
+// numericConstraints bounds the count numeric values observed, unless some
+// had no exact value.
+func (p *valueProfile) numericConstraints(count int) *FieldConstraints {
+	if p.numericCount != count {
+		return nil
+	}
+	constraints := &FieldConstraints{}
+	if lower, ok := boundFloat(p.minimum, math.Inf(-1)); ok {
+		constraints.Minimum = &lower
+	}
+	if upper, ok := boundFloat(p.maximum, math.Inf(1)); ok {
+		constraints.Maximum = &upper
+	}
+	if constraints.Minimum == nil && constraints.Maximum == nil {
+		return nil
+	}
+	return constraints
+}
+
+// boundFloat converts exact to the nearest float64 on the side of toward,
+// so that a bound rounded from an exact value still admits that value.
+// Values beyond the float64 range have no bound.
+func boundFloat(exact *big.Rat, toward float64) (float64, bool) {
+	bound, _ := exact.Float64()
+	if math.IsInf(bound, 0) {
+		return 0, false
+	}
+	comparison := new(big.Rat).SetFloat64(bound).Cmp(exact)
+	if (toward < 0 && comparison > 0) || (toward > 0 && comparison < 0) {
+		bound = math.Nextafter(bound, toward)
+	}
+	return bound, !math.IsInf(bound, 0)
+}
+
+// stringConstraints proposes an enum or pattern for count string values,
+// unless some values counted as strings were of another Go type. The value
+// counter holds every distinct value exactly while it has not evicted any.
+func (p *valueProfile) stringConstraints(count int, options ConstraintInferenceOptions) *FieldConstraints {
+	if p.lengthCount != count {
+		return nil
+	}
+	distinct := len(p.top.entries)
+	if options.EnumThreshold > 0 && !p.top.evicted && distinct <= options.EnumThreshold && distinct < count {
+		enum := make([]string, 0, distinct)
+		for text := range p.top.entries {
+			enum = append(enum, text)
+		}
+		sort.Strings(enum)
+		return &FieldConstraints{Enum: enum}
+	}
+	for index := range compiledInferredPatterns {
+		if p.patternMisses&(1<<index) == 0 {
+			return &FieldConstraints{Pattern: inferredPatterns[index]}
+		}
+	}
+	return nil
+}
+
+// matchPatterns marks the inferred patterns text does not match.
+func (p *valueProfile) matchPatterns(text string) {
+	for index, pattern := range compiledInferredPatterns {
+		if p.patternMisses&(1<<index) == 0 && !pattern.MatchString(text) {
+			p.patternMisses |= 1 << index
+		}
+	}
+}
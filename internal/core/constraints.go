@@ -0,0 +1,315 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+)
+
+// Constraint rule names reported in violations and warnings.
+const (
+	ConstraintMinimum = "minimum"
+	ConstraintMaximum = "maximum"
+	ConstraintEnum    = "enum"
+	ConstraintPattern = "pattern"
+)
+
+// ConstraintViolation describes one value that failed a constraint.
+type ConstraintViolation struct {
+	RecordIndex int              `json:"record_index"`
+	Path        string           `json:"path"`
+	Value       any              `json:"value"`
+	Rule        string           `json:"rule"`
+	Policy      ConstraintPolicy `json:"policy"`
+}
+
+// ConstraintError reports the violations of constraints whose policy is
+// error. Violations holds the first constraintErrorLimit of them and Count
+// the total.
+type ConstraintError struct {
+	Violations []ConstraintViolation
+	Count      int
+}
+
+// constraintErrorLimit caps how many violations are kept and listed.
+const constraintErrorLimit = 5
+
+func (e *ConstraintError) Error() string {
+	parts := []string{}
+	for index, violation := range e.Violations {
+		if index == constraintErrorLimit {
+			break
+		}
+		parts = append(parts, fmt.Sprintf("record %d path %s violates %s (value: %s)", violation.RecordIndex, violation.Path, violation.Rule, valueText(violation.Value)))
+	}
+	total := e.Count
+	if total < len(e.Violations) {
+		total = len(e.Violations)
+	}
+	if total > len(parts) {
+		parts = append(parts, fmt.Sprintf("and %d more", total-len(parts)))
+	}
+	return "constraint violations: " + strings.Join(parts, "; ")
+}
+
+// constraintViolations counts the violations of error-policy constraints,
+// keeping only the first constraintErrorLimit so memory stays bounded
+// however many records fail.
+type constraintViolations struct {
+	kept  []ConstraintViolation
+	count int
+}
+
+func (v *constraintViolations) add(violation ConstraintViolation) {
+	v.count++
+	if len(v.kept) < constraintErrorLimit {
+		violation.Value = deepCopyValue(violation.Value)
+		v.kept = append(v.kept, violation)
+	}
+}
+
+// err returns a *ConstraintError when any violation was added, or nil.
+func (v *constraintViolations) err() error {
+	if v.count == 0 {
+		return nil
+	}
+	return &ConstraintError{Violations: v.kept, Count: v.count}
+}
+
+// ConstraintRulesFromShape returns a rule for every shape field that
+// declares constraints.
+func ConstraintRulesFromShape(shape DataShape) []ConstraintRule {
+	rules := []ConstraintRule{}
+	for _, field := range shape.Fields {
+		if field.Constraints == nil {
+			continue
+		}
+		rules = append(rules, ConstraintRule{Path: field.Path, FieldConstraints: *field.Constraints})
+	}
+	return rules
+}
+
+// compiledConstraint is a constraint rule with its pattern compiled.
+type compiledConstraint struct {
+	ConstraintRule
+	path    Path
+	pattern *regexp.Regexp
+}
+
+func compileConstraints(rules []ConstraintRule) ([]compiledConstraint, error) {
+	compiled := make([]compiledConstraint, 0, len(rules))
+	for _, rule := range rules {
+		if rule.Path == "" {
+			return nil, errors.New("constraints path is empty")
+		}
+		switch rule.OnViolation {
+		case "", ConstraintPolicyError, ConstraintPolicyWarn, ConstraintPolicyNull, ConstraintPolicyDropRecord:
+		default:
+			return nil, errors.New("unsupported constraints on_violation: " + string(rule.OnViolation))
+		}
+		if rule.Minimum != nil && rule.Maximum != nil && *rule.Minimum > *rule.Maximum {
+			return nil, errors.New("constraints minimum exceeds maximum for path: " + rule.Path)
+		}
+		path, err := ParsePath(rule.Path)
+		if err != nil {
+			return nil, err
+		}
+		entry := compiledConstraint{ConstraintRule: rule, path: path}
+		if rule.Pattern != "" {
+			pattern, err := regexp.Compile(rule.Pattern)
+			if err != nil {
+				return nil, errors.New("constraints pattern is invalid for path " + rule.Path + ": " + err.Error())
+			}
+			entry.pattern = pattern
+		}
+		compiled = append(compiled, entry)
+	}
+	return compiled, nil
+}
+
+func (c compiledConstraint) policy() ConstraintPolicy {
+	if c.OnViolation == "" {
+		return ConstraintPolicyError
+	}
+	return c.OnViolation
+}
+
+// constraintFailure is one value that failed a constraint.
+type constraintFailure struct {
+	value any
+	rule  string
+}
+
+// failures appends the values in value that fail the constraint. Missing
+// and null values are not checked; array items are checked one by one.
+func (c compiledConstraint) failures(value any, failures []constraintFailure) []constraintFailure {
+	if items, ok := value.([]any); ok {
+		for _, item := range items {
+			failures = c.failures(item, failures)
+		}
+		return failures
+	}
+	for _, rule := range c.violations(value) {
+		failures = append(failures, constraintFailure{value: value, rule: rule})
+	}
+	return failures
+}
+
+// withoutFailures replaces the values in value that fail the constraint
+// with null, item by item in arrays.
+func (c compiledConstraint) withoutFailures(value any) any {
+	if items, ok := value.([]any); ok {
+		for index, item := range items {
+			items[index] = c.withoutFailures(item)
+		}
+		return items
+	}
+	if len(c.violations(value)) > 0 {
+		return nil
+	}
+	return value
+}
+
+// violations returns the names of the constraints a value other than an
+// array fails. Null values are not checked.
+func (c compiledConstraint) violations(value any) []string {
+	if value == nil {
+		return nil
+	}
+	failed := []string{}
+	if c.Minimum != nil || c.Maximum != nil {
+		exact, numeric := exactValue(value)
+		if c.Minimum != nil && (!numeric || exact.Cmp(new(big.Rat).SetFloat64(*c.Minimum)) < 0) {
+			failed = append(failed, ConstraintMinimum)
+		}
+		if c.Maximum != nil && (!numeric || exact.Cmp(new(big.Rat).SetFloat64(*c.Maximum)) > 0) {
+			failed = append(failed, ConstraintMaximum)
+		}
+	}
+	_, isObject := value.(map[string]any)
+	if len(c.Enum) > 0 {
+		allowed := false
+		if !isObject {
+			text := valueText(value)
+			for _, option := range c.Enum {
+				if option == text {
+					allowed = true
+					break
+				}
+			}
+		}
+		if !allowed {
+			failed = append(failed, ConstraintEnum)
+		}
+	}
+	if c.pattern != nil && (isObject || !c.pattern.MatchString(valueText(value))) {
+		failed = append(failed, ConstraintPattern)
+	}
+	return failed
+}
+
+// validateConstraints checks every record against the rules, applying each
+// rule's policy. Error violations are collected across all records and
+// returned together as a *ConstraintError.
+func (s *transformState) validateConstraints(compiled []compiledConstraint) error {
+	errorViolations := &constraintViolations{}
+	kept := make([]Record, 0, len(s.records))
+	for index, record := range s.records {
+		keep, err := s.constrainRecord(compiled, record, index, errorViolations)
+		if err != nil {
+			return err
+		}
+		if keep {
+			kept = append(kept, record)
+		}
+	}
+	if err := errorViolations.err(); err != nil {
+		return err
+	}
+	s.records = kept
+	return nil
+}
+
+// constrainRecord checks one record, adding violations of error-policy
+// rules to errorViolations. It reports whether the record is kept, i.e. no
+// drop_record rule failed.
+func (s *transformState) constrainRecord(compiled []compiledConstraint, record Record, index int, errorViolations *constraintViolations) (bool, error) {
+	keep := true
+	for _, rule := range compiled {
+		values, err := rule.path.Values(record)
+		if err != nil {
+			return false, err
+		}
+		failures := []constraintFailure{}
+		for _, value := range values {
+			failures = rule.failures(value, failures)
+		}
+		if len(failures) == 0 {
+			continue
+		}
+		// Warnings sample the value at the path, or the values of every
+		// match of a wildcard, so a record counts once per failed constraint.
+		before := values[0]
+		if rule.path.wildcard {
+			before = any(values)
+		}
+		policy := rule.policy()
+		after := before
+		switch policy {
+		case ConstraintPolicyNull:
+			before = deepCopyValue(before)
+			err := rule.path.update(record, func(value any) (any, error) {
+				return rule.withoutFailures(value), nil
+			})
+			if err != nil {
+				return false, err
+			}
+			if after, err = rule.sample(record); err != nil {
+				return false, err
+			}
+		case ConstraintPolicyDropRecord:
+			keep = false
+			after = nil
+		}
+		seen := map[string]struct{}{}
+		for _, failure := range failures {
+			if policy == ConstraintPolicyError {
+				errorViolations.add(ConstraintViolation{
+					RecordIndex: index,
+					Path:        rule.Path,
+					Value:       failure.value,
+					Rule:        failure.rule,
+					Policy:      policy,
+				})
+			}
+			if _, exists := seen[failure.rule]; exists {
+				continue
+			}
+			seen[failure.rule] = struct{}{}
+			switch policy {
+			case ConstraintPolicyError, ConstraintPolicyWarn:
+				s.warnings.affectRule(rule.Path, WarningCodeConstraintViolation, failure.rule, index, before, after)
+			case ConstraintPolicyNull:
+				s.warnings.affectRule(rule.Path, WarningCodeNullInvalid, failure.rule, index, before, after)
+			case ConstraintPolicyDropRecord:
+				s.warnings.affectRule(rule.Path, WarningCodeDropRecord, failure.rule, index, before, after)
+			}
+		}
+	}
+	return keep, nil
+}
+
+// sample returns the value at the rule's path in record, or the values of
+// every match of a wildcard.
+func (c compiledConstraint) sample(record Record) (any, error) {
+	values, err := c.path.Values(record)
+	if err != nil || len(values) == 0 {
+		return nil, err
+	}
+	if c.path.wildcard {
+		return values, nil
+	}
+	return values[0], nil
+}
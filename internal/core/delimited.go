@@ -0,0 +1,193 @@
+package core
+
+import (
+	"errors"
+	"strings"
+	"unicode/utf8"
+)
+
+const quoteChar = `"`
+
+func validateJoinCollision(rule JoinArrayRule) error {
+	if rule.Delimiter == "" {
+		return errors.New("join delimiter is empty: " + rule.Path)
+	}
+	switch rule.OnCollision {
+	case "", DelimiterCollisionError, DelimiterCollisionAcknowledge:
+		return nil
+	case DelimiterCollisionEscape:
+		return validateEscapeChar(rule.EscapeChar, rule.Delimiter, rule.Path)
+	case DelimiterCollisionQuote:
+		return validateQuoteDelimiter(rule.Delimiter, rule.Path)
+	default:
+		return errors.New("unsupported join on_collision strategy for path: " + rule.Path)
+	}
+}
+
+func validateSplitEscaping(rule SplitStringRule) error {
+	if rule.Delimiter == "" {
+		return errors.New("split delimiter is empty: " + rule.Path)
+	}
+	switch rule.Escaping {
+	case "":
+		return nil
+	case DelimiterCollisionEscape:
+		return validateEscapeChar(rule.EscapeChar, rule.Delimiter, rule.Path)
+	case DelimiterCollisionQuote:
+		return validateQuoteDelimiter(rule.Delimiter, rule.Path)
+	default:
+		return errors.New("split escaping must be escape or quote for path: " + rule.Path)
+	}
+}
+
+func validateEscapeChar(escapeChar string, delimiter string, path string) error {
+	if utf8.RuneCountInString(escapeChar) != 1 {
+		return errors.New("escape_char must be a single character for path: " + path)
+	}
+	if strings.Contains(delimiter, escapeChar) {
+		return errors.New("escape_char must not appear in the delimiter for path: " + path)
+	}
+	return nil
+}
+
+func validateQuoteDelimiter(delimiter string, path string) error {
+	if strings.Contains(delimiter, quoteChar) {
+		return errors.New("quote strategy requires a delimiter without double quotes for path: " + path)
+	}
+	return nil
+}
+
+// encodeJoinParts joins parts according to the rule's collision strategy and
+// reports whether any part contained the delimiter.
+func encodeJoinParts(parts []string, rule JoinArrayRule) (string, bool, error) {
+	collided := false
+	encoded := make([]string, len(parts))
+	for index, part := range parts {
+		contains := strings.Contains(part, rule.Delimiter)
+		if contains {
+			collided = true
+		}
+		switch rule.OnCollision {
+		case DelimiterCollisionEscape:
+			encoded[index] = escapePart(part, rule.Delimiter, rule.EscapeChar)
+		case DelimiterCollisionQuote:
+			if contains || strings.Contains(part, quoteChar) {
+				encoded[index] = quoteChar + strings.ReplaceAll(part, quoteChar, quoteChar+quoteChar) + quoteChar
+			} else {
+				encoded[index] = part
+			}
+		case DelimiterCollisionAcknowledge:
+			encoded[index] = part
+		default:
+			if contains {
+				return "", true, errors.New("join array element contains the delimiter at path: " + rule.Path)
+			}
+			encoded[index] = part
+		}
+	}
+	return strings.Join(encoded, rule.Delimiter), collided, nil
+}
+
+func escapePart(part string, delimiter string, escapeChar string) string {
+	builder := strings.Builder{}
+	for rest := part; rest != ""; {
+		switch {
+		case strings.HasPrefix(rest, escapeChar):
+			builder.WriteString(escapeChar + escapeChar)
+			rest = rest[len(escapeChar):]
+		case strings.HasPrefix(rest, delimiter):
+			builder.WriteString(escapeChar + delimiter)
+			rest = rest[len(delimiter):]
+		default:
+			_, size := utf8.DecodeRuneInString(rest)
+			builder.WriteString(rest[:size])
+			rest = rest[size:]
+		}
+	}
+	return builder.String()
+}
+
+// decodeSplitParts splits text according to the rule's escaping.
+func decodeSplitParts(text string, rule SplitStringRule) ([]string, error) {
+	switch rule.Escaping {
+	case DelimiterCollisionEscape:
+		return splitEscaped(text, rule.Delimiter, rule.EscapeChar, rule.Path)
+	case DelimiterCollisionQuote:
+		return splitQuoted(text, rule.Delimiter, rule.Path)
+	default:
+		return strings.Split(text, rule.Delimiter), nil
+	}
+}
+
+func splitEscaped(text string, delimiter string, escapeChar string, path string) ([]string, error) {
+	parts := []string{}
+	current := strings.Builder{}
+	for rest := text; rest != ""; {
+		switch {
+		case strings.HasPrefix(rest, escapeChar):
+			rest = rest[len(escapeChar):]
+			switch {
+			case strings.HasPrefix(rest, escapeChar):
+				current.WriteString(escapeChar)
+				rest = rest[len(escapeChar):]
+			case strings.HasPrefix(rest, delimiter):
+				current.WriteString(delimiter)
+				rest = rest[len(delimiter):]
+			default:
+				return nil, errors.New("invalid escape sequence in split string at path: " + path)
+			}
+		case strings.HasPrefix(rest, delimiter):
+			parts = append(parts, current.String())
+			current.Reset()
+			rest = rest[len(delimiter):]
+		default:
+			_, size := utf8.DecodeRuneInString(rest)
+			current.WriteString(rest[:size])
+			rest = rest[size:]
+		}
+	}
+	return append(parts, current.String()), nil
+}
+
+func splitQuoted(text string, delimiter string, path string) ([]string, error) {
+	parts := []string{}
+	rest := text
+	for {
+		if !strings.HasPrefix(rest, quoteChar) {
+			part, remainder, found := strings.Cut(rest, delimiter)
+			if strings.Contains(part, quoteChar) {
+				return nil, errors.New("unexpected quote in split string at path: " + path)
+			}
+			parts = append(parts, part)
+			if !found {
+				return parts, nil
+			}
+			rest = remainder
+			continue
+		}
+		current := strings.Builder{}
+		rest = rest[len(quoteChar):]
+		for {
+			index := strings.Index(rest, quoteChar)
+			if index < 0 {
+				return nil, errors.New("unterminated quote in split string at path: " + path)
+			}
+			current.WriteString(rest[:index])
+			rest = rest[index+len(quoteChar):]
+			if strings.HasPrefix(rest, quoteChar) {
+				current.WriteString(quoteChar)
+				rest = rest[len(quoteChar):]
+				continue
+			}
+			break
+		}
+		parts = append(parts, current.String())
+		if rest == "" {
+			return parts, nil
+		}
+		if !strings.HasPrefix(rest, delimiter) {
+			return nil, errors.New("expected delimiter after quoted part in split string at path: " + path)
+		}
+		rest = rest[len(delimiter):]
+	}
+}
@@ -0,0 +1,62 @@
+package core
+
+import (
+	"errors"
+	"sort"
+	"strings"
+)
+
+func validateFieldOrder(order FieldOrder) error {
+	switch order {
+	case "", FieldOrderSorted, FieldOrderInput:
+		return nil
+	default:
+		return errors.New("unsupported field_order: " + string(order))
+	}
+}
+
+// orderShape sorts shape fields by their first position in order. Paths
+// missing from order follow, sorted by path.
+func orderShape(shape DataShape, order []string) DataShape {
+	rank := pathRanks(order)
+	fields := append([]FieldDefinition(nil), shape.Fields...)
+	sort.SliceStable(fields, func(i, j int) bool {
+		left, leftKnown := rank[fields[i].Path]
+		right, rightKnown := rank[fields[j].Path]
+		if leftKnown && rightKnown {
+			return left < right
+		}
+		if leftKnown != rightKnown {
+			return leftKnown
+		}
+		return fields[i].Path < fields[j].Path
+	})
+	return DataShape{Fields: fields, Order: ShapeOrderDeclared, SourceOrder: order}
+}
+
+func pathRanks(order []string) map[string]int {
+	rank := make(map[string]int, len(order))
+	for index, path := range order {
+		if _, exists := rank[path]; !exists {
+			rank[path] = index
+		}
+	}
+	return rank
+}
+
+// renameOrder moves from, and any path nested under it, to the matching
+// path under to, keeping their positions.
+func renameOrder(order []string, from string, to string) []string {
+	renamed := make([]string, len(order))
+	for index, path := range order {
+		switch {
+		case path == from:
+			renamed[index] = to
+		case PathWithin(path, from):
+			renamed[index] = to + strings.TrimPrefix(path, from)
+		default:
+			renamed[index] = path
+		}
+	}
+	return renamed
+}
@@ -0,0 +1,178 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// LossBudget limits the lossy operations a transformation may perform.
+type LossBudget struct {
+	FailOnWarning       bool            `json:"fail_on_warning,omitempty"`
+	ForbiddenStrategies []Strategy      `json:"forbidden_strategies,omitempty"`
+	StrategyLimits      []StrategyLimit `json:"strategy_limits,omitempty"`
+}
+
+// StrategyLimit caps the records affected by a strategy. A record changed
+// at several paths counts once; records are numbered as warnings number
+// them.
+type StrategyLimit struct {
+	Strategy           Strategy `json:"strategy"`
+	MaxAffectedRecords int      `json:"max_affected_records"`
+}
+
+// LossBudgetRule names the budget rule a violation broke.
+type LossBudgetRule string
+
+const (
+	LossBudgetRuleFailOnWarning      LossBudgetRule = "fail_on_warning"
+	LossBudgetRuleForbiddenStrategy  LossBudgetRule = "forbidden_strategy"
+	LossBudgetRuleMaxAffectedRecords LossBudgetRule = "max_affected_records"
+)
+
+// LossBudgetViolation describes one exceeded limit.
+type LossBudgetViolation struct {
+	Rule     LossBudgetRule `json:"rule"`
+	Strategy Strategy       `json:"strategy,omitempty"`
+	Code     WarningCode    `json:"code,omitempty"`
+	Path     string         `json:"path,omitempty"`
+	Limit    int            `json:"limit,omitempty"`
+	Actual   int            `json:"actual"`
+}
+
+// LossBudgetError lists every violated limit.
+type LossBudgetError struct {
+	Violations []LossBudgetViolation
+}
+
+func (e *LossBudgetError) Error() string {
+	parts := make([]string, 0, len(e.Violations))
+	for _, violation := range e.Violations {
+		switch violation.Rule {
+		case LossBudgetRuleFailOnWarning:
+			parts = append(parts, fmt.Sprintf("warning %s at path %s (%d records)", violation.Code, violation.Path, violation.Actual))
+		case LossBudgetRuleForbiddenStrategy:
+			parts = append(parts, fmt.Sprintf("forbidden strategy %s at path %s (%d records)", violation.Strategy, violation.Path, violation.Actual))
+		case LossBudgetRuleMaxAffectedRecords:
+			parts = append(parts, fmt.Sprintf("strategy %s affected %d records (max %d)", violation.Strategy, violation.Actual, violation.Limit))
+		}
+	}
+	return "loss budget exceeded: " + strings.Join(parts, "; ")
+}
+
+// StrategyForWarning returns the lossy strategy a warning code reports.
+func StrategyForWarning(code WarningCode) (Strategy, bool) {
+	switch code {
+	case WarningCodeJoinArray:
+		return StrategyJoinArray, true
+	case WarningCodeDropField:
+		return StrategyDropField, true
+	case WarningCodeCoerceType:
+		return StrategyCoerceType, true
+	case WarningCodeSplitString:
+		return StrategySplitString, true
+	case WarningCodeJoinCollision:
+		return StrategyJoinCollision, true
+	case WarningCodeOverwriteField:
+		return StrategyOverwriteField, true
+	case WarningCodeNarrowNumber:
+		return StrategyNarrowNumber, true
+	case WarningCodeTruncateTime:
+		return StrategyTruncateTime, true
+	case WarningCodeNullInvalid:
+		return StrategyNullInvalid, true
+	case WarningCodeDropRecord:
+		return StrategyDropRecord, true
+	default:
+		return "", false
+	}
+}
+
+// ParseStrategy returns the lossy strategy called name.
+func ParseStrategy(name string) (Strategy, error) {
+	switch strategy := Strategy(name); strategy {
+	case StrategyJoinArray, StrategyDropField, StrategyCoerceType, StrategySplitString,
+		StrategyJoinCollision, StrategyOverwriteField, StrategyNarrowNumber,
+		StrategyTruncateTime, StrategyNullInvalid, StrategyDropRecord:
+		return strategy, nil
+	}
+	return "", errors.New("unsupported strategy: " + name)
+}
+
+// validateLossBudget rejects strategies that no warning reports, so a
+// misspelled name cannot silently lift a limit.
+func validateLossBudget(budget *LossBudget) error {
+	if budget == nil {
+		return nil
+	}
+	for _, strategy := range budget.ForbiddenStrategies {
+		if _, err := ParseStrategy(string(strategy)); err != nil {
+			return errors.New("loss_budget forbidden_strategies: " + err.Error())
+		}
+	}
+	for _, limit := range budget.StrategyLimits {
+		if _, err := ParseStrategy(string(limit.Strategy)); err != nil {
+			return errors.New("loss_budget strategy_limits: " + err.Error())
+		}
+	}
+	return nil
+}
+
+// EvaluateLossBudget checks warnings against a budget and returns a
+// *LossBudgetError listing every violation, or nil. Strategy limits are
+// checked against affected, the number of distinct records each strategy
+// changed. A budget naming an unknown strategy returns a plain error.
+func EvaluateLossBudget(budget LossBudget, warnings []Warning, affected map[Strategy]int) error {
+	if err := validateLossBudget(&budget); err != nil {
+		return err
+	}
+	violations := []LossBudgetViolation{}
+	forbidden := map[Strategy]struct{}{}
+	for _, strategy := range budget.ForbiddenStrategies {
+		forbidden[strategy] = struct{}{}
+	}
+
+	for _, warning := range warnings {
+		if budget.FailOnWarning {
+			violations = append(violations, LossBudgetViolation{
+				Rule:   LossBudgetRuleFailOnWarning,
+				Code:   warning.Code,
+				Path:   warning.Path,
+				Actual: warning.AffectedCount,
+			})
+		}
+		strategy, ok := StrategyForWarning(warning.Code)
+		if !ok {
+			continue
+		}
+		if _, isForbidden := forbidden[strategy]; isForbidden {
+			violations = append(violations, LossBudgetViolation{
+				Rule:     LossBudgetRuleForbiddenStrategy,
+				Strategy: strategy,
+				Code:     warning.Code,
+				Path:     warning.Path,
+				Actual:   warning.AffectedCount,
+			})
+		}
+	}
+
+	limits := append([]StrategyLimit(nil), budget.StrategyLimits...)
+	sort.SliceStable(limits, func(i, j int) bool { return limits[i].Strategy < limits[j].Strategy })
+	for _, limit := range limits {
+		actual := affected[limit.Strategy]
+		if actual > limit.MaxAffectedRecords {
+			violations = append(violations, LossBudgetViolation{
+				Rule:     LossBudgetRuleMaxAffectedRecords,
+				Strategy: limit.Strategy,
+				Limit:    limit.MaxAffectedRecords,
+				Actual:   actual,
+			})
+		}
+	}
+
+	if len(violations) == 0 {
+		return nil
+	}
+	return &LossBudgetError{Violations: violations}
+}
@@ -3,34 +3,77 @@ package core
 // LogicalType describes the canonical field type.
 type LogicalType string
 
+// LogicalTypeNumber is a binary floating-point number. LogicalTypeInteger and
+// LogicalTypeDecimal are exact and carried as json.Number values.
+// LogicalTypeDate, LogicalTypeDatetime, and LogicalTypeDuration are carried
+// as Date, time.Time, and Duration values.
 const (
-	LogicalTypeString  LogicalType = "string"
-	LogicalTypeNumber  LogicalType = "number"
-	LogicalTypeBoolean LogicalType = "boolean"
-	LogicalTypeObject  LogicalType = "object"
-	LogicalTypeArray   LogicalType = "array"
+	LogicalTypeString   LogicalType = "string"
+	LogicalTypeNumber   LogicalType = "number"
+	LogicalTypeInteger  LogicalType = "integer"
+	LogicalTypeDecimal  LogicalType = "decimal"
+	LogicalTypeBoolean  LogicalType = "boolean"
+	LogicalTypeDate     LogicalType = "date"
+	LogicalTypeDatetime LogicalType = "datetime"
+	LogicalTypeDuration LogicalType = "duration"
+	LogicalTypeObject   LogicalType = "object"
+	LogicalTypeArray    LogicalType = "array"
 )
 
-// FieldConstraints describe optional validation constraints.
+// FieldConstraints describe optional validation constraints. OnViolation
+// decides what happens to a record that fails one of them.
 type FieldConstraints struct {
-	Minimum *float64 `json:"minimum,omitempty"`
-	Maximum *float64 `json:"maximum,omitempty"`
-	Enum    []string `json:"enum,omitempty"`
-	Pattern string   `json:"pattern,omitempty"`
+	Minimum     *float64         `json:"minimum,omitempty"`
+	Maximum     *float64         `json:"maximum,omitempty"`
+	Enum        []string         `json:"enum,omitempty"`
+	Pattern     string           `json:"pattern,omitempty"`
+	OnViolation ConstraintPolicy `json:"on_violation,omitempty"`
 }
 
-// FieldDefinition describes a canonical field.
+// ConstraintPolicy describes how a constraint violation is handled.
+type ConstraintPolicy string
+
+const (
+	// ConstraintPolicyError fails the transform. An empty value behaves the same.
+	ConstraintPolicyError ConstraintPolicy = "error"
+	// ConstraintPolicyWarn keeps the value and emits a warning.
+	ConstraintPolicyWarn ConstraintPolicy = "warn"
+	// ConstraintPolicyNull replaces the value with null; it needs a
+	// null_invalid lossy decision.
+	ConstraintPolicyNull ConstraintPolicy = "null"
+	// ConstraintPolicyDropRecord removes the record; it needs a drop_record
+	// lossy decision.
+	ConstraintPolicyDropRecord ConstraintPolicy = "drop_record"
+)
+
+// FieldDefinition describes a canonical field. Label, when set, is the name
+// encoders use for the field instead of its path.
 type FieldDefinition struct {
 	Path        string            `json:"path"`
 	Type        LogicalType       `json:"type"`
 	Nullable    bool              `json:"nullable"`
 	Repeated    bool              `json:"repeated"`
 	Constraints *FieldConstraints `json:"constraints,omitempty"`
+	Label       string            `json:"label,omitempty"`
 }
 
+// ShapeOrder describes how encoders order shape fields.
+type ShapeOrder string
+
+const (
+	// ShapeOrderSorted orders fields by path. An empty value behaves the same.
+	ShapeOrderSorted ShapeOrder = "sorted"
+	// ShapeOrderDeclared keeps fields in the order they are listed.
+	ShapeOrderDeclared ShapeOrder = "declared"
+)
+
 // DataShape describes the paths, types, and repetition for canonical records.
+// SourceOrder lists paths in the order a decoder first saw them; it is
+// runtime metadata and is not serialized.
 type DataShape struct {
-	Fields []FieldDefinition `json:"fields"`
+	Fields      []FieldDefinition `json:"fields"`
+	Order       ShapeOrder        `json:"order,omitempty"`
+	SourceOrder []string          `json:"-"`
 }
 
 // DataValues contains the canonical records.
@@ -45,9 +88,18 @@ type Record map[string]any
 type WarningCode string
 
 const (
-	WarningCodeJoinArray  WarningCode = "join_array"
-	WarningCodeDropField  WarningCode = "drop_field"
-	WarningCodeCoerceType WarningCode = "coerce_type"
+	WarningCodeJoinArray      WarningCode = "join_array"
+	WarningCodeDropField      WarningCode = "drop_field"
+	WarningCodeCoerceType     WarningCode = "coerce_type"
+	WarningCodeSplitString    WarningCode = "split_string"
+	WarningCodeJoinCollision  WarningCode = "join_collision"
+	WarningCodeOverwriteField WarningCode = "overwrite_field"
+	WarningCodeNarrowNumber   WarningCode = "narrow_number"
+	WarningCodeTruncateTime   WarningCode = "truncate_time"
+	// Constraint warnings carry the violated rule in Warning.Rule.
+	WarningCodeConstraintViolation WarningCode = "constraint_violation"
+	WarningCodeNullInvalid         WarningCode = "null_invalid"
+	WarningCodeDropRecord          WarningCode = "drop_record"
 )
 
 func (c WarningCode) String() string {
@@ -61,8 +113,23 @@ type CanonicalData struct {
 }
 
 // Warning captures lossy or noteworthy operations.
+//
+// AffectedCount is the number of records the operation changed. RecordIndices
+// and Samples hold the first WarningSampleLimit of those records, indexed by
+// position in the record list when the operation ran.
 type Warning struct {
-	Code    WarningCode
-	Message string
-	Path    string
+	Code          WarningCode     `json:"code"`
+	Message       string          `json:"message"`
+	Path          string          `json:"path"`
+	Rule          string          `json:"rule,omitempty"`
+	AffectedCount int             `json:"affected_count"`
+	RecordIndices []int           `json:"record_indices,omitempty"`
+	Samples       []WarningSample `json:"samples,omitempty"`
+}
+
+// WarningSample shows a value before and after a lossy operation.
+type WarningSample struct {
+	RecordIndex int `json:"record_index"`
+	Before      any `json:"before"`
+	After       any `json:"after"`
 }
@@ -0,0 +1,165 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Exact numbers are carried as json.Number so their text survives decoding,
+// transformation, and encoding unchanged.
+
+var jsonNumberPattern = regexp.MustCompile(`^-?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][+-]?[0-9]+)?$`)
+
+func isJSONNumber(text string) bool {
+	return jsonNumberPattern.MatchString(text)
+}
+
+// numberKind returns LogicalTypeInteger for integer literals and
+// LogicalTypeDecimal for literals with a fraction or exponent.
+func numberKind(number json.Number) LogicalType {
+	if strings.ContainsAny(string(number), ".eE") {
+		return LogicalTypeDecimal
+	}
+	return LogicalTypeInteger
+}
+
+// exactValue returns the exact rational value of a number or numeric string.
+func exactValue(value any) (*big.Rat, bool) {
+	switch typed := value.(type) {
+	case json.Number:
+		return new(big.Rat).SetString(string(typed))
+	case string:
+		trimmed := strings.TrimSpace(typed)
+		if !isJSONNumber(trimmed) {
+			return nil, false
+		}
+		return new(big.Rat).SetString(trimmed)
+	case float64:
+		if math.IsInf(typed, 0) || math.IsNaN(typed) {
+			return nil, false
+		}
+		return new(big.Rat).SetFloat64(typed), true
+	case float32:
+		return exactValue(float64(typed))
+	case int:
+		return new(big.Rat).SetInt64(int64(typed)), true
+	case int64:
+		return new(big.Rat).SetInt64(typed), true
+	case int32:
+		return new(big.Rat).SetInt64(int64(typed)), true
+	case uint:
+		return new(big.Rat).SetUint64(uint64(typed)), true
+	case uint64:
+		return new(big.Rat).SetUint64(typed), true
+	case uint32:
+		return new(big.Rat).SetUint64(uint64(typed)), true
+	default:
+		return nil, false
+	}
+}
+
+// narrowsToFloat reports whether converting exact to parsed changes the
+// value, i.e. the shortest text of parsed does not denote exact.
+func narrowsToFloat(exact *big.Rat, parsed float64) bool {
+	shortest, ok := new(big.Rat).SetString(strconv.FormatFloat(parsed, 'g', -1, 64))
+	return !ok || shortest.Cmp(exact) != 0
+}
+
+// coerceNumber converts a value to float64 and reports whether the
+// conversion lost precision.
+func coerceNumber(value any) (float64, bool, error) {
+	var parsed float64
+	switch typed := value.(type) {
+	case bool:
+		if typed {
+			return 1, false, nil
+		}
+		return 0, false, nil
+	case string:
+		number, err := strconv.ParseFloat(strings.TrimSpace(typed), 64)
+		if err != nil {
+			return 0, false, errors.New("cannot coerce string to number")
+		}
+		parsed = number
+	case json.Number:
+		number, err := strconv.ParseFloat(string(typed), 64)
+		if err != nil {
+			return 0, false, errors.New("cannot coerce value to number: " + string(typed))
+		}
+		parsed = number
+	case float64:
+		return typed, false, nil
+	case float32:
+		return float64(typed), false, nil
+	case int, int64, int32, uint, uint64, uint32:
+		parsed, _ = mustExact(typed).Float64()
+	default:
+		return 0, false, errors.New("cannot coerce value to number")
+	}
+	exact, ok := exactValue(value)
+	return parsed, ok && narrowsToFloat(exact, parsed), nil
+}
+
+// coerceInteger converts a value with an integral value to an exact integer.
+func coerceInteger(value any) (json.Number, error) {
+	if typed, ok := value.(bool); ok {
+		return boolNumber(typed), nil
+	}
+	exact, ok := exactValue(value)
+	if !ok {
+		return "", errors.New("cannot coerce value to integer")
+	}
+	if !exact.IsInt() {
+		return "", errors.New("cannot coerce non-integral value to integer: " + fmt.Sprint(value))
+	}
+	return json.Number(exact.Num().String()), nil
+}
+
+// coerceDecimal converts a value to an exact decimal, keeping the text of
+// numbers that already have one.
+func coerceDecimal(value any) (json.Number, error) {
+	switch typed := value.(type) {
+	case bool:
+		return boolNumber(typed), nil
+	case json.Number:
+		return typed, nil
+	case string:
+		trimmed := strings.TrimSpace(typed)
+		if !isJSONNumber(trimmed) {
+			return "", errors.New("cannot coerce string to decimal")
+		}
+		return json.Number(trimmed), nil
+	case float64:
+		if _, ok := exactValue(typed); !ok {
+			return "", errors.New("cannot coerce value to decimal")
+		}
+		return json.Number(strconv.FormatFloat(typed, 'g', -1, 64)), nil
+	case float32:
+		if _, ok := exactValue(typed); !ok {
+			return "", errors.New("cannot coerce value to decimal")
+		}
+		return json.Number(strconv.FormatFloat(float64(typed), 'g', -1, 32)), nil
+	case int, int64, int32, uint, uint64, uint32:
+		return json.Number(fmt.Sprint(typed)), nil
+	default:
+		return "", errors.New("cannot coerce value to decimal")
+	}
+}
+
+func mustExact(value any) *big.Rat {
+	exact, _ := exactValue(value)
+	return exact
+}
+
+func boolNumber(value bool) json.Number {
+	if value {
+		return "1"
+	}
+	return "0"
+}
@@ -0,0 +1,169 @@
+package core
+
+import (
+	"errors"
+	"sort"
+	"strings"
+)
+
+func validateOutputFields(plan ConversionPlan) error {
+	if len(plan.OutputFields) == 0 {
+		if plan.UnlistedFields != "" {
+			return errors.New("unlisted_fields requires output_fields")
+		}
+		return nil
+	}
+	switch plan.UnlistedFields {
+	case "", UnlistedFieldsError, UnlistedFieldsAppend, UnlistedFieldsDrop:
+	default:
+		return errors.New("unsupported unlisted_fields: " + string(plan.UnlistedFields))
+	}
+	paths := map[string]struct{}{}
+	headers := map[string]struct{}{}
+	for _, field := range plan.OutputFields {
+		if field.Path == "" {
+			return errors.New("output_fields path is empty")
+		}
+		if _, exists := paths[field.Path]; exists {
+			return errors.New("output_fields path is listed twice: " + field.Path)
+		}
+		paths[field.Path] = struct{}{}
+		header := field.label()
+		if _, exists := headers[header]; exists {
+			return errors.New("output_fields header is used twice: " + header)
+		}
+		headers[header] = struct{}{}
+	}
+	for _, field := range plan.OutputFields {
+		for other := range paths {
+			if field.Path != other && PathWithin(field.Path, other) {
+				return errors.New("output_fields path overlaps another listed path: " + field.Path)
+			}
+		}
+	}
+	return nil
+}
+
+func (f OutputField) label() string {
+	if f.Header != "" {
+		return f.Header
+	}
+	return f.Path
+}
+
+// outputCovers reports whether a shape path is part of a listed output
+// field: the field itself, a container holding it, or a value nested in it.
+func outputCovers(fields []OutputField, path string) bool {
+	for _, field := range fields {
+		if PathWithin(path, field.Path) || PathWithin(field.Path, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// unlistedPaths returns the outermost shape paths not covered by the output
+// fields, sorted.
+func unlistedPaths(shape DataShape, fields []OutputField) []string {
+	paths := make([]string, 0, len(shape.Fields))
+	for _, field := range shape.Fields {
+		if !outputCovers(fields, field.Path) {
+			paths = append(paths, field.Path)
+		}
+	}
+	sort.Strings(paths)
+	outermost := []string{}
+	for _, path := range paths {
+		if !pathWithinAny(path, outermost) {
+			outermost = append(outermost, path)
+		}
+	}
+	return outermost
+}
+
+// pathWithinAny reports whether path is one of paths or nested under one.
+// Sorted paths do not keep a path next to its ancestor when a key contains
+// '[' or a character sorting before '.', so every path is checked.
+func pathWithinAny(path string, paths []string) bool {
+	for _, ancestor := range paths {
+		if PathWithin(path, ancestor) {
+			return true
+		}
+	}
+	return false
+}
+
+// dropUnlisted removes unlisted fields from the records. Each dropped path
+// is a drop_field lossy action.
+func (s *transformState) dropUnlisted(shape DataShape, fields []OutputField) error {
+	steps, err := s.unlistedSteps(shape, fields)
+	if err != nil {
+		return err
+	}
+	for _, step := range steps {
+		if err := s.run(step); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unlistedSteps returns a drop step for every unlisted path of shape,
+// checking the drop_field decisions first.
+func (s *transformState) unlistedSteps(shape DataShape, fields []OutputField) ([]recordStep, error) {
+	paths := unlistedPaths(shape, fields)
+	steps := make([]recordStep, 0, len(paths))
+	for _, path := range paths {
+		if _, err := requireLossyDecision(s.decisions, StrategyDropField, path); err != nil {
+			return nil, errors.New("unlisted_fields drop requires drop_field lossy_decisions entry for path: " + path)
+		}
+		step, err := s.dropField(path)
+		if err != nil {
+			return nil, err
+		}
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+// layoutShape orders the shape by the output fields and labels them with
+// their headers. Appended fields keep their order in shape. Listed fields
+// missing from the data are included as nullable strings so the layout does
+// not depend on the records.
+func layoutShape(shape DataShape, fields []OutputField, unlisted UnlistedFields) (DataShape, error) {
+	byPath := map[string]FieldDefinition{}
+	for _, field := range shape.Fields {
+		byPath[field.Path] = field
+	}
+	remaining := []FieldDefinition{}
+	for _, field := range shape.Fields {
+		if !outputCovers(fields, field.Path) {
+			remaining = append(remaining, field)
+		}
+	}
+	if len(remaining) > 0 && unlisted != UnlistedFieldsAppend {
+		paths := unlistedPaths(shape, fields)
+		return DataShape{}, errors.New("fields not listed in output_fields: " + strings.Join(paths, ", "))
+	}
+
+	ordered := make([]FieldDefinition, 0, len(fields)+len(remaining))
+	for _, output := range fields {
+		field, ok := byPath[output.Path]
+		if !ok {
+			field = FieldDefinition{Path: output.Path, Type: LogicalTypeString, Nullable: true}
+		}
+		field.Label = output.Header
+		ordered = append(ordered, field)
+	}
+	labels := map[string]struct{}{}
+	for _, output := range fields {
+		labels[output.label()] = struct{}{}
+	}
+	for _, field := range remaining {
+		if _, exists := labels[field.Path]; exists {
+			return DataShape{}, errors.New("unlisted field collides with output_fields header: " + field.Path)
+		}
+	}
+	ordered = append(ordered, remaining...)
+	return DataShape{Fields: ordered, Order: ShapeOrderDeclared, SourceOrder: shape.SourceOrder}, nil
+}
@@ -2,33 +2,341 @@ package core
 
 import (
 	"errors"
+	"sort"
 	"strings"
 )
 
-func splitPath(path string) []string {
-	cleaned := strings.TrimSpace(path)
-	if cleaned == "" {
-		return []string{}
+// Path is a parsed path, resolved against many records without splitting or
+// joining strings. Keys written bare prefer, at each level, a key holding
+// the rest of the path literally, dots included, over descending into the
+// next key; quoted keys are matched exactly. ParsePath describes the syntax.
+//
+// A path with a wildcard resolves to every value it matches, in document
+// order with object keys sorted.
+type Path struct {
+	segments []pathSegment
+	// literals[i] is the rest of the path from segments[i] joined with dots,
+	// the flattened key looked up before descending into segments[i]. It is
+	// empty for the last segment and when a segment other than a bare key
+	// follows.
+	literals []string
+	// lastIndexed is the position of the last index or wildcard segment, or
+	// -1. Missing objects are only created below it.
+	lastIndexed int
+	wildcard    bool
+	text        string
+}
+
+type segmentKind int
+
+const (
+	segmentKey segmentKind = iota
+	segmentIndex
+	segmentWildcard
+)
+
+// pathSegment is one step of a Path: an object key, an array index, or a
+// wildcard.
+type pathSegment struct {
+	kind   segmentKind
+	key    string
+	quoted bool
+	index  int
+}
+
+// bracketed reports whether the segment is written in brackets.
+func (s pathSegment) bracketed() bool {
+	return s.kind != segmentKey || s.quoted
+}
+
+func newPath(segments []pathSegment) Path {
+	path := Path{segments: segments, literals: make([]string, len(segments)), lastIndexed: -1, text: formatPath(segments)}
+	for index, segment := range segments {
+		if segment.kind != segmentKey {
+			path.lastIndexed = index
+		}
+		if segment.kind == segmentWildcard {
+			path.wildcard = true
+		}
+	}
+	for index := len(segments) - 2; index >= 0; index-- {
+		if segments[index].bracketed() || segments[index+1].bracketed() {
+			break
+		}
+		if index == len(segments)-2 {
+			path.literals[index] = segments[index].key + "." + segments[index+1].key
+			continue
+		}
+		if path.literals[index+1] == "" {
+			break
+		}
+		path.literals[index] = segments[index].key + "." + path.literals[index+1]
+	}
+	return path
+}
+
+// String returns the canonical form of the path.
+func (p Path) String() string {
+	return p.text
+}
+
+// KeysOnly reports whether every segment of p is an object key, with no
+// index or wildcard.
+func (p Path) KeysOnly() bool {
+	return p.lastIndexed < 0
+}
+
+// TopLevelKey returns the key of p when p is a single object key.
+func (p Path) TopLevelKey() (string, bool) {
+	if len(p.segments) != 1 || p.segments[0].kind != segmentKey {
+		return "", false
 	}
-	return strings.Split(cleaned, ".")
+	return p.segments[0].key, true
 }
 
-func getValueAtPath(record Record, path string) (any, bool, error) {
-	segments := splitPath(path)
-	if len(segments) == 0 {
+// flatKey joins the keys of p with dots, the key flattening gives the values
+// nested in p.
+func (p Path) flatKey() string {
+	keys := make([]string, len(p.segments))
+	for index, segment := range p.segments {
+		keys[index] = segment.key
+	}
+	return strings.Join(keys, ".")
+}
+
+// ancestors returns the paths of the objects enclosing p, deepest first.
+func (p Path) ancestors() []Path {
+	ancestors := make([]Path, 0, len(p.segments))
+	for depth := len(p.segments) - 1; depth > 0; depth-- {
+		ancestors = append(ancestors, newPath(p.segments[:depth]))
+	}
+	return ancestors
+}
+
+// Depth returns the number of segments in p.
+func (p Path) Depth() int {
+	return len(p.segments)
+}
+
+// Literal returns the key holding the rest of p literally from depth, which
+// Match prefers over the next key, or "" when there is none.
+func (p Path) Literal(depth int) string {
+	return p.literals[depth]
+}
+
+// Match reports how far key, found in the object reached after depth
+// segments of p, advances resolution: to the end of p when it is the
+// literal rest of the path, or by one segment when it is the next key.
+// Indices and wildcards match no key.
+func (p Path) Match(depth int, key string) (int, bool) {
+	if literal := p.literals[depth]; literal != "" && key == literal {
+		return len(p.segments), true
+	}
+	if segment := p.segments[depth]; segment.kind == segmentKey && key == segment.key {
+		return depth + 1, true
+	}
+	return depth, false
+}
+
+// pathTarget is one place a path resolves to: a key of an object or an
+// item of an array, whether or not a value is stored there yet.
+type pathTarget struct {
+	object map[string]any
+	key    string
+	// holder is where the array holding the item at index is stored; it is
+	// read again on every access, since removing an item replaces the array.
+	holder *pathTarget
+	index  int
+}
+
+func (t pathTarget) value() (any, bool) {
+	if t.holder == nil {
+		value, exists := t.object[t.key]
+		return value, exists
+	}
+	return t.items()[t.index], true
+}
+
+func (t pathTarget) items() []any {
+	value, _ := t.holder.value()
+	return value.([]any)
+}
+
+func (t pathTarget) set(value any) {
+	if t.holder == nil {
+		t.object[t.key] = value
+		return
+	}
+	t.items()[t.index] = value
+}
+
+func (t pathTarget) remove() {
+	if t.holder == nil {
+		delete(t.object, t.key)
+		return
+	}
+	items := t.items()
+	t.holder.set(append(items[:t.index:t.index], items[t.index+1:]...))
+}
+
+// targets resolves p in record. With create set, missing objects below the
+// last index or wildcard are created so a value can be stored; otherwise a
+// missing key or index ends resolution without a target, except that the
+// last key is a target even when absent.
+func (p Path) targets(record Record, create bool) ([]pathTarget, error) {
+	if len(p.segments) == 0 {
+		return nil, errors.New("path is empty")
+	}
+	if p.lastIndexed < 0 {
+		target, found, err := p.keyTarget(record, create)
+		if err != nil || !found {
+			return nil, err
+		}
+		return []pathTarget{target}, nil
+	}
+	targets := []pathTarget{}
+	err := p.resolve(map[string]any(record), nil, 0, create, &targets)
+	return targets, err
+}
+
+// keyTarget resolves a path of object keys alone, which has at most one
+// target, without the bookkeeping resolve needs for arrays.
+func (p Path) keyTarget(record Record, create bool) (pathTarget, bool, error) {
+	current := any(record)
+	for depth, segment := range p.segments {
+		object, ok := mapFromValue(current)
+		if !ok {
+			return pathTarget{}, false, errors.New("path segment is not an object: " + segment.key)
+		}
+		if literal := p.literals[depth]; literal != "" {
+			if _, exists := object[literal]; exists {
+				return pathTarget{object: object, key: literal}, true, nil
+			}
+		}
+		if depth == len(p.segments)-1 {
+			return pathTarget{object: object, key: segment.key}, true, nil
+		}
+		next, exists := object[segment.key]
+		if !exists {
+			if !create {
+				return pathTarget{}, false, nil
+			}
+			next = map[string]any{}
+			object[segment.key] = next
+		}
+		current = next
+	}
+	return pathTarget{}, false, nil
+}
+
+func (p Path) resolve(current any, holder *pathTarget, depth int, create bool, targets *[]pathTarget) error {
+	segment := p.segments[depth]
+	descend := func(target pathTarget, next any) error {
+		if depth == len(p.segments)-1 {
+			*targets = append(*targets, target)
+			return nil
+		}
+		return p.resolve(next, &target, depth+1, create, targets)
+	}
+	switch segment.kind {
+	case segmentIndex:
+		items, ok := current.([]any)
+		if !ok {
+			if current == nil {
+				return nil
+			}
+			return errors.New("path segment is not an array: " + formatPath([]pathSegment{segment}))
+		}
+		if segment.index >= len(items) {
+			return nil
+		}
+		return descend(pathTarget{holder: holder, index: segment.index}, items[segment.index])
+	case segmentWildcard:
+		if items, ok := current.([]any); ok {
+			for index, item := range items {
+				if err := descend(pathTarget{holder: holder, index: index}, item); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		if object, ok := mapFromValue(current); ok {
+			keys := make([]string, 0, len(object))
+			for key := range object {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				if err := descend(pathTarget{object: object, key: key}, object[key]); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		if current == nil {
+			return nil
+		}
+		return errors.New("path segment is not an array or object: [*]")
+	}
+	object, ok := mapFromValue(current)
+	if !ok {
+		return errors.New("path segment is not an object: " + segment.key)
+	}
+	if literal := p.literals[depth]; literal != "" {
+		if _, exists := object[literal]; exists {
+			*targets = append(*targets, pathTarget{object: object, key: literal})
+			return nil
+		}
+	}
+	target := pathTarget{object: object, key: segment.key}
+	next, exists := object[segment.key]
+	if !exists && depth < len(p.segments)-1 {
+		if !create || depth < p.lastIndexed {
+			return nil
+		}
+		next = map[string]any{}
+		object[segment.key] = next
+	}
+	return descend(target, next)
+}
+
+// Value retrieves the value at p and reports whether it exists. A path with
+// a wildcard has no single value; use Values.
+func (p Path) Value(record Record) (any, bool, error) {
+	if p.wildcard {
+		return nil, false, errors.New("path with a wildcard has many values: " + p.text)
+	}
+	if len(p.segments) == 0 {
 		return nil, false, errors.New("path is empty")
 	}
+	// Without a wildcard there is at most one match, so p is walked
+	// directly rather than collecting targets.
 	current := any(record)
-	for index, segment := range segments {
-		currentMap, ok := mapFromValue(current)
+	for depth, segment := range p.segments {
+		if segment.kind == segmentIndex {
+			items, ok := current.([]any)
+			if !ok {
+				if current == nil {
+					return nil, false, nil
+				}
+				return nil, false, errors.New("path segment is not an array: " + formatPath([]pathSegment{segment}))
+			}
+			if segment.index >= len(items) {
+				return nil, false, nil
+			}
+			current = items[segment.index]
+			continue
+		}
+		object, ok := mapFromValue(current)
 		if !ok {
-			return nil, false, errors.New("path segment is not an object: " + segment)
+			return nil, false, errors.New("path segment is not an object: " + segment.key)
 		}
-		remainingPath := strings.Join(segments[index:], ".")
-		if value, exists := currentMap[remainingPath]; exists {
-			return value, true, nil
+		if literal := p.literals[depth]; literal != "" {
+			if value, exists := object[literal]; exists {
+				return value, true, nil
+			}
 		}
-		value, exists := currentMap[segment]
+		value, exists := object[segment.key]
 		if !exists {
 			return nil, false, nil
 		}
@@ -37,69 +345,262 @@ func getValueAtPath(record Record, path string) (any, bool, error) {
 	return current, true, nil
 }
 
-// ValueAtPath retrieves a value using dot notation paths.
-func ValueAtPath(record Record, path string) (any, bool, error) {
-	return getValueAtPath(record, path)
+// Values returns the values p matches in record, in order.
+func (p Path) Values(record Record) ([]any, error) {
+	targets, err := p.targets(record, false)
+	if err != nil {
+		return nil, err
+	}
+	values := []any{}
+	for _, target := range targets {
+		if value, exists := target.value(); exists {
+			values = append(values, value)
+		}
+	}
+	return values, nil
 }
 
-func setValueAtPath(record Record, path string, value any) error {
-	segments := splitPath(path)
-	if len(segments) == 0 {
-		return errors.New("path is empty")
+// Set stores value at every match of p, creating objects as needed. An
+// index past the end of an array is an error.
+func (p Path) Set(record Record, value any) error {
+	targets, err := p.targets(record, true)
+	if err != nil {
+		return err
 	}
-	current := map[string]any(record)
-	for index, segment := range segments {
-		remainingPath := strings.Join(segments[index:], ".")
-		if _, exists := current[remainingPath]; exists {
-			current[remainingPath] = value
-			return nil
+	if len(targets) == 0 && !p.wildcard {
+		return errors.New("path index is out of range: " + p.text)
+	}
+	for index, target := range targets {
+		if index > 0 {
+			value = deepCopyValue(value)
 		}
-		if index == len(segments)-1 {
-			current[segment] = value
-			return nil
+		target.set(value)
+	}
+	return nil
+}
+
+// remove deletes the values at p and returns them in order. Removing an
+// array item shifts the items after it.
+func (p Path) remove(record Record) ([]any, error) {
+	targets, err := p.targets(record, false)
+	if err != nil {
+		return nil, err
+	}
+	removed := []any{}
+	for _, target := range targets {
+		if value, exists := target.value(); exists {
+			removed = append(removed, value)
 		}
-		next, ok := current[segment]
-		if !ok {
-			nextMap := map[string]any{}
-			current[segment] = nextMap
-			current = nextMap
+	}
+	// Later items of an array are removed first so earlier indices hold.
+	for index := len(targets) - 1; index >= 0; index-- {
+		if _, exists := targets[index].value(); exists {
+			targets[index].remove()
+		}
+	}
+	return removed, nil
+}
+
+// update replaces each non-null value p matches in record with the value
+// change returns for it.
+func (p Path) update(record Record, change func(value any) (any, error)) error {
+	targets, err := p.targets(record, false)
+	if err != nil {
+		return err
+	}
+	for _, target := range targets {
+		value, exists := target.value()
+		if !exists || value == nil {
 			continue
 		}
-		nextMap, ok := mapFromValue(next)
-		if !ok {
-			return errors.New("path segment is not an object: " + segment)
+		updated, err := change(value)
+		if err != nil {
+			return err
 		}
-		current = nextMap
+		target.set(updated)
 	}
 	return nil
 }
 
-func deleteValueAtPath(record Record, path string) error {
-	segments := splitPath(path)
-	if len(segments) == 0 {
-		return errors.New("path is empty")
+// ValueAtPath retrieves a value using the path syntax of ParsePath. Callers
+// resolving the same path against many records should parse it once.
+func ValueAtPath(record Record, path string) (any, bool, error) {
+	parsed, err := ParsePath(path)
+	if err != nil {
+		return nil, false, err
+	}
+	return parsed.Value(record)
+}
+
+// ValuesAtPath returns every value a path, usually one with a wildcard,
+// matches in record.
+func ValuesAtPath(record Record, path string) ([]any, error) {
+	parsed, err := ParsePath(path)
+	if err != nil {
+		return nil, err
 	}
-	current := map[string]any(record)
-	for index, segment := range segments {
-		if index == len(segments)-1 {
-			delete(current, segment)
-			return nil
+	return parsed.Values(record)
+}
+
+// SetValueAtPath stores a value using the path syntax of ParsePath, creating
+// objects as needed.
+func SetValueAtPath(record Record, path string, value any) error {
+	parsed, err := ParsePath(path)
+	if err != nil {
+		return err
+	}
+	return parsed.Set(record, value)
+}
+
+// DeleteValueAtPath removes the values a path matches and returns them.
+func DeleteValueAtPath(record Record, path string) ([]any, error) {
+	parsed, err := ParsePath(path)
+	if err != nil {
+		return nil, err
+	}
+	return parsed.remove(record)
+}
+
+// pathMove moves values from one parsed path to another. When from has a
+// wildcard, scope is the part both paths share up to its last wildcard, and
+// from and to are relative to each object scope matches.
+type pathMove struct {
+	scope *Path
+	from  Path
+	to    Path
+	// target is the whole to path, for messages.
+	target string
+	// parents are the objects enclosing from, deepest first, pruned when the
+	// move leaves them empty.
+	parents []Path
+}
+
+func newPathMove(from string, to string) (pathMove, error) {
+	fromPath, err := ParsePath(from)
+	if err != nil {
+		return pathMove{}, err
+	}
+	toPath, err := ParsePath(to)
+	if err != nil {
+		return pathMove{}, err
+	}
+	move := pathMove{from: fromPath, to: toPath, target: toPath.String()}
+	if fromPath.wildcard {
+		last := 0
+		for index, segment := range fromPath.segments {
+			if segment.kind == segmentWildcard {
+				last = index
+			}
 		}
-		next, ok := current[segment]
-		if !ok {
-			return nil
+		if last == len(fromPath.segments)-1 {
+			return pathMove{}, errors.New("rename path must not end with a wildcard: " + fromPath.String())
 		}
-		nextMap, ok := mapFromValue(next)
+		if len(toPath.segments) <= last+1 || formatPath(toPath.segments[:last+1]) != formatPath(fromPath.segments[:last+1]) {
+			return pathMove{}, errors.New("rename to must keep the wildcards of path: " + fromPath.String() + " -> " + toPath.String())
+		}
+		scope := newPath(fromPath.segments[:last+1])
+		move.scope = &scope
+		move.from = newPath(fromPath.segments[last+1:])
+		move.to = newPath(toPath.segments[last+1:])
+	}
+	if move.to.wildcard {
+		return pathMove{}, errors.New("rename to must keep the wildcards of path: " + fromPath.String() + " -> " + toPath.String())
+	}
+	for _, parent := range move.from.ancestors() {
+		if parent.segments[len(parent.segments)-1].kind == segmentKey {
+			move.parents = append(move.parents, parent)
+		}
+	}
+	return move, nil
+}
+
+// apply moves the value at from to to, in record or in every object scope
+// matches, pruning objects the move leaves empty. Each value replaced at the
+// target is added to overwritten with the value stored over it.
+func (m pathMove) apply(record Record, overwrite bool, overwritten *pathChanges) error {
+	if m.scope == nil {
+		return m.applyTo(record, overwrite, overwritten)
+	}
+	scopes, err := m.scope.Values(record)
+	if err != nil {
+		return err
+	}
+	for _, scope := range scopes {
+		if scope == nil {
+			continue
+		}
+		object, ok := mapFromValue(scope)
 		if !ok {
-			return errors.New("path segment is not an object: " + segment)
+			return errors.New("path segment is not an object: " + m.from.segments[0].key)
+		}
+		if err := m.applyTo(Record(object), overwrite, overwritten); err != nil {
+			return err
 		}
-		current = nextMap
 	}
 	return nil
 }
 
-func flattenAtPath(record Record, path string) error {
-	value, exists, err := getValueAtPath(record, path)
+func (m pathMove) applyTo(record Record, overwrite bool, overwritten *pathChanges) error {
+	value, exists, err := m.from.Value(record)
+	if err != nil || !exists {
+		return err
+	}
+	replaced, collided, err := m.to.Value(record)
+	if err != nil {
+		return err
+	}
+	if collided && !overwrite {
+		return errors.New("rename target already exists: " + m.target)
+	}
+	parents := populatedParents(record, m.parents)
+	if _, err := m.from.remove(record); err != nil {
+		return err
+	}
+	pruneEmptiedParents(record, parents)
+	if err := m.to.Set(record, value); err != nil {
+		return err
+	}
+	if collided {
+		overwritten.add(replaced, value)
+	}
+	return nil
+}
+
+// populatedParents lists the ancestors holding non-empty objects, keeping
+// their deepest-first order.
+func populatedParents(record Record, ancestors []Path) []Path {
+	parents := []Path{}
+	for _, parentPath := range ancestors {
+		parent, exists, err := parentPath.Value(record)
+		if err != nil || !exists {
+			continue
+		}
+		if parentMap, ok := mapFromValue(parent); ok && len(parentMap) > 0 {
+			parents = append(parents, parentPath)
+		}
+	}
+	return parents
+}
+
+// pruneEmptiedParents deletes parents that a removal left empty.
+func pruneEmptiedParents(record Record, parents []Path) {
+	for _, parentPath := range parents {
+		parent, exists, err := parentPath.Value(record)
+		if err != nil || !exists {
+			return
+		}
+		parentMap, ok := mapFromValue(parent)
+		if !ok || len(parentMap) > 0 {
+			return
+		}
+		if _, err := parentPath.remove(record); err != nil {
+			return
+		}
+	}
+}
+
+func flattenAtPath(record Record, path Path) error {
+	value, exists, err := path.Value(record)
 	if err != nil {
 		return err
 	}
@@ -108,13 +609,91 @@ func flattenAtPath(record Record, path string) error {
 	}
 	objectValue, ok := mapFromValue(value)
 	if !ok {
-		return errors.New("flatten target is not an object: " + path)
+		return errors.New("flatten target is not an object: " + path.String())
 	}
 	for key, nestedValue := range objectValue {
-		flatKey := path + "." + key
+		flatKey := path.flatKey() + "." + key
 		record[flatKey] = nestedValue
 	}
-	return deleteValueAtPath(record, path)
+	_, err = path.remove(record)
+	return err
+}
+
+// unflattenAtPath regroups top-level "path.key" entries into an object at
+// path. regrouped holds the paths the plan unflattens, whose dotted keys are
+// collected into parents this call creates.
+// It is the inverse of flattenAtPath and nests exactly one level.
+func unflattenAtPath(record Record, path Path, regrouped map[string]struct{}) error {
+	prefix := path.flatKey() + "."
+	keys := []string{}
+	for key := range record {
+		if strings.HasPrefix(key, prefix) && len(key) > len(prefix) {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	sort.Strings(keys)
+
+	existing, exists, err := path.Value(record)
+	if err != nil {
+		return err
+	}
+	target := map[string]any{}
+	if exists && existing != nil {
+		existingMap, ok := mapFromValue(existing)
+		if !ok {
+			return errors.New("unflatten collides with non-object value at path: " + path.String())
+		}
+		target = existingMap
+	}
+	for _, key := range keys {
+		nestedKey := strings.TrimPrefix(key, prefix)
+		if _, collision := target[nestedKey]; collision {
+			return errors.New("unflatten collides with existing nested field: " + key)
+		}
+	}
+	if !exists || existing == nil {
+		if err := checkCreatedParents(record, path, regrouped); err != nil {
+			return err
+		}
+	}
+	for _, key := range keys {
+		target[strings.TrimPrefix(key, prefix)] = record[key]
+		delete(record, key)
+	}
+	if !exists || existing == nil {
+		return path.Set(record, target)
+	}
+	return nil
+}
+
+// checkCreatedParents rejects unflattening path when it would create a
+// parent object beside flattened keys of that parent that no unflatten in
+// regrouped collects, which would leave both a nested and a literal form of
+// the same fields.
+func checkCreatedParents(record Record, path Path, regrouped map[string]struct{}) error {
+	prefix := path.flatKey() + "."
+	for _, parent := range path.ancestors() {
+		if _, listed := regrouped[parent.String()]; listed {
+			continue
+		}
+		_, exists, err := parent.Value(record)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+		parentPrefix := parent.flatKey() + "."
+		for key := range record {
+			if strings.HasPrefix(key, parentPrefix) && !strings.HasPrefix(key, prefix) {
+				return errors.New("unflatten collides with flattened field of parent: " + key)
+			}
+		}
+	}
+	return nil
 }
 
 func mapFromValue(value any) (map[string]any, bool) {
@@ -0,0 +1,237 @@
+package core
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// pathSpecialCharacters cannot appear in a bare key.
+const pathSpecialCharacters = `.[]"\`
+
+// ParsePath parses a path: keys separated by dots, such as user.address.city.
+// A key containing dots, brackets, quotes, or backslashes is written in
+// brackets as a double-quoted string, with \" and \\ as the only escapes:
+// domains["example.com"].owner. An index in brackets selects an array item,
+// as in contacts[0], and a wildcard, [*] or a bare *, selects every item of an
+// array or value of an object, as in items[*].price. Surrounding whitespace
+// is ignored.
+//
+// Bare keys keep the literal-key preference described on Path, so a.b also
+// finds a flattened key "a.b"; a path with a quoted key never matches a
+// flattened key spanning it.
+func ParsePath(text string) (Path, error) {
+	cleaned := strings.TrimSpace(text)
+	if cleaned == "" {
+		return Path{}, errors.New("path is empty")
+	}
+	segments := []pathSegment{}
+	offset := 0
+	for {
+		if cleaned[offset] == '[' {
+			segment, next, err := parseBracket(cleaned, offset)
+			if err != nil {
+				return Path{}, err
+			}
+			segments = append(segments, segment)
+			offset = next
+		} else {
+			start := offset
+			for offset < len(cleaned) && !strings.ContainsRune(pathSpecialCharacters, rune(cleaned[offset])) {
+				offset++
+			}
+			if offset == start {
+				return Path{}, pathSyntaxError(cleaned, offset)
+			}
+			if key := cleaned[start:offset]; key == "*" {
+				segments = append(segments, pathSegment{kind: segmentWildcard})
+			} else {
+				segments = append(segments, pathSegment{key: key})
+			}
+		}
+		if offset == len(cleaned) {
+			return newPath(segments), nil
+		}
+		switch cleaned[offset] {
+		case '.':
+			offset++
+			if offset == len(cleaned) || cleaned[offset] == '[' {
+				return Path{}, errors.New("path key is empty at offset " + strconv.Itoa(offset) + ": " + cleaned)
+			}
+		case '[':
+		default:
+			if segments[len(segments)-1].bracketed() {
+				return Path{}, errors.New("path expects '.' or '[' after ']' at offset " + strconv.Itoa(offset) + ": " + cleaned)
+			}
+			return Path{}, pathSyntaxError(cleaned, offset)
+		}
+	}
+}
+
+// pathSyntaxError describes the character at offset where a bare key was
+// expected.
+func pathSyntaxError(path string, offset int) error {
+	position := " at offset " + strconv.Itoa(offset) + ": " + path
+	switch path[offset] {
+	case '.':
+		return errors.New("path key is empty" + position)
+	case ']':
+		return errors.New("path has ']' without '['" + position)
+	default:
+		return errors.New("path key with " + string(path[offset]) + " must be quoted in brackets" + position)
+	}
+}
+
+// parseBracket parses the bracketed segment starting at offset and returns
+// it with the offset that follows the closing bracket.
+func parseBracket(path string, offset int) (pathSegment, int, error) {
+	if offset+1 < len(path) && path[offset+1] == '"' {
+		key, next, err := parseQuotedKey(path, offset)
+		return pathSegment{key: key, quoted: true}, next, err
+	}
+	end := strings.IndexByte(path[offset:], ']')
+	if end > 1 {
+		content := path[offset+1 : offset+end]
+		if content == "*" {
+			return pathSegment{kind: segmentWildcard}, offset + end + 1, nil
+		}
+		if strings.Trim(content, "0123456789") == "" {
+			if index, err := strconv.Atoi(content); err == nil {
+				return pathSegment{kind: segmentIndex, index: index}, offset + end + 1, nil
+			}
+		}
+	}
+	return pathSegment{}, 0, errors.New("path bracket must hold a quoted key, an index, or * at offset " + strconv.Itoa(offset) + ": " + path)
+}
+
+// parseQuotedKey parses the quoted key in the bracket starting at offset and
+// returns it with the offset that follows the closing bracket.
+func parseQuotedKey(path string, offset int) (string, int, error) {
+	var key strings.Builder
+	for index := offset + 2; index < len(path); index++ {
+		switch path[index] {
+		case '\\':
+			if index+1 >= len(path) || (path[index+1] != '"' && path[index+1] != '\\') {
+				return "", 0, errors.New(`path escape must be \" or \\ at offset ` + strconv.Itoa(index) + ": " + path)
+			}
+			index++
+			key.WriteByte(path[index])
+		case '"':
+			if index+1 >= len(path) || path[index+1] != ']' {
+				return "", 0, errors.New("path bracket is not closed at offset " + strconv.Itoa(index+1) + ": " + path)
+			}
+			return key.String(), index + 2, nil
+		default:
+			key.WriteByte(path[index])
+		}
+	}
+	return "", 0, errors.New("path quoted key is not closed: " + path)
+}
+
+// formatPath prints segments in canonical form: bare keys joined by dots,
+// and quoted keys, indices, and wildcards in brackets.
+func formatPath(segments []pathSegment) string {
+	var builder strings.Builder
+	for index, segment := range segments {
+		switch {
+		case segment.kind == segmentIndex:
+			builder.WriteString("[" + strconv.Itoa(segment.index) + "]")
+			continue
+		case segment.kind == segmentWildcard:
+			builder.WriteString("[*]")
+			continue
+		case segment.quoted:
+			builder.WriteString(quotePathKey(segment.key))
+			continue
+		}
+		if index > 0 {
+			builder.WriteByte('.')
+		}
+		builder.WriteString(segment.key)
+	}
+	return builder.String()
+}
+
+func quotePathKey(key string) string {
+	escaped := strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(key)
+	return `["` + escaped + `"]`
+}
+
+// AppendPathKey returns the path of key in the object at path, in canonical
+// form. Keys a bare key cannot hold are quoted. A key containing dots stays
+// bare when no fields are nested under it, since bare keys find the literal
+// rest of the path, so flattened keys such as user.name keep their name; it
+// is quoted when nested is set.
+func AppendPathKey(path string, key string, nested bool) string {
+	if !bareKeyAllowed(key, nested) {
+		return path + quotePathKey(key)
+	}
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// ChildPath returns the path of key in the object at path when key holds
+// value, as BuildShapeFromRecords names it.
+func ChildPath(path string, key string, value any) string {
+	return AppendPathKey(path, key, holdsFields(value))
+}
+
+// holdsFields reports whether value has fields nested under its path: an
+// object, or an array holding one.
+func holdsFields(value any) bool {
+	if _, ok := mapFromValue(value); ok {
+		return true
+	}
+	items, ok := value.([]any)
+	if !ok {
+		return false
+	}
+	for _, item := range items {
+		if holdsFields(item) {
+			return true
+		}
+	}
+	return false
+}
+
+func bareKeyAllowed(key string, nested bool) bool {
+	if key == "" || key == "*" || strings.TrimSpace(key) != key || strings.ContainsAny(key, `[]"\`) {
+		return false
+	}
+	if !strings.Contains(key, ".") {
+		return true
+	}
+	if nested {
+		return false
+	}
+	for _, part := range strings.Split(key, ".") {
+		if part == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// PathWithin reports whether path is ancestor or nested under it. Both must
+// be in canonical form.
+func PathWithin(path string, ancestor string) bool {
+	return path == ancestor ||
+		strings.HasPrefix(path, ancestor+".") ||
+		strings.HasPrefix(path, ancestor+"[")
+}
+
+// JoinPaths appends child, a path relative to parent, to parent.
+func JoinPaths(parent string, child string) string {
+	switch {
+	case parent == "":
+		return child
+	case child == "":
+		return parent
+	case child[0] == '[':
+		return parent + child
+	default:
+		return parent + "." + child
+	}
+}
@@ -12,9 +12,27 @@ const (
 type Strategy string
 
 const (
-	StrategyJoinArray Strategy = "join_array"
-	StrategyDropField Strategy = "drop_field"
-	StrategyCoerceType Strategy = "coerce_type"
+	StrategyJoinArray   Strategy = "join_array"
+	StrategyDropField   Strategy = "drop_field"
+	StrategyCoerceType  Strategy = "coerce_type"
+	StrategySplitString Strategy = "split_string"
+	// StrategyJoinCollision acknowledges that joined elements containing the
+	// delimiter become indistinguishable from separate elements.
+	StrategyJoinCollision Strategy = "join_collision"
+	// StrategyOverwriteField acknowledges that a rename replaces an existing value.
+	StrategyOverwriteField Strategy = "overwrite_field"
+	// StrategyNarrowNumber acknowledges that converting an exact number to a
+	// floating-point number changes its value.
+	StrategyNarrowNumber Strategy = "narrow_number"
+	// StrategyTruncateTime acknowledges that coercing a datetime to a date
+	// drops its time of day and zone.
+	StrategyTruncateTime Strategy = "truncate_time"
+	// StrategyNullInvalid acknowledges that values violating a constraint
+	// are replaced with null.
+	StrategyNullInvalid Strategy = "null_invalid"
+	// StrategyDropRecord acknowledges that records violating a constraint
+	// are removed.
+	StrategyDropRecord Strategy = "drop_record"
 )
 
 // LossyDecision records explicit approval for a lossy action.
@@ -24,16 +42,150 @@ type LossyDecision struct {
 	Strategy  Strategy   `json:"strategy"`
 }
 
+// DelimiterCollision describes how array elements containing the join
+// delimiter are encoded.
+type DelimiterCollision string
+
+const (
+	// DelimiterCollisionError fails the transform. An empty value behaves the same.
+	DelimiterCollisionError DelimiterCollision = "error"
+	// DelimiterCollisionEscape prefixes delimiters and escape characters with EscapeChar.
+	DelimiterCollisionEscape DelimiterCollision = "escape"
+	// DelimiterCollisionQuote wraps elements containing the delimiter or a
+	// double quote in double quotes, doubling inner quotes.
+	DelimiterCollisionQuote DelimiterCollision = "quote"
+	// DelimiterCollisionAcknowledge joins as-is and requires a join_collision
+	// lossy decision.
+	DelimiterCollisionAcknowledge DelimiterCollision = "acknowledge"
+)
+
 // JoinArrayRule defines how to join arrays.
 type JoinArrayRule struct {
-	Path      string `json:"path"`
-	Delimiter string `json:"delimiter"`
+	Path        string             `json:"path"`
+	Delimiter   string             `json:"delimiter"`
+	OnCollision DelimiterCollision `json:"on_collision,omitempty"`
+	EscapeChar  string             `json:"escape_char,omitempty"`
 }
 
-// TypeCoercionRule defines type coercion for a field.
+// SplitStringRule defines how to split a delimited string into an array.
+// Trim removes surrounding whitespace from each part and ElementType coerces
+// each part; either makes the split lossy and requires a split_string
+// lossy decision. Escaping decodes parts written by a join rule using the
+// escape or quote collision strategy.
+type SplitStringRule struct {
+	Path        string             `json:"path"`
+	Delimiter   string             `json:"delimiter"`
+	Trim        bool               `json:"trim,omitempty"`
+	ElementType LogicalType        `json:"element_type,omitempty"`
+	Escaping    DelimiterCollision `json:"escaping,omitempty"`
+	EscapeChar  string             `json:"escape_char,omitempty"`
+}
+
+// IsLossy reports whether applying the rule can lose information.
+func (r SplitStringRule) IsLossy() bool {
+	return r.Trim || (r.ElementType != "" && r.ElementType != LogicalTypeString)
+}
+
+// TypeCoercionRule defines type coercion for a field. Layouts lists the Go
+// time layouts tried for date and datetime targets, and Timezone names the
+// IANA zone for datetime values without an offset (UTC by default).
 type TypeCoercionRule struct {
 	Path       string      `json:"path"`
 	TargetType LogicalType `json:"target_type"`
+	Layouts    []string    `json:"layouts,omitempty"`
+	Timezone   string      `json:"timezone,omitempty"`
+}
+
+// RenameConflict describes how a rename handles an existing target value.
+type RenameConflict string
+
+const (
+	// RenameConflictError fails the transform. An empty value behaves the same.
+	RenameConflictError RenameConflict = "error"
+	// RenameConflictOverwrite replaces the target value and requires an
+	// overwrite_field lossy decision for the target path.
+	RenameConflictOverwrite RenameConflict = "overwrite"
+)
+
+// RenameFieldRule moves the value at Path to To.
+type RenameFieldRule struct {
+	Path       string         `json:"path"`
+	To         string         `json:"to"`
+	OnConflict RenameConflict `json:"on_conflict,omitempty"`
+}
+
+// ConstraintRule checks the values at Path against constraints after the
+// plan's other rules have run.
+type ConstraintRule struct {
+	Path string `json:"path"`
+	FieldConstraints
+}
+
+// OutputField pins one output field. Header, when set, labels the field in
+// the output instead of its path.
+type OutputField struct {
+	Path   string `json:"path"`
+	Header string `json:"header,omitempty"`
+}
+
+// UnlistedFields describes how fields missing from OutputFields are handled.
+type UnlistedFields string
+
+const (
+	// UnlistedFieldsError fails the transform. An empty value behaves the same.
+	UnlistedFieldsError UnlistedFields = "error"
+	// UnlistedFieldsAppend keeps unlisted fields after the listed ones, sorted by path.
+	UnlistedFieldsAppend UnlistedFields = "append"
+	// UnlistedFieldsDrop removes unlisted fields; each dropped path requires a
+	// drop_field lossy decision.
+	UnlistedFieldsDrop UnlistedFields = "drop"
+)
+
+// FieldOrder describes how output fields are ordered.
+type FieldOrder string
+
+const (
+	// FieldOrderSorted orders fields by path. An empty value behaves the same.
+	FieldOrderSorted FieldOrder = "sorted"
+	// FieldOrderInput keeps the order fields appeared in the input. Renamed
+	// fields keep the position of their source path; new fields follow,
+	// sorted by path.
+	FieldOrderInput FieldOrder = "input"
+)
+
+// StreamShape describes how a streaming plan learns the shape of its output.
+type StreamShape string
+
+const (
+	// StreamShapeNone streams without a shape, so plan rules and output
+	// formats that need one fail. An empty value behaves the same.
+	StreamShapeNone StreamShape = "none"
+	// StreamShapeDeclared uses StreamingOptions.DeclaredShape as the shape
+	// of the records after the plan's rules.
+	StreamShapeDeclared StreamShape = "declared"
+	// StreamShapeTwoPass reads the input twice: the first pass runs the plan
+	// and measures the shape without keeping records.
+	StreamShapeTwoPass StreamShape = "two_pass"
+)
+
+// StreamingOptions runs a plan one record at a time instead of holding all
+// records in memory. TransformData validates but otherwise ignores them.
+type StreamingOptions struct {
+	Shape         StreamShape `json:"shape,omitempty"`
+	DeclaredShape *DataShape  `json:"declared_shape,omitempty"`
+}
+
+// DefaultParallelChunkSize is the number of records a worker takes at a time
+// when ParallelOptions.ChunkSize is zero.
+const DefaultParallelChunkSize = 1024
+
+// ParallelOptions spreads the per-record steps of TransformData over worker
+// goroutines. Records are processed in chunks and reassembled in order, so
+// records, warnings, and errors are identical to a sequential run. Streaming
+// runs ignore them.
+type ParallelOptions struct {
+	Workers   int `json:"workers"`
+	ChunkSize int `json:"chunk_size,omitempty"`
 }
 
 // DefaultValueRule defines a default value for a field.
@@ -45,10 +197,21 @@ type DefaultValueRule struct {
 // ConversionPlan defines explicit transformation decisions.
 type ConversionPlan struct {
 	FlattenFields   []string           `json:"flatten_fields,omitempty"`
+	UnflattenFields []string           `json:"unflatten_fields,omitempty"`
 	JoinArrays      []JoinArrayRule    `json:"join_arrays,omitempty"`
+	SplitStrings    []SplitStringRule  `json:"split_strings,omitempty"`
 	ExplodeArrays   []string           `json:"explode_arrays,omitempty"`
 	TypeCoercions   []TypeCoercionRule `json:"type_coercions,omitempty"`
 	DefaultValues   []DefaultValueRule `json:"default_values,omitempty"`
 	DropFields      []string           `json:"drop_fields,omitempty"`
+	RenameFields    []RenameFieldRule  `json:"rename_fields,omitempty"`
+	Constraints     []ConstraintRule   `json:"constraints,omitempty"`
+	FieldOrder      FieldOrder         `json:"field_order,omitempty"`
+	OutputFields    []OutputField      `json:"output_fields,omitempty"`
+	UnlistedFields  UnlistedFields     `json:"unlisted_fields,omitempty"`
+	Steps           []PlanStep         `json:"steps,omitempty"`
 	LossyDecisions  []LossyDecision    `json:"lossy_decisions,omitempty"`
+	LossBudget      *LossBudget        `json:"loss_budget,omitempty"`
+	Streaming       *StreamingOptions  `json:"streaming,omitempty"`
+	Parallel        *ParallelOptions   `json:"parallel,omitempty"`
 }
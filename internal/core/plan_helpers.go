@@ -2,17 +2,24 @@ package core
 
 import (
 	"errors"
+	"fmt"
 	"sort"
+	"strings"
 )
 
 // NormalizePlan sorts plan slices for deterministic application.
+// Steps keep their declared order.
 func NormalizePlan(plan ConversionPlan) ConversionPlan {
 	sort.Strings(plan.FlattenFields)
+	sort.Strings(plan.UnflattenFields)
 	sort.Strings(plan.ExplodeArrays)
 	sort.Strings(plan.DropFields)
 	sort.Slice(plan.JoinArrays, func(i, j int) bool { return plan.JoinArrays[i].Path < plan.JoinArrays[j].Path })
+	sort.Slice(plan.SplitStrings, func(i, j int) bool { return plan.SplitStrings[i].Path < plan.SplitStrings[j].Path })
 	sort.Slice(plan.TypeCoercions, func(i, j int) bool { return plan.TypeCoercions[i].Path < plan.TypeCoercions[j].Path })
 	sort.Slice(plan.DefaultValues, func(i, j int) bool { return plan.DefaultValues[i].Path < plan.DefaultValues[j].Path })
+	sort.Slice(plan.RenameFields, func(i, j int) bool { return plan.RenameFields[i].Path < plan.RenameFields[j].Path })
+	sort.SliceStable(plan.Constraints, func(i, j int) bool { return plan.Constraints[i].Path < plan.Constraints[j].Path })
 	sort.Slice(plan.LossyDecisions, func(i, j int) bool {
 		if plan.LossyDecisions[i].Strategy == plan.LossyDecisions[j].Strategy {
 			return plan.LossyDecisions[i].FieldPath < plan.LossyDecisions[j].FieldPath
@@ -35,6 +42,24 @@ func ValidateLossyDecisions(plan ConversionPlan) error {
 			return errors.New("join_arrays requires lossy_decisions entry for path: " + rule.Path)
 		}
 	}
+	for _, rule := range plan.JoinArrays {
+		if rule.OnCollision != DelimiterCollisionAcknowledge {
+			continue
+		}
+		key := string(StrategyJoinCollision) + ":" + rule.Path
+		if _, ok := lossyMap[key]; !ok {
+			return errors.New("join_arrays on_collision acknowledge requires join_collision lossy_decisions entry for path: " + rule.Path)
+		}
+	}
+	for _, rule := range plan.SplitStrings {
+		if !rule.IsLossy() {
+			continue
+		}
+		key := string(StrategySplitString) + ":" + rule.Path
+		if _, ok := lossyMap[key]; !ok {
+			return errors.New("split_strings with trim or element_type requires lossy_decisions entry for path: " + rule.Path)
+		}
+	}
 	for _, rule := range plan.TypeCoercions {
 		key := string(StrategyCoerceType) + ":" + rule.Path
 		if _, ok := lossyMap[key]; !ok {
@@ -47,5 +72,117 @@ func ValidateLossyDecisions(plan ConversionPlan) error {
 			return errors.New("drop_fields requires lossy_decisions entry for path: " + path)
 		}
 	}
+	for _, rule := range plan.RenameFields {
+		if rule.OnConflict != RenameConflictOverwrite {
+			continue
+		}
+		key := string(StrategyOverwriteField) + ":" + rule.To
+		if _, ok := lossyMap[key]; !ok {
+			return errors.New("rename_fields on_conflict overwrite requires overwrite_field lossy_decisions entry for path: " + rule.To)
+		}
+	}
+	for _, rule := range plan.Constraints {
+		strategy := Strategy("")
+		switch rule.OnViolation {
+		case ConstraintPolicyNull:
+			strategy = StrategyNullInvalid
+		case ConstraintPolicyDropRecord:
+			strategy = StrategyDropRecord
+		default:
+			continue
+		}
+		if _, ok := lossyMap[string(strategy)+":"+rule.Path]; !ok {
+			return errors.New("constraints on_violation " + string(rule.OnViolation) + " requires " + string(strategy) + " lossy_decisions entry for path: " + rule.Path)
+		}
+	}
+	for index, step := range plan.Steps {
+		strategy, lossy := step.lossyStrategy()
+		if !lossy {
+			continue
+		}
+		path := step.Path
+		if step.Operation == StepRenameField {
+			path = step.To
+		}
+		if _, ok := lossyMap[string(strategy)+":"+path]; !ok {
+			return fmt.Errorf("steps[%d] %s requires lossy_decisions entry for path: %s", index, step.Operation, path)
+		}
+	}
+	for index, step := range plan.Steps {
+		if step.Operation != StepJoinArray || step.OnCollision != DelimiterCollisionAcknowledge {
+			continue
+		}
+		if _, ok := lossyMap[string(StrategyJoinCollision)+":"+step.Path]; !ok {
+			return fmt.Errorf("steps[%d] join_array on_collision acknowledge requires join_collision lossy_decisions entry for path: %s", index, step.Path)
+		}
+	}
 	return nil
 }
+
+// canonicalizePlanPaths returns a copy of plan with every path rewritten in
+// canonical form, so paths written differently compare equal when rules and
+// lossy decisions are matched. Empty paths are left for the rules to reject.
+func canonicalizePlanPaths(plan ConversionPlan) (ConversionPlan, error) {
+	var err error
+	canonical := func(field string, path *string) {
+		if err != nil || strings.TrimSpace(*path) == "" {
+			return
+		}
+		parsed, parseErr := ParsePath(*path)
+		if parseErr != nil {
+			err = errors.New(field + ": " + parseErr.Error())
+			return
+		}
+		*path = parsed.String()
+	}
+	canonicalAll := func(field string, paths []string) []string {
+		copied := append([]string(nil), paths...)
+		for index := range copied {
+			canonical(field, &copied[index])
+		}
+		return copied
+	}
+	plan.FlattenFields = canonicalAll("flatten_fields", plan.FlattenFields)
+	plan.UnflattenFields = canonicalAll("unflatten_fields", plan.UnflattenFields)
+	plan.ExplodeArrays = canonicalAll("explode_arrays", plan.ExplodeArrays)
+	plan.DropFields = canonicalAll("drop_fields", plan.DropFields)
+	plan.JoinArrays = append([]JoinArrayRule(nil), plan.JoinArrays...)
+	for index := range plan.JoinArrays {
+		canonical("join_arrays", &plan.JoinArrays[index].Path)
+	}
+	plan.SplitStrings = append([]SplitStringRule(nil), plan.SplitStrings...)
+	for index := range plan.SplitStrings {
+		canonical("split_strings", &plan.SplitStrings[index].Path)
+	}
+	plan.TypeCoercions = append([]TypeCoercionRule(nil), plan.TypeCoercions...)
+	for index := range plan.TypeCoercions {
+		canonical("type_coercions", &plan.TypeCoercions[index].Path)
+	}
+	plan.DefaultValues = append([]DefaultValueRule(nil), plan.DefaultValues...)
+	for index := range plan.DefaultValues {
+		canonical("default_values", &plan.DefaultValues[index].Path)
+	}
+	plan.RenameFields = append([]RenameFieldRule(nil), plan.RenameFields...)
+	for index := range plan.RenameFields {
+		canonical("rename_fields", &plan.RenameFields[index].Path)
+		canonical("rename_fields", &plan.RenameFields[index].To)
+	}
+	plan.Constraints = append([]ConstraintRule(nil), plan.Constraints...)
+	for index := range plan.Constraints {
+		canonical("constraints", &plan.Constraints[index].Path)
+	}
+	plan.OutputFields = append([]OutputField(nil), plan.OutputFields...)
+	for index := range plan.OutputFields {
+		canonical("output_fields", &plan.OutputFields[index].Path)
+	}
+	plan.Steps = append([]PlanStep(nil), plan.Steps...)
+	for index := range plan.Steps {
+		canonical("steps", &plan.Steps[index].Path)
+		canonical("steps", &plan.Steps[index].To)
+	}
+	plan.LossyDecisions = append([]LossyDecision(nil), plan.LossyDecisions...)
+	for index := range plan.LossyDecisions {
+		canonical("lossy_decisions", &plan.LossyDecisions[index].FieldPath)
+	}
+	return plan, err
+}
@@ -1,14 +1,25 @@
 package core
 
 import (
+	"encoding/json"
 	"sort"
+	"strings"
+	"time"
 )
 
 // InferConversionPlan suggests a plan for the target format.
 func InferConversionPlan(data CanonicalData, targetFormat string) ConversionPlan {
-	if targetFormat != "csv" {
+	switch targetFormat {
+	case "csv":
+		return inferCSVPlan(data)
+	case "json", "ndjson":
+		return inferTypedPlan(data)
+	default:
 		return ConversionPlan{}
 	}
+}
+
+func inferCSVPlan(data CanonicalData) ConversionPlan {
 	flattenSet := map[string]struct{}{}
 	explodeSet := map[string]struct{}{}
 	joinRules := map[string]JoinArrayRule{}
@@ -44,17 +55,24 @@ func InferConversionPlan(data CanonicalData, targetFormat string) ConversionPlan
 	}
 }
 
-func collectPlanSuggestions(value any, prefix string, flattenSet map[string]struct{}, explodeSet map[string]struct{}, joinRules map[string]JoinArrayRule, lossyDecisions map[string]LossyDecision) {
+// collectPlanSuggestions names each value by the top-level key it has once
+// the objects holding it are flattened, since the suggested rules run after
+// the suggested flattens.
+func collectPlanSuggestions(value any, flatKey string, flattenSet map[string]struct{}, explodeSet map[string]struct{}, joinRules map[string]JoinArrayRule, lossyDecisions map[string]LossyDecision) {
+	prefix := ""
+	if flatKey != "" {
+		prefix = AppendPathKey("", flatKey, false)
+	}
 	if recordMap, ok := mapFromValue(value); ok {
 		if prefix != "" {
 			flattenSet[prefix] = struct{}{}
 		}
 		for key, nested := range recordMap {
-			path := key
-			if prefix != "" {
-				path = prefix + "." + key
+			nestedKey := key
+			if flatKey != "" {
+				nestedKey = flatKey + "." + key
 			}
-			collectPlanSuggestions(nested, path, flattenSet, explodeSet, joinRules, lossyDecisions)
+			collectPlanSuggestions(nested, nestedKey, flattenSet, explodeSet, joinRules, lossyDecisions)
 		}
 		return
 	}
@@ -67,14 +85,14 @@ func collectPlanSuggestions(value any, prefix string, flattenSet map[string]stru
 		case LogicalTypeObject:
 			explodeSet[prefix] = struct{}{}
 		case LogicalTypeString, LogicalTypeNumber, LogicalTypeBoolean:
-			joinRules[prefix] = JoinArrayRule{Path: prefix, Delimiter: ","}
+			joinRules[prefix] = inferredJoinRule(prefix)
 			lossyDecisions[prefix] = LossyDecision{
 				FieldPath: prefix,
 				Reason:    LossReasonFormatLimit,
 				Strategy:  StrategyJoinArray,
 			}
 		default:
-			joinRules[prefix] = JoinArrayRule{Path: prefix, Delimiter: ","}
+			joinRules[prefix] = inferredJoinRule(prefix)
 			lossyDecisions[prefix] = LossyDecision{
 				FieldPath: prefix,
 				Reason:    LossReasonFormatLimit,
@@ -85,22 +103,178 @@ func collectPlanSuggestions(value any, prefix string, flattenSet map[string]stru
 	}
 }
 
+type coercionCandidate struct {
+	valueCount int
+	number     bool
+	fraction   bool
+	boolean    bool
+}
+
+// inferTypedPlan proposes type coercions for string fields whose values all
+// read as numbers or booleans, such as CSV cells headed for a typed format.
+// Numbers become exact integers, or decimals when any value has a fraction.
+func inferTypedPlan(data CanonicalData) ConversionPlan {
+	candidates := map[string]*coercionCandidate{}
+	for _, record := range data.Values.Records {
+		collectCoercionCandidates(record, "", candidates)
+	}
+
+	coercions := []TypeCoercionRule{}
+	decisions := []LossyDecision{}
+	for _, path := range sortedCandidatePaths(candidates) {
+		candidate := candidates[path]
+		if candidate.valueCount == 0 {
+			continue
+		}
+		targetType := LogicalType("")
+		switch {
+		case candidate.number && candidate.fraction:
+			targetType = LogicalTypeDecimal
+		case candidate.number:
+			targetType = LogicalTypeInteger
+		case candidate.boolean:
+			targetType = LogicalTypeBoolean
+		default:
+			continue
+		}
+		coercions = append(coercions, TypeCoercionRule{Path: path, TargetType: targetType})
+		decisions = append(decisions, LossyDecision{
+			FieldPath: path,
+			Reason:    LossReasonFormatLimit,
+			Strategy:  StrategyCoerceType,
+		})
+	}
+	if len(coercions) == 0 {
+		return ConversionPlan{}
+	}
+	return ConversionPlan{
+		TypeCoercions:  coercions,
+		LossyDecisions: decisions,
+	}
+}
+
+func collectCoercionCandidates(value any, prefix string, candidates map[string]*coercionCandidate) {
+	if recordMap, ok := mapFromValue(value); ok {
+		if prefix != "" {
+			rejectCoercionCandidate(prefix, candidates)
+		}
+		for key, nested := range recordMap {
+			path := ChildPath(prefix, key, nested)
+			collectCoercionCandidates(nested, path, candidates)
+		}
+		return
+	}
+	if value == nil {
+		return
+	}
+	candidate := ensureCoercionCandidate(prefix, candidates)
+	text, ok := value.(string)
+	if !ok {
+		candidate.number = false
+		candidate.boolean = false
+		return
+	}
+	candidate.valueCount++
+	trimmed := strings.TrimSpace(text)
+	if !looksLikeNumber(trimmed) {
+		candidate.number = false
+	}
+	if strings.Contains(trimmed, ".") {
+		candidate.fraction = true
+	}
+	if !strings.EqualFold(trimmed, "true") && !strings.EqualFold(trimmed, "false") {
+		candidate.boolean = false
+	}
+}
+
+func ensureCoercionCandidate(path string, candidates map[string]*coercionCandidate) *coercionCandidate {
+	candidate, ok := candidates[path]
+	if !ok {
+		candidate = &coercionCandidate{number: true, boolean: true}
+		candidates[path] = candidate
+	}
+	return candidate
+}
+
+func rejectCoercionCandidate(path string, candidates map[string]*coercionCandidate) {
+	candidate := ensureCoercionCandidate(path, candidates)
+	candidate.number = false
+	candidate.boolean = false
+}
+
+func sortedCandidatePaths(candidates map[string]*coercionCandidate) []string {
+	paths := make([]string, 0, len(candidates))
+	for path := range candidates {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// looksLikeNumber accepts plain decimal notation only. Leading zeros, signs
+// other than "-", exponents, and special values such as NaN are rejected so
+// identifiers like zip codes are not proposed for numeric coercion.
+func looksLikeNumber(text string) bool {
+	if strings.HasPrefix(text, "-") {
+		text = text[1:]
+	}
+	integerPart, fractionPart, hasFraction := strings.Cut(text, ".")
+	if integerPart == "" || !allDigits(integerPart) {
+		return false
+	}
+	if len(integerPart) > 1 && integerPart[0] == '0' {
+		return false
+	}
+	if hasFraction && (fractionPart == "" || !allDigits(fractionPart)) {
+		return false
+	}
+	return true
+}
+
+func allDigits(text string) bool {
+	for _, char := range text {
+		if char < '0' || char > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// inferredJoinRule escapes delimiters inside elements so the joined value can
+// be split back without ambiguity.
+func inferredJoinRule(path string) JoinArrayRule {
+	return JoinArrayRule{
+		Path:        path,
+		Delimiter:   ",",
+		OnCollision: DelimiterCollisionEscape,
+		EscapeChar:  `\`,
+	}
+}
+
 func inferArrayType(values []any) LogicalType {
 	for _, item := range values {
 		if item == nil {
 			continue
 		}
-		switch item.(type) {
+		switch typed := item.(type) {
 		case map[string]any:
 			return LogicalTypeObject
 		case []any:
 			return LogicalTypeArray
 		case string:
 			return LogicalTypeString
+		case json.Number:
+			return numberKind(typed)
 		case float64, float32, int, int64, int32, uint, uint64, uint32:
 			return LogicalTypeNumber
 		case bool:
 			return LogicalTypeBoolean
+		case Date:
+			return LogicalTypeDate
+		case time.Time:
+			return LogicalTypeDatetime
+		case Duration:
+			return LogicalTypeDuration
 		}
 	}
 	return LogicalTypeArray
@@ -0,0 +1,214 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+)
+
+// StepOperation names the operation a plan step performs.
+type StepOperation string
+
+const (
+	StepFlatten      StepOperation = "flatten"
+	StepUnflatten    StepOperation = "unflatten"
+	StepSplitString  StepOperation = "split_string"
+	StepExplodeArray StepOperation = "explode_array"
+	StepJoinArray    StepOperation = "join_array"
+	StepCoerceType   StepOperation = "coerce_type"
+	StepDefaultValue StepOperation = "default_value"
+	StepDropField    StepOperation = "drop_field"
+	StepRenameField  StepOperation = "rename_field"
+)
+
+// PlanStep is one typed operation in an ordered plan. Only the fields used
+// by Operation may be set.
+type PlanStep struct {
+	Operation   StepOperation      `json:"op"`
+	Path        string             `json:"path"`
+	Delimiter   string             `json:"delimiter,omitempty"`
+	OnCollision DelimiterCollision `json:"on_collision,omitempty"`
+	EscapeChar  string             `json:"escape_char,omitempty"`
+	Escaping    DelimiterCollision `json:"escaping,omitempty"`
+	Trim        bool               `json:"trim,omitempty"`
+	ElementType LogicalType        `json:"element_type,omitempty"`
+	TargetType  LogicalType        `json:"target_type,omitempty"`
+	Layouts     []string           `json:"layouts,omitempty"`
+	Timezone    string             `json:"timezone,omitempty"`
+	Value       any                `json:"value,omitempty"`
+	To          string             `json:"to,omitempty"`
+	OnConflict  RenameConflict     `json:"on_conflict,omitempty"`
+}
+
+// PlanSteps returns the steps a plan executes, in order. Plans with an
+// explicit steps array run it as written; plans using the grouped rule
+// fields desugar into flatten, unflatten, split, explode, join, coerce,
+// default, drop, rename order.
+func PlanSteps(plan ConversionPlan) ([]PlanStep, error) {
+	if len(plan.Steps) > 0 {
+		if hasGroupedRules(plan) {
+			return nil, errors.New("steps cannot be combined with grouped plan fields")
+		}
+		for index, step := range plan.Steps {
+			if err := validateStep(step); err != nil {
+				return nil, fmt.Errorf("steps[%d]: %w", index, err)
+			}
+		}
+		return plan.Steps, nil
+	}
+	return desugarPlan(NormalizePlan(plan)), nil
+}
+
+func hasGroupedRules(plan ConversionPlan) bool {
+	return len(plan.FlattenFields) > 0 ||
+		len(plan.UnflattenFields) > 0 ||
+		len(plan.SplitStrings) > 0 ||
+		len(plan.ExplodeArrays) > 0 ||
+		len(plan.JoinArrays) > 0 ||
+		len(plan.TypeCoercions) > 0 ||
+		len(plan.DefaultValues) > 0 ||
+		len(plan.DropFields) > 0 ||
+		len(plan.RenameFields) > 0
+}
+
+func desugarPlan(plan ConversionPlan) []PlanStep {
+	steps := []PlanStep{}
+	for _, path := range plan.FlattenFields {
+		steps = append(steps, PlanStep{Operation: StepFlatten, Path: path})
+	}
+	// Deeper prefixes are regrouped first so "a.b" nests before "a" collects it.
+	for index := len(plan.UnflattenFields) - 1; index >= 0; index-- {
+		steps = append(steps, PlanStep{Operation: StepUnflatten, Path: plan.UnflattenFields[index]})
+	}
+	for _, rule := range plan.SplitStrings {
+		steps = append(steps, splitStep(rule))
+	}
+	for _, path := range plan.ExplodeArrays {
+		steps = append(steps, PlanStep{Operation: StepExplodeArray, Path: path})
+	}
+	for _, rule := range plan.JoinArrays {
+		steps = append(steps, PlanStep{
+			Operation:   StepJoinArray,
+			Path:        rule.Path,
+			Delimiter:   rule.Delimiter,
+			OnCollision: rule.OnCollision,
+			EscapeChar:  rule.EscapeChar,
+		})
+	}
+	for _, rule := range plan.TypeCoercions {
+		steps = append(steps, PlanStep{
+			Operation:  StepCoerceType,
+			Path:       rule.Path,
+			TargetType: rule.TargetType,
+			Layouts:    rule.Layouts,
+			Timezone:   rule.Timezone,
+		})
+	}
+	for _, rule := range plan.DefaultValues {
+		steps = append(steps, PlanStep{Operation: StepDefaultValue, Path: rule.Path, Value: rule.Value})
+	}
+	for _, path := range plan.DropFields {
+		steps = append(steps, PlanStep{Operation: StepDropField, Path: path})
+	}
+	for _, rule := range plan.RenameFields {
+		steps = append(steps, PlanStep{Operation: StepRenameField, Path: rule.Path, To: rule.To, OnConflict: rule.OnConflict})
+	}
+	return steps
+}
+
+func splitStep(rule SplitStringRule) PlanStep {
+	return PlanStep{
+		Operation:   StepSplitString,
+		Path:        rule.Path,
+		Delimiter:   rule.Delimiter,
+		Trim:        rule.Trim,
+		ElementType: rule.ElementType,
+		Escaping:    rule.Escaping,
+		EscapeChar:  rule.EscapeChar,
+	}
+}
+
+func (s PlanStep) joinRule() JoinArrayRule {
+	return JoinArrayRule{Path: s.Path, Delimiter: s.Delimiter, OnCollision: s.OnCollision, EscapeChar: s.EscapeChar}
+}
+
+func (s PlanStep) coercionRule() TypeCoercionRule {
+	return TypeCoercionRule{Path: s.Path, TargetType: s.TargetType, Layouts: s.Layouts, Timezone: s.Timezone}
+}
+
+func (s PlanStep) splitRule() SplitStringRule {
+	return SplitStringRule{
+		Path:        s.Path,
+		Delimiter:   s.Delimiter,
+		Trim:        s.Trim,
+		ElementType: s.ElementType,
+		Escaping:    s.Escaping,
+		EscapeChar:  s.EscapeChar,
+	}
+}
+
+// lossyStrategy returns the lossy decision the step requires, if any.
+func (s PlanStep) lossyStrategy() (Strategy, bool) {
+	switch s.Operation {
+	case StepJoinArray:
+		return StrategyJoinArray, true
+	case StepCoerceType:
+		return StrategyCoerceType, true
+	case StepDropField:
+		return StrategyDropField, true
+	case StepSplitString:
+		return StrategySplitString, s.splitRule().IsLossy()
+	case StepRenameField:
+		return StrategyOverwriteField, s.OnConflict == RenameConflictOverwrite
+	default:
+		return "", false
+	}
+}
+
+func validateStep(step PlanStep) error {
+	if step.Path == "" {
+		return errors.New("step path is empty")
+	}
+	usesDelimiter := step.Delimiter != "" || step.OnCollision != "" || step.EscapeChar != "" || step.Escaping != "" || step.Trim || step.ElementType != ""
+	usesRename := step.To != "" || step.OnConflict != ""
+	if usesRename && step.Operation != StepRenameField {
+		return errors.New("to and on_conflict are only valid for rename_field steps")
+	}
+	if (len(step.Layouts) > 0 || step.Timezone != "") && step.Operation != StepCoerceType {
+		return errors.New("layouts and timezone are only valid for coerce_type steps")
+	}
+	switch step.Operation {
+	case StepFlatten, StepUnflatten, StepExplodeArray, StepDropField:
+		if usesDelimiter || step.TargetType != "" || step.Value != nil {
+			return errors.New(string(step.Operation) + " step only accepts a path")
+		}
+	case StepJoinArray:
+		if step.Escaping != "" || step.Trim || step.ElementType != "" || step.TargetType != "" || step.Value != nil {
+			return errors.New("join_array step accepts path, delimiter, on_collision, and escape_char")
+		}
+	case StepSplitString:
+		if step.OnCollision != "" || step.TargetType != "" || step.Value != nil {
+			return errors.New("split_string step accepts path, delimiter, trim, element_type, escaping, and escape_char")
+		}
+	case StepCoerceType:
+		if usesDelimiter || step.Value != nil {
+			return errors.New("coerce_type step accepts path, target_type, layouts, and timezone")
+		}
+		if step.TargetType == "" {
+			return errors.New("coerce_type step requires target_type")
+		}
+	case StepDefaultValue:
+		if usesDelimiter || step.TargetType != "" {
+			return errors.New("default_value step accepts path and value")
+		}
+	case StepRenameField:
+		if usesDelimiter || step.TargetType != "" || step.Value != nil {
+			return errors.New("rename_field step accepts path, to, and on_conflict")
+		}
+		if step.To == "" {
+			return errors.New("rename_field step requires to")
+		}
+	default:
+		return errors.New("unsupported step op: " + string(step.Operation))
+	}
+	return nil
+}
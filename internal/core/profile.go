@@ -0,0 +1,405 @@
+package core
+
+import (
+	"container/heap"
+	"encoding/json"
+	"hash/fnv"
+	"math"
+	"math/big"
+	"math/bits"
+	"sort"
+	"unicode/utf8"
+)
+
+// Defaults used by the CLI when --top-k and --distinct-limit are not given.
+const (
+	DefaultProfileTopK          = 5
+	DefaultProfileDistinctLimit = 1024
+)
+
+// ProfileOptions controls the statistics ProfileRecords gathers.
+type ProfileOptions struct {
+	// TopK is how many of the most frequent values to report per path.
+	TopK int
+	// DistinctLimit is how many distinct values are tracked per path. Distinct
+	// counts and top values are exact up to the limit and estimated beyond it,
+	// which keeps memory bounded on high-cardinality fields. Values below 2
+	// use DefaultProfileDistinctLimit.
+	DistinctLimit int
+	// matchPatterns tracks which inferred constraint patterns every string
+	// matches, for InferConstraints.
+	matchPatterns bool
+}
+
+// Profile reports per-path statistics of a set of records.
+type Profile struct {
+	RecordCount int            `json:"record_count"`
+	Fields      []FieldProfile `json:"fields"`
+}
+
+// FieldProfile holds the statistics of one path. Array items share the path
+// of their array, so counts may exceed the number of records. Distinct
+// counts and top values compare values by their text.
+type FieldProfile struct {
+	Path                 string              `json:"path"`
+	Type                 LogicalType         `json:"type"`
+	PresentCount         int                 `json:"present_count"`
+	PresenceRate         float64             `json:"presence_rate"`
+	ValueCount           int                 `json:"value_count"`
+	NullCount            int                 `json:"null_count"`
+	NullRate             float64             `json:"null_rate"`
+	TypeCounts           map[LogicalType]int `json:"type_counts"`
+	DistinctCount        int                 `json:"distinct_count"`
+	DistinctApproximate  bool                `json:"distinct_approximate,omitempty"`
+	Numeric              *NumericProfile     `json:"numeric,omitempty"`
+	StringLengths        *LengthProfile      `json:"string_lengths,omitempty"`
+	TopValues            []ValueCount        `json:"top_values,omitempty"`
+	TopValuesApproximate bool                `json:"top_values_approximate,omitempty"`
+}
+
+// NumericProfile summarizes the numeric values of a path, including strings
+// that hold a number, as CSV fields do. Minimum and maximum keep the exact
+// value text.
+type NumericProfile struct {
+	Count   int         `json:"count"`
+	Minimum json.Number `json:"minimum"`
+	Maximum json.Number `json:"maximum"`
+	Mean    float64     `json:"mean"`
+}
+
+// LengthProfile summarizes string lengths, counted in characters.
+type LengthProfile struct {
+	Count     int            `json:"count"`
+	Minimum   int            `json:"minimum"`
+	Maximum   int            `json:"maximum"`
+	Mean      float64        `json:"mean"`
+	Histogram []LengthBucket `json:"histogram"`
+}
+
+// LengthBucket counts strings whose length is within [Minimum, Maximum].
+// Buckets double in width: 0, 1, 2-3, 4-7, and so on.
+type LengthBucket struct {
+	Minimum int `json:"minimum"`
+	Maximum int `json:"maximum"`
+	Count   int `json:"count"`
+}
+
+// ValueCount is a value and how often it occurred. When top values are
+// approximate, Count may overstate the true count.
+type ValueCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// ProfileRecords gathers per-path statistics of records with the same
+// traversal BuildShapeFromRecords uses for shape inference. Fields are
+// sorted by path.
+func ProfileRecords(records []Record, options ProfileOptions) Profile {
+	if options.DistinctLimit < 2 {
+		options.DistinctLimit = DefaultProfileDistinctLimit
+	}
+	statsByPath := collectRecordStats(records, &options)
+	fields := make([]FieldProfile, 0, len(statsByPath.stats))
+	for path, stats := range statsByPath.stats {
+		fields = append(fields, stats.fieldProfile(path, len(records), options))
+	}
+	sort.Slice(fields, func(i, j int) bool { return fields[i].Path < fields[j].Path })
+	return Profile{RecordCount: len(records), Fields: fields}
+}
+
+func (s *fieldStats) fieldProfile(path string, recordCount int, options ProfileOptions) FieldProfile {
+	typeCounts := make(map[LogicalType]int, len(s.typeCounts))
+	valueCount := s.nullCount
+	for logicalType, count := range s.typeCounts {
+		typeCounts[logicalType] = count
+		valueCount += count
+	}
+	field := FieldProfile{
+		Path:         path,
+		Type:         chooseLogicalType(s.typeCounts),
+		PresentCount: s.presentCount,
+		PresenceRate: ratio(s.presentCount, recordCount),
+		ValueCount:   valueCount,
+		NullCount:    s.nullCount,
+		NullRate:     ratio(s.nullCount, valueCount),
+		TypeCounts:   typeCounts,
+	}
+	profile := s.profile
+	field.DistinctCount, field.DistinctApproximate = profile.distinct.estimate()
+	field.Numeric = profile.numericProfile()
+	field.StringLengths = profile.lengthProfile()
+	field.TopValues = profile.top.most(options.TopK)
+	field.TopValuesApproximate = profile.top.evicted && len(field.TopValues) > 0
+	return field
+}
+
+func ratio(count int, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(count) / float64(total)
+}
+
+// valueProfile accumulates the scalar values of one path.
+type valueProfile struct {
+	distinct *distinctSketch
+	top      *valueCounter
+	// patternMisses has bit i set once a string fails inferredPatterns[i];
+	// it is only kept when matching is set.
+	matching      bool
+	patternMisses uint
+
+	numericCount int
+	minimum      *big.Rat
+	maximum      *big.Rat
+	minimumValue any
+	maximumValue any
+	mean         float64
+
+	lengthCount int
+	lengthMin   int
+	lengthMax   int
+	lengthSum   int
+	lengthBins  map[int]int
+}
+
+func newValueProfile(options ProfileOptions) *valueProfile {
+	return &valueProfile{
+		distinct:   newDistinctSketch(options.DistinctLimit),
+		top:        newValueCounter(options.DistinctLimit),
+		matching:   options.matchPatterns,
+		lengthBins: map[int]int{},
+	}
+}
+
+func (p *valueProfile) observe(value any) {
+	text := valueText(value)
+	p.distinct.add(text)
+	p.top.add(text)
+	if typed, ok := value.(string); ok {
+		length := utf8.RuneCountInString(typed)
+		if p.lengthCount == 0 || length < p.lengthMin {
+			p.lengthMin = length
+		}
+		if length > p.lengthMax {
+			p.lengthMax = length
+		}
+		p.lengthCount++
+		p.lengthSum += length
+		p.lengthBins[bits.Len(uint(length))]++
+		if p.matching {
+			p.matchPatterns(typed)
+		}
+	}
+	if _, isBool := value.(bool); isBool {
+		return
+	}
+	exact, ok := exactValue(value)
+	if !ok {
+		return
+	}
+	if p.minimum == nil || exact.Cmp(p.minimum) < 0 {
+		p.minimum, p.minimumValue = exact, value
+	}
+	if p.maximum == nil || exact.Cmp(p.maximum) > 0 {
+		p.maximum, p.maximumValue = exact, value
+	}
+	approximate, _ := exact.Float64()
+	p.numericCount++
+	p.mean += (approximate - p.mean) / float64(p.numericCount)
+}
+
+func (p *valueProfile) numericProfile() *NumericProfile {
+	if p.numericCount == 0 {
+		return nil
+	}
+	minimum, _ := coerceDecimal(p.minimumValue)
+	maximum, _ := coerceDecimal(p.maximumValue)
+	return &NumericProfile{
+		Count:   p.numericCount,
+		Minimum: minimum,
+		Maximum: maximum,
+		Mean:    p.mean,
+	}
+}
+
+func (p *valueProfile) lengthProfile() *LengthProfile {
+	if p.lengthCount == 0 {
+		return nil
+	}
+	bins := make([]int, 0, len(p.lengthBins))
+	for bin := range p.lengthBins {
+		bins = append(bins, bin)
+	}
+	sort.Ints(bins)
+	histogram := make([]LengthBucket, 0, len(bins))
+	for _, bin := range bins {
+		bucket := LengthBucket{Count: p.lengthBins[bin]}
+		if bin > 0 {
+			bucket.Minimum = 1 << (bin - 1)
+			bucket.Maximum = 1<<bin - 1
+		}
+		histogram = append(histogram, bucket)
+	}
+	return &LengthProfile{
+		Count:     p.lengthCount,
+		Minimum:   p.lengthMin,
+		Maximum:   p.lengthMax,
+		Mean:      float64(p.lengthSum) / float64(p.lengthCount),
+		Histogram: histogram,
+	}
+}
+
+// distinctSketch counts distinct values exactly up to its limit and then
+// estimates the count from the limit smallest value hashes (a k-minimum
+// values sketch).
+type distinctSketch struct {
+	limit     int
+	hashes    map[uint64]struct{}
+	largest   hashHeap
+	saturated bool
+}
+
+func newDistinctSketch(limit int) *distinctSketch {
+	return &distinctSketch{limit: limit, hashes: map[uint64]struct{}{}}
+}
+
+func (d *distinctSketch) add(text string) {
+	hash := hashText(text)
+	if _, exists := d.hashes[hash]; exists {
+		return
+	}
+	if len(d.hashes) < d.limit {
+		d.hashes[hash] = struct{}{}
+		heap.Push(&d.largest, hash)
+		return
+	}
+	d.saturated = true
+	if hash >= d.largest[0] {
+		return
+	}
+	delete(d.hashes, d.largest[0])
+	d.largest[0] = hash
+	d.hashes[hash] = struct{}{}
+	heap.Fix(&d.largest, 0)
+}
+
+// estimate returns the distinct count and whether it is an estimate.
+func (d *distinctSketch) estimate() (int, bool) {
+	if !d.saturated {
+		return len(d.hashes), false
+	}
+	fraction := float64(d.largest[0]) / math.Exp2(64)
+	return int(math.Round(float64(d.limit-1) / fraction)), true
+}
+
+func hashText(text string) uint64 {
+	hasher := fnv.New64a()
+	hasher.Write([]byte(text))
+	// Finalize with the splitmix64 mixer so the hashes spread evenly.
+	hash := hasher.Sum64()
+	hash ^= hash >> 30
+	hash *= 0xbf58476d1ce4e5b9
+	hash ^= hash >> 27
+	hash *= 0x94d049bb133111eb
+	hash ^= hash >> 31
+	return hash
+}
+
+// hashHeap is a max-heap of hashes.
+type hashHeap []uint64
+
+func (h hashHeap) Len() int           { return len(h) }
+func (h hashHeap) Less(i, j int) bool { return h[i] > h[j] }
+func (h hashHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *hashHeap) Push(value any)    { *h = append(*h, value.(uint64)) }
+func (h *hashHeap) Pop() any {
+	old := *h
+	value := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return value
+}
+
+// valueCounter counts values exactly up to its capacity. Beyond it, a new
+// value replaces the least frequent one and inherits its count (the
+// space-saving algorithm), so frequent values are still found.
+type valueCounter struct {
+	capacity int
+	entries  map[string]*counterEntry
+	least    counterHeap
+	evicted  bool
+}
+
+type counterEntry struct {
+	value string
+	count int
+	index int
+}
+
+func newValueCounter(capacity int) *valueCounter {
+	return &valueCounter{capacity: capacity, entries: map[string]*counterEntry{}}
+}
+
+func (c *valueCounter) add(text string) {
+	if entry, exists := c.entries[text]; exists {
+		entry.count++
+		heap.Fix(&c.least, entry.index)
+		return
+	}
+	if len(c.entries) < c.capacity {
+		entry := &counterEntry{value: text, count: 1}
+		c.entries[text] = entry
+		heap.Push(&c.least, entry)
+		return
+	}
+	c.evicted = true
+	entry := c.least[0]
+	delete(c.entries, entry.value)
+	entry.value = text
+	entry.count++
+	c.entries[text] = entry
+	heap.Fix(&c.least, 0)
+}
+
+// most returns up to k values, most frequent first and ties by value.
+func (c *valueCounter) most(k int) []ValueCount {
+	if k <= 0 || len(c.entries) == 0 {
+		return nil
+	}
+	counts := make([]ValueCount, 0, len(c.entries))
+	for _, entry := range c.entries {
+		counts = append(counts, ValueCount{Value: entry.value, Count: entry.count})
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Count != counts[j].Count {
+			return counts[i].Count > counts[j].Count
+		}
+		return counts[i].Value < counts[j].Value
+	})
+	if len(counts) > k {
+		counts = counts[:k]
+	}
+	return counts
+}
+
+// counterHeap is a min-heap of entries by count.
+type counterHeap []*counterEntry
+
+func (h counterHeap) Len() int           { return len(h) }
+func (h counterHeap) Less(i, j int) bool { return h[i].count < h[j].count }
+func (h counterHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+func (h *counterHeap) Push(value any) {
+	entry := value.(*counterEntry)
+	entry.index = len(*h)
+	*h = append(*h, entry)
+}
+func (h *counterHeap) Pop() any {
+	old := *h
+	entry := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return entry
+}
@@ -1,7 +1,9 @@
 package core
 
 import (
+	"encoding/json"
 	"sort"
+	"time"
 )
 
 type fieldStats struct {
@@ -9,25 +11,53 @@ type fieldStats struct {
 	nullCount    int
 	typeCounts   map[LogicalType]int
 	repeated     bool
+	profile      *valueProfile
+}
+
+// fieldStatsByPath holds the stats of every path seen in recordCount
+// records. When profile is set, each path also accumulates a valueProfile of
+// its scalar values.
+type fieldStatsByPath struct {
+	stats       map[string]*fieldStats
+	profile     *ProfileOptions
+	recordCount int
 }
 
 // BuildShapeFromRecords infers a shape from canonical records.
 func BuildShapeFromRecords(records []Record) DataShape {
-	statsByPath := map[string]*fieldStats{}
+	return collectRecordStats(records, nil).shape()
+}
+
+func newFieldStatsByPath(profile *ProfileOptions) *fieldStatsByPath {
+	return &fieldStatsByPath{stats: map[string]*fieldStats{}, profile: profile}
+}
+
+func collectRecordStats(records []Record, profile *ProfileOptions) *fieldStatsByPath {
+	statsByPath := newFieldStatsByPath(profile)
 	for _, record := range records {
-		pathsInRecord := map[string]struct{}{}
-		collectFieldStats(record, "", statsByPath, pathsInRecord)
-		for path, stats := range statsByPath {
-			if _, ok := pathsInRecord[path]; ok {
-				stats.presentCount++
-			}
+		statsByPath.add(record)
+	}
+	return statsByPath
+}
+
+// add collects the stats of one more record.
+func (s *fieldStatsByPath) add(record Record) {
+	pathsInRecord := map[string]struct{}{}
+	collectFieldStats(record, "", s, pathsInRecord)
+	for path, stats := range s.stats {
+		if _, ok := pathsInRecord[path]; ok {
+			stats.presentCount++
 		}
 	}
+	s.recordCount++
+}
 
-	fields := make([]FieldDefinition, 0, len(statsByPath))
-	for path, stats := range statsByPath {
+// shape returns the fields inferred from the records added so far.
+func (s *fieldStatsByPath) shape() DataShape {
+	fields := make([]FieldDefinition, 0, len(s.stats))
+	for path, stats := range s.stats {
 		fieldType := chooseLogicalType(stats.typeCounts)
-		nullable := stats.nullCount > 0 || stats.presentCount < len(records)
+		nullable := stats.nullCount > 0 || stats.presentCount < s.recordCount
 		fields = append(fields, FieldDefinition{
 			Path:     path,
 			Type:     fieldType,
@@ -40,18 +70,15 @@ func BuildShapeFromRecords(records []Record) DataShape {
 	return DataShape{Fields: fields}
 }
 
-func collectFieldStats(value any, prefix string, statsByPath map[string]*fieldStats, pathsInRecord map[string]struct{}) {
+func collectFieldStats(value any, prefix string, statsByPath *fieldStatsByPath, pathsInRecord map[string]struct{}) {
 	recordMap, ok := mapFromValue(value)
 	if ok {
 		if prefix != "" {
-			ensureFieldStats(prefix, statsByPath).typeCounts[LogicalTypeObject]++
+			statsByPath.ensure(prefix).typeCounts[LogicalTypeObject]++
 			pathsInRecord[prefix] = struct{}{}
 		}
 		for key, nested := range recordMap {
-			path := key
-			if prefix != "" {
-				path = prefix + "." + key
-			}
+			path := ChildPath(prefix, key, nested)
 			collectFieldStats(nested, path, statsByPath, pathsInRecord)
 		}
 		return
@@ -59,7 +86,7 @@ func collectFieldStats(value any, prefix string, statsByPath map[string]*fieldSt
 
 	sliceValue, ok := value.([]any)
 	if ok {
-		stats := ensureFieldStats(prefix, statsByPath)
+		stats := statsByPath.ensure(prefix)
 		stats.repeated = true
 		pathsInRecord[prefix] = struct{}{}
 		if len(sliceValue) == 0 {
@@ -67,80 +94,94 @@ func collectFieldStats(value any, prefix string, statsByPath map[string]*fieldSt
 			return
 		}
 		for _, item := range sliceValue {
-			subPath := prefix
-			collectArrayItemStats(item, subPath, statsByPath, pathsInRecord)
+			collectArrayItemStats(item, prefix, prefix+"[*]", statsByPath, pathsInRecord)
 		}
 		return
 	}
 
-	stats := ensureFieldStats(prefix, statsByPath)
+	stats := statsByPath.ensure(prefix)
 	pathsInRecord[prefix] = struct{}{}
 	if value == nil {
 		stats.nullCount++
 		return
 	}
-	switch value.(type) {
-	case string:
-		stats.typeCounts[LogicalTypeString]++
-	case float64, float32, int, int64, int32, uint, uint64, uint32:
-		stats.typeCounts[LogicalTypeNumber]++
-	case bool:
-		stats.typeCounts[LogicalTypeBoolean]++
-	default:
-		stats.typeCounts[LogicalTypeString]++
-	}
+	stats.observeScalar(value)
 }
 
-func collectArrayItemStats(item any, prefix string, statsByPath map[string]*fieldStats, pathsInRecord map[string]struct{}) {
+// collectArrayItemStats counts an array item under prefix, the path of its
+// array, and collects the fields of object items under itemPath, the path of
+// the items, such as items[*] or, for nested arrays, items[*][*].
+func collectArrayItemStats(item any, prefix string, itemPath string, statsByPath *fieldStatsByPath, pathsInRecord map[string]struct{}) {
 	if item == nil {
-		stats := ensureFieldStats(prefix, statsByPath)
+		stats := statsByPath.ensure(prefix)
 		stats.nullCount++
 		pathsInRecord[prefix] = struct{}{}
 		return
 	}
 	if recordMap, ok := mapFromValue(item); ok {
-		stats := ensureFieldStats(prefix, statsByPath)
+		stats := statsByPath.ensure(prefix)
 		stats.typeCounts[LogicalTypeObject]++
 		pathsInRecord[prefix] = struct{}{}
 		for key, nested := range recordMap {
-			path := prefix + "." + key
+			path := ChildPath(itemPath, key, nested)
 			collectFieldStats(nested, path, statsByPath, pathsInRecord)
 		}
 		return
 	}
 	if nestedArray, ok := item.([]any); ok {
-		stats := ensureFieldStats(prefix, statsByPath)
+		stats := statsByPath.ensure(prefix)
 		stats.typeCounts[LogicalTypeArray]++
 		pathsInRecord[prefix] = struct{}{}
 		for _, nested := range nestedArray {
-			collectArrayItemStats(nested, prefix, statsByPath, pathsInRecord)
+			collectArrayItemStats(nested, prefix, itemPath+"[*]", statsByPath, pathsInRecord)
 		}
 		return
 	}
-	stats := ensureFieldStats(prefix, statsByPath)
+	stats := statsByPath.ensure(prefix)
 	pathsInRecord[prefix] = struct{}{}
-	switch item.(type) {
+	stats.observeScalar(item)
+}
+
+// observeScalar counts the logical type of a non-null scalar and adds it to
+// the value profile when profiling.
+func (s *fieldStats) observeScalar(value any) {
+	switch typed := value.(type) {
 	case string:
-		stats.typeCounts[LogicalTypeString]++
+		s.typeCounts[LogicalTypeString]++
+	case json.Number:
+		s.typeCounts[numberKind(typed)]++
 	case float64, float32, int, int64, int32, uint, uint64, uint32:
-		stats.typeCounts[LogicalTypeNumber]++
+		s.typeCounts[LogicalTypeNumber]++
 	case bool:
-		stats.typeCounts[LogicalTypeBoolean]++
+		s.typeCounts[LogicalTypeBoolean]++
+	case Date:
+		s.typeCounts[LogicalTypeDate]++
+	case time.Time:
+		s.typeCounts[LogicalTypeDatetime]++
+	case Duration:
+		s.typeCounts[LogicalTypeDuration]++
 	default:
-		stats.typeCounts[LogicalTypeString]++
+		s.typeCounts[LogicalTypeString]++
+	}
+	if s.profile != nil {
+		s.profile.observe(value)
 	}
 }
 
-func ensureFieldStats(path string, statsByPath map[string]*fieldStats) *fieldStats {
-	stats, ok := statsByPath[path]
+func (s *fieldStatsByPath) ensure(path string) *fieldStats {
+	stats, ok := s.stats[path]
 	if !ok {
 		stats = &fieldStats{typeCounts: map[LogicalType]int{}}
-		statsByPath[path] = stats
+		if s.profile != nil {
+			stats.profile = newValueProfile(*s.profile)
+		}
+		s.stats[path] = stats
 	}
 	return stats
 }
 
 func chooseLogicalType(typeCounts map[LogicalType]int) LogicalType {
+	typeCounts = mergeNumericTypes(typeCounts)
 	if len(typeCounts) == 0 {
 		return LogicalTypeString
 	}
@@ -149,7 +190,7 @@ func chooseLogicalType(typeCounts map[LogicalType]int) LogicalType {
 			return typeValue
 		}
 	}
-	priority := []LogicalType{LogicalTypeObject, LogicalTypeArray, LogicalTypeString, LogicalTypeNumber, LogicalTypeBoolean}
+	priority := []LogicalType{LogicalTypeObject, LogicalTypeArray, LogicalTypeString, LogicalTypeNumber, LogicalTypeDecimal, LogicalTypeInteger, LogicalTypeDatetime, LogicalTypeDate, LogicalTypeDuration, LogicalTypeBoolean}
 	for _, typeValue := range priority {
 		if typeCounts[typeValue] > 0 {
 			return typeValue
@@ -157,3 +198,28 @@ func chooseLogicalType(typeCounts map[LogicalType]int) LogicalType {
 	}
 	return LogicalTypeString
 }
+
+// mergeNumericTypes folds numeric counts into the widest numeric type seen:
+// integers widen to decimals, and any floating-point value makes the field a
+// number.
+func mergeNumericTypes(typeCounts map[LogicalType]int) map[LogicalType]int {
+	numeric := typeCounts[LogicalTypeNumber] + typeCounts[LogicalTypeDecimal] + typeCounts[LogicalTypeInteger]
+	if numeric == 0 {
+		return typeCounts
+	}
+	widest := LogicalTypeInteger
+	if typeCounts[LogicalTypeNumber] > 0 {
+		widest = LogicalTypeNumber
+	} else if typeCounts[LogicalTypeDecimal] > 0 {
+		widest = LogicalTypeDecimal
+	}
+	merged := map[LogicalType]int{widest: numeric}
+	for typeValue, count := range typeCounts {
+		switch typeValue {
+		case LogicalTypeNumber, LogicalTypeDecimal, LogicalTypeInteger:
+		default:
+			merged[typeValue] = count
+		}
+	}
+	return merged
+}
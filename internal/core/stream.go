@@ -0,0 +1,239 @@
+package core
+
+import (
+	"errors"
+	"sort"
+)
+
+func validateStreaming(options *StreamingOptions) error {
+	if options == nil {
+		return nil
+	}
+	switch options.Shape {
+	case "", StreamShapeNone, StreamShapeTwoPass:
+		if options.DeclaredShape != nil {
+			return errors.New("streaming declared_shape requires shape declared")
+		}
+	case StreamShapeDeclared:
+		if options.DeclaredShape == nil {
+			return errors.New("streaming shape declared requires declared_shape")
+		}
+	default:
+		return errors.New("unsupported streaming shape: " + string(options.Shape))
+	}
+	return nil
+}
+
+// RecordStream runs a plan one record at a time. It holds the records in
+// flight, warning samples, and the first violations of error-policy
+// constraints, never the whole dataset. Records are numbered as TransformData numbers them, so
+// warnings match a batch run over the same input.
+type RecordStream struct {
+	plan        ConversionPlan
+	state       *transformState
+	steps       []recordStep
+	constraints []compiledConstraint
+	unlisted    []recordStep
+	shape       *DataShape
+	// entered counts the records that reached each step; the last entry
+	// counts records that reached constraint validation.
+	entered    []int
+	kept       int
+	violations constraintViolations
+}
+
+// NewRecordStream prepares plan for record-at-a-time execution. shape
+// describes the records after the plan's rules, as TransformData would infer
+// it, with the input field order in SourceOrder: the plan's declared shape or
+// the result of a ShapePass. It may be nil when neither field_order input nor
+// output_fields is used.
+func NewRecordStream(plan ConversionPlan, shape *DataShape) (*RecordStream, error) {
+	stream, err := newRecordStream(plan)
+	if err != nil {
+		return nil, err
+	}
+	if shape == nil {
+		if stream.plan.FieldOrder == FieldOrderInput {
+			return nil, errors.New("streaming field_order input requires streaming shape declared or two_pass")
+		}
+		if len(stream.plan.OutputFields) > 0 {
+			return nil, errors.New("streaming output_fields requires streaming shape declared or two_pass")
+		}
+		return stream, nil
+	}
+	output, err := stream.outputShape(*shape)
+	if err != nil {
+		return nil, err
+	}
+	stream.shape = &output
+	return stream, nil
+}
+
+func newRecordStream(plan ConversionPlan) (*RecordStream, error) {
+	prepared, err := preparePlan(plan)
+	if err != nil {
+		return nil, err
+	}
+	stream := &RecordStream{
+		plan:        prepared.plan,
+		state:       newTransformState(prepared.plan, nil, nil),
+		constraints: prepared.constraints,
+		entered:     make([]int, len(prepared.steps)+1),
+	}
+	for _, step := range prepared.steps {
+		compiled, err := stream.state.compileStep(step)
+		if err != nil {
+			return nil, err
+		}
+		stream.steps = append(stream.steps, compiled)
+	}
+	return stream, nil
+}
+
+// outputShape orders shape as the plan requests and lays out its output
+// fields, compiling the drops of unlisted fields.
+func (r *RecordStream) outputShape(shape DataShape) (DataShape, error) {
+	output := DataShape{Fields: append([]FieldDefinition(nil), shape.Fields...), SourceOrder: shape.SourceOrder}
+	if r.plan.FieldOrder == FieldOrderInput {
+		order := shape.SourceOrder
+		if len(order) == 0 {
+			order = make([]string, 0, len(shape.Fields))
+			for _, field := range shape.Fields {
+				order = append(order, field.Path)
+			}
+		}
+		output = orderShape(output, order)
+	} else {
+		sort.SliceStable(output.Fields, func(i, j int) bool { return output.Fields[i].Path < output.Fields[j].Path })
+	}
+	if len(r.plan.OutputFields) == 0 {
+		return output, nil
+	}
+	if r.plan.UnlistedFields == UnlistedFieldsDrop {
+		paths := unlistedPaths(output, r.plan.OutputFields)
+		unlisted, err := r.state.unlistedSteps(output, r.plan.OutputFields)
+		if err != nil {
+			return DataShape{}, err
+		}
+		r.unlisted = unlisted
+		output.Fields = withoutPaths(output.Fields, paths)
+	}
+	return layoutShape(output, r.plan.OutputFields, r.plan.UnlistedFields)
+}
+
+// withoutPaths removes the fields at or nested under paths.
+func withoutPaths(fields []FieldDefinition, paths []string) []FieldDefinition {
+	kept := make([]FieldDefinition, 0, len(fields))
+	for _, field := range fields {
+		if !pathWithinAny(field.Path, paths) {
+			kept = append(kept, field)
+		}
+	}
+	return kept
+}
+
+// Shape returns the shape of the records the stream emits, or nil when the
+// stream has none.
+func (r *RecordStream) Shape() *DataShape {
+	return r.shape
+}
+
+// Process transforms one input record and passes each resulting record to
+// emit. Once a constraint with the error policy is violated, later records
+// are still checked so every violation is reported, but none are emitted.
+func (r *RecordStream) Process(record Record, emit func(Record) error) error {
+	return r.push(record, 0, emit)
+}
+
+func (r *RecordStream) push(record Record, stage int, emit func(Record) error) error {
+	index := r.entered[stage]
+	r.entered[stage]++
+	if stage < len(r.steps) {
+		return r.steps[stage].apply(record, index, r.state.warnings, func(next Record) error {
+			return r.push(next, stage+1, emit)
+		})
+	}
+	keep, err := r.state.constrainRecord(r.constraints, record, index, &r.violations)
+	if err != nil || !keep || r.violations.count > 0 {
+		return err
+	}
+	keptIndex := r.kept
+	r.kept++
+	for _, step := range r.unlisted {
+		if err := step.apply(record, keptIndex, r.state.warnings, ignoreRecord); err != nil {
+			return err
+		}
+	}
+	return emit(record)
+}
+
+func ignoreRecord(Record) error {
+	return nil
+}
+
+// Finish completes the plan after the last record and returns the warnings.
+// As with TransformData, a violated error-policy constraint or an exceeded
+// loss budget is returned with the warnings as a *ConstraintError or
+// *LossBudgetError.
+func (r *RecordStream) Finish() ([]Warning, error) {
+	for _, step := range r.steps {
+		if err := step.finish(); err != nil {
+			return nil, err
+		}
+	}
+	if err := r.violations.err(); err != nil {
+		return r.state.warnings.list(), err
+	}
+	for _, step := range r.unlisted {
+		if err := step.finish(); err != nil {
+			return nil, err
+		}
+	}
+	warnings := r.state.warnings.list()
+	if r.plan.LossBudget != nil {
+		if err := EvaluateLossBudget(*r.plan.LossBudget, warnings, r.state.warnings.strategyRecords()); err != nil {
+			return warnings, err
+		}
+	}
+	return warnings, nil
+}
+
+// ShapePass is the first pass of a two_pass streaming plan. It runs the
+// plan's rules and constraints on each record and measures the shape of the
+// results without keeping them, so failures surface before any output is
+// written.
+type ShapePass struct {
+	stream *RecordStream
+	stats  *fieldStatsByPath
+}
+
+// NewShapePass prepares the first pass of plan.
+func NewShapePass(plan ConversionPlan) (*ShapePass, error) {
+	stream, err := newRecordStream(plan)
+	if err != nil {
+		return nil, err
+	}
+	return &ShapePass{stream: stream, stats: newFieldStatsByPath(nil)}, nil
+}
+
+// Process measures one input record.
+func (p *ShapePass) Process(record Record) error {
+	return p.stream.Process(record, func(result Record) error {
+		p.stats.add(result)
+		return nil
+	})
+}
+
+// Finish returns the measured shape, with sourceOrder, the input field order
+// observed by the decoder, adjusted for renames. Errors are returned as by
+// RecordStream.Finish.
+func (p *ShapePass) Finish(sourceOrder []string) (DataShape, []Warning, error) {
+	p.stream.state.order = append([]string(nil), sourceOrder...)
+	warnings, err := p.stream.Finish()
+	if err != nil {
+		return DataShape{}, warnings, err
+	}
+	shape := p.stats.shape()
+	shape.SourceOrder = p.stream.state.order
+	return shape, warnings, nil
+}
@@ -0,0 +1,266 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Date is a calendar date without a time of day or zone. Datetime values are
+// carried as time.Time and durations as Duration.
+type Date struct {
+	Year  int
+	Month time.Month
+	Day   int
+}
+
+// String returns the ISO 8601 form, e.g. 2025-03-04.
+func (d Date) String() string {
+	return fmt.Sprintf("%04d-%02d-%02d", d.Year, int(d.Month), d.Day)
+}
+
+// Format renders the date with a Go time layout.
+func (d Date) Format(layout string) string {
+	return time.Date(d.Year, d.Month, d.Day, 0, 0, 0, 0, time.UTC).Format(layout)
+}
+
+// MarshalJSON renders the date as an ISO 8601 string.
+func (d Date) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// Duration is an elapsed time rendered in ISO 8601 form, e.g. PT1H30M.
+type Duration time.Duration
+
+// String returns the ISO 8601 form using hours, minutes, and seconds.
+func (d Duration) String() string {
+	value := time.Duration(d)
+	sign := ""
+	if value < 0 {
+		sign = "-"
+		value = -value
+	}
+	hours := value / time.Hour
+	value -= hours * time.Hour
+	minutes := value / time.Minute
+	value -= minutes * time.Minute
+	builder := strings.Builder{}
+	builder.WriteString(sign + "PT")
+	if hours > 0 {
+		builder.WriteString(strconv.FormatInt(int64(hours), 10) + "H")
+	}
+	if minutes > 0 {
+		builder.WriteString(strconv.FormatInt(int64(minutes), 10) + "M")
+	}
+	if value > 0 || (hours == 0 && minutes == 0) {
+		builder.WriteString(strconv.FormatFloat(value.Seconds(), 'f', -1, 64) + "S")
+	}
+	return builder.String()
+}
+
+// MarshalJSON renders the duration as an ISO 8601 string.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// Default layouts used when a coercion rule lists none.
+const (
+	DefaultDateLayout     = "2006-01-02"
+	DefaultDatetimeLayout = time.RFC3339Nano
+)
+
+func isTemporalType(logicalType LogicalType) bool {
+	return logicalType == LogicalTypeDate || logicalType == LogicalTypeDatetime || logicalType == LogicalTypeDuration
+}
+
+// validateTemporalOptions checks that layouts and timezone are only used
+// where they apply.
+func validateTemporalOptions(targetType LogicalType, layouts []string, timezone string) error {
+	if len(layouts) > 0 && targetType != LogicalTypeDate && targetType != LogicalTypeDatetime {
+		return errors.New("layouts require target_type date or datetime")
+	}
+	for _, layout := range layouts {
+		if layout == "" {
+			return errors.New("layouts contains an empty layout")
+		}
+		if targetType == LogicalTypeDate && layoutHasClock(layout) {
+			return errors.New("layouts with a time of day or zone require target_type datetime: " + layout)
+		}
+	}
+	if timezone != "" {
+		if targetType != LogicalTypeDatetime {
+			return errors.New("timezone requires target_type datetime")
+		}
+		if _, err := time.LoadLocation(timezone); err != nil {
+			return errors.New("unknown timezone: " + timezone)
+		}
+	}
+	return nil
+}
+
+// coerceTemporal converts a value to a date, datetime, or duration. Strings
+// are parsed with every layout; a value that parses to different results
+// under different layouts is ambiguous and reported instead of guessed.
+func coerceTemporal(value any, rule TypeCoercionRule) (any, error) {
+	switch rule.TargetType {
+	case LogicalTypeDate:
+		switch typed := value.(type) {
+		case Date:
+			return typed, nil
+		case time.Time:
+			return dateOf(typed), nil
+		case string:
+			return parseWithLayouts(typed, rule, func(text string, layout string) (any, error) {
+				parsed, err := time.Parse(layout, text)
+				if err != nil {
+					return nil, err
+				}
+				return dateOf(parsed), nil
+			})
+		}
+	case LogicalTypeDatetime:
+		location := time.UTC
+		if rule.Timezone != "" {
+			loaded, err := time.LoadLocation(rule.Timezone)
+			if err != nil {
+				return nil, errors.New("unknown timezone: " + rule.Timezone)
+			}
+			location = loaded
+		}
+		switch typed := value.(type) {
+		case time.Time:
+			return typed, nil
+		case Date:
+			return time.Date(typed.Year, typed.Month, typed.Day, 0, 0, 0, 0, location), nil
+		case string:
+			return parseWithLayouts(typed, rule, func(text string, layout string) (any, error) {
+				return time.ParseInLocation(layout, text, location)
+			})
+		}
+	case LogicalTypeDuration:
+		switch typed := value.(type) {
+		case Duration:
+			return typed, nil
+		case string:
+			return parseDuration(strings.TrimSpace(typed))
+		}
+	}
+	return nil, errors.New("cannot coerce value to " + string(rule.TargetType))
+}
+
+// layoutHasClock reports whether layout renders a time of day or zone,
+// which a date cannot hold.
+func layoutHasClock(layout string) bool {
+	midnight := time.Date(2001, time.February, 3, 0, 0, 0, 0, time.UTC)
+	afternoon := time.Date(2001, time.February, 3, 13, 14, 15, 123456789, time.FixedZone("X", 3600))
+	return midnight.Format(layout) != afternoon.Format(layout)
+}
+
+// truncatesTime reports whether coercing value to a date drops a time of
+// day or a zone offset.
+func truncatesTime(value any) bool {
+	datetime, ok := value.(time.Time)
+	if !ok {
+		return false
+	}
+	_, offset := datetime.Zone()
+	return offset != 0 || datetime.Hour() != 0 || datetime.Minute() != 0 || datetime.Second() != 0 || datetime.Nanosecond() != 0
+}
+
+func dateOf(value time.Time) Date {
+	year, month, day := value.Date()
+	return Date{Year: year, Month: month, Day: day}
+}
+
+func parseWithLayouts(text string, rule TypeCoercionRule, parse func(string, string) (any, error)) (any, error) {
+	text = strings.TrimSpace(text)
+	layouts := rule.Layouts
+	if len(layouts) == 0 {
+		layouts = []string{DefaultDateLayout}
+		if rule.TargetType == LogicalTypeDatetime {
+			layouts = []string{DefaultDatetimeLayout}
+		}
+	}
+	var result any
+	matches := map[string]string{}
+	for _, layout := range layouts {
+		parsed, err := parse(text, layout)
+		if err != nil {
+			continue
+		}
+		rendered := valueText(parsed)
+		if result == nil {
+			result = parsed
+		}
+		matches[layout] = rendered
+	}
+	if result == nil {
+		return nil, errors.New("cannot coerce string to " + string(rule.TargetType) + ": " + strconv.Quote(text))
+	}
+	distinct := map[string]struct{}{}
+	for _, rendered := range matches {
+		distinct[rendered] = struct{}{}
+	}
+	if len(distinct) > 1 {
+		readings := make([]string, 0, len(matches))
+		for layout, rendered := range matches {
+			readings = append(readings, layout+" → "+rendered)
+		}
+		sort.Strings(readings)
+		return nil, errors.New("ambiguous " + string(rule.TargetType) + " " + strconv.Quote(text) + " (" + strings.Join(readings, ", ") + ")")
+	}
+	return result, nil
+}
+
+var isoDurationPattern = regexp.MustCompile(`^(-)?P(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+(?:\.\d+)?)S)?)?$`)
+
+// parseDuration accepts ISO 8601 durations limited to days, hours, minutes,
+// and seconds (a day counts as 24 hours) and Go duration strings such as
+// 1h30m. Years, months, and weeks have no fixed length and are rejected.
+func parseDuration(text string) (Duration, error) {
+	body := strings.TrimPrefix(text, "-")
+	if match := isoDurationPattern.FindStringSubmatch(text); match != nil && body != "P" && !strings.HasSuffix(body, "T") {
+		days, daysErr := strconv.ParseInt(zeroIfEmpty(match[2]), 10, 64)
+		hours, hoursErr := strconv.ParseInt(zeroIfEmpty(match[3]), 10, 64)
+		if daysErr != nil || hoursErr != nil || days > 1_000_000 {
+			return 0, errors.New("duration out of range: " + text)
+		}
+		goSyntax := match[1] + strconv.FormatInt(days*24+hours, 10) + "h" + zeroIfEmpty(match[4]) + "m" + zeroIfEmpty(match[5]) + "s"
+		parsed, err := time.ParseDuration(goSyntax)
+		if err != nil {
+			return 0, errors.New("duration out of range: " + text)
+		}
+		return Duration(parsed), nil
+	}
+	parsed, err := time.ParseDuration(text)
+	if err != nil {
+		return 0, errors.New("cannot coerce string to duration: " + strconv.Quote(text))
+	}
+	return Duration(parsed), nil
+}
+
+func zeroIfEmpty(text string) string {
+	if text == "" {
+		return "0"
+	}
+	return text
+}
+
+// valueText renders a scalar as text, using ISO 8601 for temporal values.
+func valueText(value any) string {
+	switch typed := value.(type) {
+	case time.Time:
+		return typed.Format(time.RFC3339Nano)
+	case Date:
+		return typed.String()
+	case Duration:
+		return typed.String()
+	default:
+		return fmt.Sprint(value)
+	}
+}
@@ -0,0 +1,86 @@
+package core_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"reshape/internal/core"
+)
+
+func TestInferConstraintsProposesBoundsEnumsAndPatterns(t *testing.T) {
+	records := []core.Record{
+		{"age": json.Number("30"), "status": "active", "id": "3f2b8c1e-8d6a-4c1e-9f1a-2b3c4d5e6f70", "email": "ada@example.com", "note": "first"},
+		{"age": json.Number("7"), "status": "inactive", "id": "8a1d2e3f-4b5c-4d6e-8f70-1a2b3c4d5e6f", "email": "linus@example.org", "note": "second"},
+		{"age": json.Number("41.5"), "status": "active", "id": "00000000-0000-4000-8000-000000000000", "email": nil, "note": "third"},
+	}
+	shape := core.InferConstraints(core.BuildShapeFromRecords(records), records, core.ConstraintInferenceOptions{EnumThreshold: 5})
+
+	age := fieldByPath(shape, "age").Constraints
+	if age == nil || age.Minimum == nil || *age.Minimum != 7 || age.Maximum == nil || *age.Maximum != 41.5 {
+		t.Fatalf("expected age bounds 7..41.5, got %+v", age)
+	}
+	status := fieldByPath(shape, "status").Constraints
+	if status == nil || !reflect.DeepEqual(status.Enum, []string{"active", "inactive"}) {
+		t.Fatalf("expected status enum, got %+v", status)
+	}
+	if id := fieldByPath(shape, "id").Constraints; id == nil || id.Pattern == "" || id.Enum != nil {
+		t.Fatalf("expected id pattern, got %+v", id)
+	}
+	if email := fieldByPath(shape, "email").Constraints; email == nil || email.Pattern != `^[^@\s]+@[^@\s]+\.[^@\s]+$` {
+		t.Fatalf("expected email pattern, got %+v", email)
+	}
+	if note := fieldByPath(shape, "note").Constraints; note != nil {
+		t.Fatalf("expected no constraints for distinct free text, got %+v", note)
+	}
+}
+
+func TestInferConstraintsHoldForTheProfiledRecords(t *testing.T) {
+	records := []core.Record{
+		{"big": json.Number("9007199254740993"), "ratio": json.Number("0.1"), "tags": []any{"x", "y"}},
+		{"big": json.Number("-9007199254740993"), "ratio": json.Number("0.3"), "tags": []any{"x"}},
+	}
+	shape := core.InferConstraints(core.BuildShapeFromRecords(records), records, core.ConstraintInferenceOptions{EnumThreshold: 5})
+	if tags := fieldByPath(shape, "tags").Constraints; tags == nil || !reflect.DeepEqual(tags.Enum, []string{"x", "y"}) {
+		t.Fatalf("expected tags enum from array items, got %+v", tags)
+	}
+
+	plan := core.ConversionPlan{Constraints: core.ConstraintRulesFromShape(shape)}
+	input := core.CanonicalData{Values: core.DataValues{Records: records}}
+	if _, _, err := core.TransformData(input, plan); err != nil {
+		t.Fatalf("expected inferred constraints to hold, got %v", err)
+	}
+}
+
+func TestInferConstraintsEnumThreshold(t *testing.T) {
+	records := []core.Record{{"color": "red"}, {"color": "green"}, {"color": "blue"}, {"color": "red"}}
+	shape := core.BuildShapeFromRecords(records)
+
+	if color := fieldByPath(core.InferConstraints(shape, records, core.ConstraintInferenceOptions{EnumThreshold: 2}), "color").Constraints; color != nil {
+		t.Fatalf("expected no enum above the threshold, got %+v", color)
+	}
+	if color := fieldByPath(core.InferConstraints(shape, records, core.ConstraintInferenceOptions{}), "color").Constraints; color != nil {
+		t.Fatalf("expected enums disabled by a zero threshold, got %+v", color)
+	}
+	color := fieldByPath(core.InferConstraints(shape, records, core.ConstraintInferenceOptions{EnumThreshold: 3}), "color").Constraints
+	if color == nil || !reflect.DeepEqual(color.Enum, []string{"blue", "green", "red"}) {
+		t.Fatalf("expected color enum, got %+v", color)
+	}
+}
+
+func TestInferConstraintsBeyondTrackedDistinctValues(t *testing.T) {
+	records := make([]core.Record, 0, 2001)
+	for index := 0; index < 2000; index++ {
+		records = append(records, core.Record{"code": strconv.Itoa(index % 1500), "tags": []any{}})
+	}
+	records = append(records, core.Record{"code": "7", "tags": []any{"x", "x"}})
+	shape := core.InferConstraints(core.BuildShapeFromRecords(records), records, core.ConstraintInferenceOptions{EnumThreshold: 5})
+
+	if code := fieldByPath(shape, "code").Constraints; code == nil || code.Enum != nil || code.Pattern != "^[0-9]+$" {
+		t.Fatalf("expected a digit pattern and no enum for many distinct codes, got %+v", code)
+	}
+	if tags := fieldByPath(shape, "tags").Constraints; tags == nil || !reflect.DeepEqual(tags.Enum, []string{"x"}) {
+		t.Fatalf("expected tags enum ignoring empty arrays, got %+v", tags)
+	}
+}
@@ -0,0 +1,151 @@
+package core_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"reshape/internal/core"
+)
+
+func constraintInput() core.CanonicalData {
+	return core.CanonicalData{Values: core.DataValues{Records: []core.Record{
+		{"age": json.Number("30"), "status": "active", "email": "ada@example.com"},
+		{"age": json.Number("-1"), "status": "gone", "email": "nobody"},
+		{"age": "abc", "status": "active", "email": nil},
+	}}}
+}
+
+func floatPointer(value float64) *float64 {
+	return &value
+}
+
+func TestConstraintErrorCollectsViolations(t *testing.T) {
+	plan := core.ConversionPlan{Constraints: []core.ConstraintRule{
+		{Path: "age", FieldConstraints: core.FieldConstraints{Minimum: floatPointer(0), Maximum: floatPointer(150)}},
+		{Path: "status", FieldConstraints: core.FieldConstraints{Enum: []string{"active", "inactive"}}},
+	}}
+
+	_, warnings, err := core.TransformData(constraintInput(), plan)
+	var constraintErr *core.ConstraintError
+	if !errors.As(err, &constraintErr) {
+		t.Fatalf("expected constraint error, got %v", err)
+	}
+	expected := []core.ConstraintViolation{
+		{RecordIndex: 1, Path: "age", Value: json.Number("-1"), Rule: core.ConstraintMinimum, Policy: core.ConstraintPolicyError},
+		{RecordIndex: 1, Path: "status", Value: "gone", Rule: core.ConstraintEnum, Policy: core.ConstraintPolicyError},
+		{RecordIndex: 2, Path: "age", Value: "abc", Rule: core.ConstraintMinimum, Policy: core.ConstraintPolicyError},
+		{RecordIndex: 2, Path: "age", Value: "abc", Rule: core.ConstraintMaximum, Policy: core.ConstraintPolicyError},
+	}
+	if !reflect.DeepEqual(constraintErr.Violations, expected) {
+		t.Fatalf("unexpected violations\nexpected: %#v\nactual: %#v", expected, constraintErr.Violations)
+	}
+	if len(warnings) != 3 || warnings[0].Code != core.WarningCodeConstraintViolation || warnings[0].Rule != core.ConstraintMaximum {
+		t.Fatalf("unexpected warnings: %#v", warnings)
+	}
+}
+
+func TestConstraintPoliciesWarnNullAndDrop(t *testing.T) {
+	plan := core.ConversionPlan{
+		Constraints: []core.ConstraintRule{
+			{Path: "age", FieldConstraints: core.FieldConstraints{Minimum: floatPointer(0), OnViolation: core.ConstraintPolicyDropRecord}},
+			{Path: "email", FieldConstraints: core.FieldConstraints{Pattern: `^[^@]+@[^@]+$`, OnViolation: core.ConstraintPolicyNull}},
+			{Path: "status", FieldConstraints: core.FieldConstraints{Enum: []string{"active"}, OnViolation: core.ConstraintPolicyWarn}},
+		},
+		LossyDecisions: []core.LossyDecision{
+			{FieldPath: "age", Reason: core.LossReasonUserRequest, Strategy: core.StrategyDropRecord},
+		},
+	}
+
+	_, _, err := core.TransformData(constraintInput(), plan)
+	if err == nil || !strings.Contains(err.Error(), "constraints on_violation null requires null_invalid lossy_decisions entry for path: email") {
+		t.Fatalf("expected lossy decision error, got %v", err)
+	}
+
+	plan.LossyDecisions = append(plan.LossyDecisions, core.LossyDecision{
+		FieldPath: "email", Reason: core.LossReasonUserRequest, Strategy: core.StrategyNullInvalid,
+	})
+	output, warnings, err := core.TransformData(constraintInput(), plan)
+	if err != nil {
+		t.Fatalf("transform: %v", err)
+	}
+	expectedRecords := []core.Record{
+		{"age": json.Number("30"), "status": "active", "email": "ada@example.com"},
+	}
+	if !reflect.DeepEqual(output.Values.Records, expectedRecords) {
+		t.Fatalf("unexpected records\nexpected: %v\nactual: %v", expectedRecords, output.Values.Records)
+	}
+
+	summary := []string{}
+	for _, warning := range warnings {
+		summary = append(summary, fmt.Sprintf("%s:%s:%s:%d", warning.Path, warning.Code, warning.Rule, warning.AffectedCount))
+	}
+	expectedSummary := []string{"age:drop_record:minimum:2", "email:null_invalid:pattern:1", "status:constraint_violation:enum:1"}
+	if !reflect.DeepEqual(summary, expectedSummary) {
+		t.Fatalf("unexpected warnings\nexpected: %v\nactual: %v", expectedSummary, summary)
+	}
+}
+
+func TestConstraintRulesFromShape(t *testing.T) {
+	shape := core.DataShape{Fields: []core.FieldDefinition{
+		{Path: "age", Type: core.LogicalTypeInteger, Constraints: &core.FieldConstraints{Maximum: floatPointer(10)}},
+		{Path: "name", Type: core.LogicalTypeString},
+	}}
+	rules := core.ConstraintRulesFromShape(shape)
+	if len(rules) != 1 || rules[0].Path != "age" || *rules[0].Maximum != 10 {
+		t.Fatalf("unexpected rules: %#v", rules)
+	}
+
+	_, _, err := core.TransformData(constraintInput(), core.ConversionPlan{Constraints: []core.ConstraintRule{
+		{Path: "email", FieldConstraints: core.FieldConstraints{Pattern: "("}},
+	}})
+	if err == nil || !strings.Contains(err.Error(), "constraints pattern is invalid for path email") {
+		t.Fatalf("expected pattern error, got %v", err)
+	}
+}
+
+func TestConstraintsCheckArrayItemsOneByOne(t *testing.T) {
+	input := core.CanonicalData{Values: core.DataValues{Records: []core.Record{
+		{"scores": []any{1.0, -2.0, 3.0, -4.0}},
+	}}}
+	plan := core.ConversionPlan{
+		Constraints:    []core.ConstraintRule{{Path: "scores", FieldConstraints: core.FieldConstraints{Minimum: floatPointer(0), OnViolation: core.ConstraintPolicyNull}}},
+		LossyDecisions: []core.LossyDecision{{FieldPath: "scores", Reason: core.LossReasonUserRequest, Strategy: core.StrategyNullInvalid}},
+	}
+	output, warnings, err := core.TransformData(input, plan)
+	if err != nil {
+		t.Fatalf("transform: %v", err)
+	}
+	if expected := []any{1.0, nil, 3.0, nil}; !reflect.DeepEqual(output.Values.Records[0]["scores"], expected) {
+		t.Fatalf("expected only failing items nulled, got %v", output.Values.Records[0]["scores"])
+	}
+	if len(warnings) != 1 || !reflect.DeepEqual(warnings[0].Samples[0].Before, []any{1.0, -2.0, 3.0, -4.0}) || !reflect.DeepEqual(warnings[0].Samples[0].After, []any{1.0, nil, 3.0, nil}) {
+		t.Fatalf("unexpected warnings: %#v", warnings)
+	}
+
+	plan.Constraints[0].OnViolation = core.ConstraintPolicyError
+	_, _, err = core.TransformData(input, plan)
+	var constraintErr *core.ConstraintError
+	if !errors.As(err, &constraintErr) {
+		t.Fatalf("expected constraint error, got %v", err)
+	}
+	expected := "constraint violations: record 0 path scores violates minimum (value: -2); record 0 path scores violates minimum (value: -4)"
+	if err.Error() != expected {
+		t.Fatalf("unexpected message\nexpected: %s\nactual: %s", expected, err.Error())
+	}
+}
+
+func TestConstraintErrorsReportEachFailingMatch(t *testing.T) {
+	input := core.CanonicalData{Values: core.DataValues{Records: []core.Record{
+		{"items": []any{map[string]any{"qty": 2.0}, map[string]any{"qty": 3.0}, map[string]any{"qty": 1.0}}},
+	}}}
+	plan := core.ConversionPlan{Constraints: []core.ConstraintRule{{Path: "items[*].qty", FieldConstraints: core.FieldConstraints{Maximum: floatPointer(1)}}}}
+	_, _, err := core.TransformData(input, plan)
+	expected := "constraint violations: record 0 path items[*].qty violates maximum (value: 2); record 0 path items[*].qty violates maximum (value: 3)"
+	if err == nil || err.Error() != expected {
+		t.Fatalf("expected %q, got %v", expected, err)
+	}
+}
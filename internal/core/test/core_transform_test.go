@@ -2,6 +2,7 @@ package core_test
 
 import (
 	"reflect"
+	"strings"
 	"testing"
 
 	"reshape/internal/core"
@@ -79,3 +80,60 @@ func TestTransformDeterministic(t *testing.T) {
 		t.Fatalf("transform not deterministic\nfirst: %+v\nsecond: %+v", first, second)
 	}
 }
+
+func TestTransformSplitsDelimitedStrings(t *testing.T) {
+	inputData := core.CanonicalData{Values: core.DataValues{Records: []core.Record{
+		{"tags": "alpha;beta", "scores": " 1 ; ;3", "notes": ""},
+		{"tags": nil, "scores": "4"},
+	}}}
+
+	plan := core.ConversionPlan{
+		SplitStrings: []core.SplitStringRule{
+			{Path: "tags", Delimiter: ";"},
+			{Path: "scores", Delimiter: ";", Trim: true, ElementType: core.LogicalTypeNumber},
+			{Path: "notes", Delimiter: ";"},
+		},
+		LossyDecisions: []core.LossyDecision{
+			{FieldPath: "scores", Strategy: core.StrategySplitString, Reason: core.LossReasonUserRequest},
+		},
+	}
+
+	transformed, warnings, err := core.TransformData(inputData, plan)
+	if err != nil {
+		t.Fatalf("transform: %v", err)
+	}
+
+	expected := []core.Record{
+		{"tags": []any{"alpha", "beta"}, "scores": []any{1.0, nil, 3.0}, "notes": []any{}},
+		{"tags": nil, "scores": []any{4.0}},
+	}
+	if !reflect.DeepEqual(transformed.Values.Records, expected) {
+		t.Fatalf("unexpected records\nexpected: %v\nactual: %v", expected, transformed.Values.Records)
+	}
+	if len(warnings) != 1 || warnings[0].Code != core.WarningCodeSplitString || warnings[0].Path != "scores" {
+		t.Fatalf("unexpected warnings: %v", warnings)
+	}
+	scores := fieldByPath(transformed.Shape, "scores")
+	if !scores.Repeated || scores.Type != core.LogicalTypeNumber {
+		t.Fatalf("unexpected scores shape: %#v", scores)
+	}
+}
+
+func TestTransformSplitRequiresLossyDecisionWhenCoercing(t *testing.T) {
+	inputData := core.CanonicalData{Values: core.DataValues{Records: []core.Record{
+		{"flags": "true,false"},
+	}}}
+	plan := core.ConversionPlan{
+		SplitStrings: []core.SplitStringRule{
+			{Path: "flags", Delimiter: ",", ElementType: core.LogicalTypeBoolean},
+		},
+	}
+
+	_, _, err := core.TransformData(inputData, plan)
+	if err == nil {
+		t.Fatalf("expected error for missing lossy decision")
+	}
+	if !strings.Contains(err.Error(), "split_strings") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
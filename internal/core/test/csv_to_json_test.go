@@ -0,0 +1,47 @@
+package core_test
+
+import (
+	"testing"
+
+	"reshape/internal/core"
+	"reshape/internal/formats"
+)
+
+func TestCSVToJSONInferredTypes(t *testing.T) {
+	input := []byte("name,age,active\nAda,36,true\nLinus,,false\n")
+
+	data, err := formats.ParseCSV(input)
+	if err != nil {
+		t.Fatalf("parse csv: %v", err)
+	}
+
+	plan := core.InferConversionPlan(data, "json")
+	transformed, warnings, err := core.TransformData(data, plan)
+	if err != nil {
+		t.Fatalf("transform: %v", err)
+	}
+
+	output, err := formats.RenderJSONWithOptions(transformed, formats.JSONOptions{Envelope: formats.JSONEnvelopeArray})
+	if err != nil {
+		t.Fatalf("render json: %v", err)
+	}
+
+	expectedJSON := `[{"active":true,"age":36,"name":"Ada"},{"active":false,"age":null,"name":"Linus"}]`
+	if string(output) != expectedJSON {
+		t.Fatalf("unexpected json output\nexpected: %s\nactual: %s", expectedJSON, string(output))
+	}
+
+	expectedWarnings := []core.Warning{
+		core.WarningFor(core.WarningCodeCoerceType, "active"),
+		core.WarningFor(core.WarningCodeCoerceType, "age"),
+	}
+	if len(warnings) != len(expectedWarnings) {
+		t.Fatalf("unexpected warnings: %v", warnings)
+	}
+	for index, warning := range warnings {
+		expected := expectedWarnings[index]
+		if warning.Code != expected.Code || warning.Path != expected.Path {
+			t.Fatalf("unexpected warning at %d\nexpected: %#v\nactual: %#v", index, expected, warning)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package core_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"reshape/internal/core"
+)
+
+func TestJoinArrayCollisionStrategies(t *testing.T) {
+	// Escaping and quoting keep the elements recoverable, so only
+	// acknowledge reports the collision.
+	cases := []struct {
+		name     string
+		rule     core.JoinArrayRule
+		expected string
+		codes    []core.WarningCode
+	}{
+		{"escape", core.JoinArrayRule{Path: "tags", Delimiter: ",", OnCollision: core.DelimiterCollisionEscape, EscapeChar: `\`}, `a\,b,c\\d,"e"`, []core.WarningCode{core.WarningCodeJoinArray}},
+		{"quote", core.JoinArrayRule{Path: "tags", Delimiter: ",", OnCollision: core.DelimiterCollisionQuote}, `"a,b",c\d,"""e"""`, []core.WarningCode{core.WarningCodeJoinArray}},
+		{"acknowledge", core.JoinArrayRule{Path: "tags", Delimiter: ",", OnCollision: core.DelimiterCollisionAcknowledge}, `a,b,c\d,"e"`, []core.WarningCode{core.WarningCodeJoinArray, core.WarningCodeJoinCollision}},
+	}
+	for _, testCase := range cases {
+		inputData := core.CanonicalData{Values: core.DataValues{Records: []core.Record{
+			{"tags": []any{"a,b", `c\d`, `"e"`}},
+		}}}
+		plan := core.ConversionPlan{
+			JoinArrays: []core.JoinArrayRule{testCase.rule},
+			LossyDecisions: []core.LossyDecision{
+				{FieldPath: "tags", Strategy: core.StrategyJoinArray, Reason: core.LossReasonFormatLimit},
+				{FieldPath: "tags", Strategy: core.StrategyJoinCollision, Reason: core.LossReasonUserRequest},
+			},
+		}
+
+		transformed, warnings, err := core.TransformData(inputData, plan)
+		if err != nil {
+			t.Fatalf("%s transform: %v", testCase.name, err)
+		}
+		if transformed.Values.Records[0]["tags"] != testCase.expected {
+			t.Fatalf("%s: expected %q, got %q", testCase.name, testCase.expected, transformed.Values.Records[0]["tags"])
+		}
+		codes := []core.WarningCode{}
+		for _, warning := range warnings {
+			codes = append(codes, warning.Code)
+		}
+		if !reflect.DeepEqual(codes, testCase.codes) {
+			t.Fatalf("%s: unexpected warning codes %v", testCase.name, codes)
+		}
+	}
+}
+
+func TestJoinArrayCollisionErrorsByDefault(t *testing.T) {
+	inputData := core.CanonicalData{Values: core.DataValues{Records: []core.Record{
+		{"tags": []any{"a,b", "c"}},
+	}}}
+	plan := core.ConversionPlan{
+		JoinArrays: []core.JoinArrayRule{{Path: "tags", Delimiter: ","}},
+		LossyDecisions: []core.LossyDecision{
+			{FieldPath: "tags", Strategy: core.StrategyJoinArray, Reason: core.LossReasonFormatLimit},
+		},
+	}
+
+	_, _, err := core.TransformData(inputData, plan)
+	if err == nil {
+		t.Fatalf("expected collision error")
+	}
+	if !strings.Contains(err.Error(), "contains the delimiter") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestJoinArrayAcknowledgeRequiresLossyDecision(t *testing.T) {
+	plan := core.ConversionPlan{
+		JoinArrays: []core.JoinArrayRule{{Path: "tags", Delimiter: ",", OnCollision: core.DelimiterCollisionAcknowledge}},
+		LossyDecisions: []core.LossyDecision{
+			{FieldPath: "tags", Strategy: core.StrategyJoinArray, Reason: core.LossReasonFormatLimit},
+		},
+	}
+
+	err := core.ValidateLossyDecisions(plan)
+	if err == nil {
+		t.Fatalf("expected error for missing join_collision decision")
+	}
+	if !strings.Contains(err.Error(), "join_collision") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestSplitStringsHonorsJoinEscaping(t *testing.T) {
+	original := []any{"a,b", `c\d`, `"e"`, ""}
+	cases := []struct {
+		join  core.JoinArrayRule
+		split core.SplitStringRule
+	}{
+		{
+			core.JoinArrayRule{Path: "tags", Delimiter: ",", OnCollision: core.DelimiterCollisionEscape, EscapeChar: `\`},
+			core.SplitStringRule{Path: "tags", Delimiter: ",", Escaping: core.DelimiterCollisionEscape, EscapeChar: `\`},
+		},
+		{
+			core.JoinArrayRule{Path: "tags", Delimiter: ",", OnCollision: core.DelimiterCollisionQuote},
+			core.SplitStringRule{Path: "tags", Delimiter: ",", Escaping: core.DelimiterCollisionQuote},
+		},
+	}
+	for _, testCase := range cases {
+		inputData := core.CanonicalData{Values: core.DataValues{Records: []core.Record{
+			{"tags": original},
+		}}}
+		joined, _, err := core.TransformData(inputData, core.ConversionPlan{
+			JoinArrays: []core.JoinArrayRule{testCase.join},
+			LossyDecisions: []core.LossyDecision{
+				{FieldPath: "tags", Strategy: core.StrategyJoinArray, Reason: core.LossReasonFormatLimit},
+			},
+		})
+		if err != nil {
+			t.Fatalf("%s join: %v", testCase.join.OnCollision, err)
+		}
+
+		split, _, err := core.TransformData(joined, core.ConversionPlan{SplitStrings: []core.SplitStringRule{testCase.split}})
+		if err != nil {
+			t.Fatalf("%s split: %v", testCase.split.Escaping, err)
+		}
+		if !reflect.DeepEqual(split.Values.Records[0]["tags"], original) {
+			t.Fatalf("%s: round trip mismatch\nexpected: %q\nactual: %q", testCase.split.Escaping, original, split.Values.Records[0]["tags"])
+		}
+	}
+}
+
+func TestSplitStringsRejectsMalformedEscaping(t *testing.T) {
+	cases := []struct {
+		value string
+		rule  core.SplitStringRule
+	}{
+		{`a\x`, core.SplitStringRule{Path: "tags", Delimiter: ",", Escaping: core.DelimiterCollisionEscape, EscapeChar: `\`}},
+		{`"a,b`, core.SplitStringRule{Path: "tags", Delimiter: ",", Escaping: core.DelimiterCollisionQuote}},
+		{`"a"b,c`, core.SplitStringRule{Path: "tags", Delimiter: ",", Escaping: core.DelimiterCollisionQuote}},
+	}
+	for _, testCase := range cases {
+		inputData := core.CanonicalData{Values: core.DataValues{Records: []core.Record{
+			{"tags": testCase.value},
+		}}}
+		_, _, err := core.TransformData(inputData, core.ConversionPlan{SplitStrings: []core.SplitStringRule{testCase.rule}})
+		if err == nil {
+			t.Fatalf("expected error for %q", testCase.value)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package core_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"reshape/internal/core"
+)
+
+func TestFieldOrderInputFollowsSourceOrder(t *testing.T) {
+	input := core.CanonicalData{
+		Shape: core.DataShape{SourceOrder: []string{"zeta", "user", "user.id", "user.name", "alpha"}},
+		Values: core.DataValues{Records: []core.Record{
+			{"zeta": "z", "user": map[string]any{"id": 1.0, "name": "Ada"}, "alpha": "a"},
+		}},
+	}
+	plan := core.ConversionPlan{
+		FieldOrder:    core.FieldOrderInput,
+		DefaultValues: []core.DefaultValueRule{{Path: "added", Value: "x"}},
+		RenameFields:  []core.RenameFieldRule{{Path: "user", To: "account"}},
+	}
+
+	output, _, err := core.TransformData(input, plan)
+	if err != nil {
+		t.Fatalf("transform: %v", err)
+	}
+	if output.Shape.Order != core.ShapeOrderDeclared {
+		t.Fatalf("expected declared order, got %q", output.Shape.Order)
+	}
+	paths := []string{}
+	for _, field := range output.Shape.Fields {
+		paths = append(paths, field.Path)
+	}
+	expected := []string{"zeta", "account", "account.id", "account.name", "alpha", "added"}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Fatalf("unexpected order\nexpected: %v\nactual: %v", expected, paths)
+	}
+}
+
+func TestFieldOrderDefaultsToSorted(t *testing.T) {
+	input := core.CanonicalData{
+		Shape:  core.DataShape{SourceOrder: []string{"b", "a"}},
+		Values: core.DataValues{Records: []core.Record{{"b": "1", "a": "2"}}},
+	}
+
+	output, _, err := core.TransformData(input, core.ConversionPlan{})
+	if err != nil {
+		t.Fatalf("transform: %v", err)
+	}
+	if output.Shape.Order == core.ShapeOrderDeclared || output.Shape.Fields[0].Path != "a" {
+		t.Fatalf("expected sorted shape, got %#v", output.Shape)
+	}
+
+	_, _, err = core.TransformData(input, core.ConversionPlan{FieldOrder: "random"})
+	if err == nil || !strings.Contains(err.Error(), "unsupported field_order: random") {
+		t.Fatalf("expected field_order error, got %v", err)
+	}
+}
@@ -32,7 +32,7 @@ func TestJSONToCSVHostile(t *testing.T) {
 
 	expectedCSV := strings.Join([]string{
 		"meta.active,notes,scores,tags,user.id,user.name",
-		"true,first,\"1,2,3\",\"a,b,c\",1,Ada",
+		"true,first,\"1,2,3\",\"a\\,b,c\",1,Ada",
 		"false,,4,solo,2,Linus",
 		"",
 	}, "\n")
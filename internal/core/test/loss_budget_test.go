@@ -0,0 +1,141 @@
+package core_test
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"reshape/internal/core"
+)
+
+func TestTransformEnforcesLossBudget(t *testing.T) {
+	input := core.CanonicalData{Values: core.DataValues{Records: []core.Record{
+		{"age": 30.0, "tags": []any{"a"}, "secret": "x"},
+		{"age": 31.0, "secret": "y"},
+	}}}
+	plan := core.ConversionPlan{
+		JoinArrays:    []core.JoinArrayRule{{Path: "tags", Delimiter: ","}},
+		TypeCoercions: []core.TypeCoercionRule{{Path: "age", TargetType: core.LogicalTypeString}},
+		DropFields:    []string{"secret"},
+		LossyDecisions: []core.LossyDecision{
+			{FieldPath: "tags", Strategy: core.StrategyJoinArray, Reason: core.LossReasonFormatLimit},
+			{FieldPath: "age", Strategy: core.StrategyCoerceType, Reason: core.LossReasonUserRequest},
+			{FieldPath: "secret", Strategy: core.StrategyDropField, Reason: core.LossReasonUserRequest},
+		},
+		LossBudget: &core.LossBudget{
+			ForbiddenStrategies: []core.Strategy{core.StrategyDropField},
+			StrategyLimits: []core.StrategyLimit{
+				{Strategy: core.StrategyJoinArray, MaxAffectedRecords: 1},
+				{Strategy: core.StrategyCoerceType, MaxAffectedRecords: 1},
+			},
+		},
+	}
+
+	_, warnings, err := core.TransformData(input, plan)
+	var budgetErr *core.LossBudgetError
+	if !errors.As(err, &budgetErr) {
+		t.Fatalf("expected loss budget error, got %v", err)
+	}
+	expected := []core.LossBudgetViolation{
+		{Rule: core.LossBudgetRuleForbiddenStrategy, Strategy: core.StrategyDropField, Code: core.WarningCodeDropField, Path: "secret", Actual: 2},
+		{Rule: core.LossBudgetRuleMaxAffectedRecords, Strategy: core.StrategyCoerceType, Limit: 1, Actual: 2},
+	}
+	if !reflect.DeepEqual(budgetErr.Violations, expected) {
+		t.Fatalf("unexpected violations\nexpected: %#v\nactual: %#v", expected, budgetErr.Violations)
+	}
+	expectedMessage := "loss budget exceeded: forbidden strategy drop_field at path secret (2 records); strategy coerce_type affected 2 records (max 1)"
+	if err.Error() != expectedMessage {
+		t.Fatalf("unexpected message\nexpected: %s\nactual: %s", expectedMessage, err.Error())
+	}
+	if len(warnings) != 3 {
+		t.Fatalf("expected warnings alongside budget error, got %#v", warnings)
+	}
+}
+
+func TestEvaluateLossBudgetFailOnWarning(t *testing.T) {
+	warnings := []core.Warning{
+		{Code: core.WarningCodeJoinArray, Path: "tags", AffectedCount: 3},
+	}
+
+	if err := core.EvaluateLossBudget(core.LossBudget{}, warnings, nil); err != nil {
+		t.Fatalf("expected empty budget to pass, got %v", err)
+	}
+
+	err := core.EvaluateLossBudget(core.LossBudget{FailOnWarning: true}, warnings, nil)
+	var budgetErr *core.LossBudgetError
+	if !errors.As(err, &budgetErr) {
+		t.Fatalf("expected loss budget error, got %v", err)
+	}
+	expected := []core.LossBudgetViolation{
+		{Rule: core.LossBudgetRuleFailOnWarning, Code: core.WarningCodeJoinArray, Path: "tags", Actual: 3},
+	}
+	if !reflect.DeepEqual(budgetErr.Violations, expected) {
+		t.Fatalf("unexpected violations: %#v", budgetErr.Violations)
+	}
+}
+
+func TestLossBudgetRejectsUnknownStrategies(t *testing.T) {
+	input := core.CanonicalData{Values: core.DataValues{Records: []core.Record{{"tags": []any{"a"}}}}}
+	plan := core.ConversionPlan{
+		JoinArrays:     []core.JoinArrayRule{{Path: "tags", Delimiter: ","}},
+		LossyDecisions: []core.LossyDecision{{FieldPath: "tags", Strategy: core.StrategyJoinArray, Reason: core.LossReasonFormatLimit}},
+	}
+	cases := []struct {
+		budget   core.LossBudget
+		expected string
+	}{
+		{
+			budget:   core.LossBudget{StrategyLimits: []core.StrategyLimit{{Strategy: "join_arrays", MaxAffectedRecords: 0}}},
+			expected: "loss_budget strategy_limits: unsupported strategy: join_arrays",
+		},
+		{
+			budget:   core.LossBudget{ForbiddenStrategies: []core.Strategy{"join-array"}},
+			expected: "loss_budget forbidden_strategies: unsupported strategy: join-array",
+		},
+	}
+	for _, tc := range cases {
+		budget := tc.budget
+		plan.LossBudget = &budget
+		if _, _, err := core.TransformData(input, plan); err == nil || err.Error() != tc.expected {
+			t.Fatalf("expected %q, got %v", tc.expected, err)
+		}
+		if _, err := core.NewRecordStream(plan, nil); err == nil || err.Error() != tc.expected {
+			t.Fatalf("expected stream to fail with %q, got %v", tc.expected, err)
+		}
+	}
+	if _, err := core.ParseStrategy("join_array"); err != nil {
+		t.Fatalf("expected join_array to parse, got %v", err)
+	}
+}
+
+func TestStrategyLimitsCountDistinctRecords(t *testing.T) {
+	input := core.CanonicalData{Values: core.DataValues{Records: []core.Record{
+		{"a": "1", "b": "2"},
+		{"a": json.Number("3"), "b": json.Number("4")},
+	}}}
+	plan := core.ConversionPlan{
+		TypeCoercions: []core.TypeCoercionRule{
+			{Path: "a", TargetType: core.LogicalTypeInteger},
+			{Path: "b", TargetType: core.LogicalTypeInteger},
+		},
+		LossyDecisions: []core.LossyDecision{
+			{FieldPath: "a", Strategy: core.StrategyCoerceType, Reason: core.LossReasonUserRequest},
+			{FieldPath: "b", Strategy: core.StrategyCoerceType, Reason: core.LossReasonUserRequest},
+		},
+		LossBudget: &core.LossBudget{StrategyLimits: []core.StrategyLimit{{Strategy: core.StrategyCoerceType, MaxAffectedRecords: 1}}},
+	}
+	for _, workers := range []int{1, 2} {
+		plan.Parallel = &core.ParallelOptions{Workers: workers, ChunkSize: 1}
+		if _, _, err := core.TransformData(input, plan); err != nil {
+			t.Fatalf("expected one record changed at two paths to fit a limit of 1 with %d workers, got %v", workers, err)
+		}
+	}
+
+	input.Values.Records[1] = core.Record{"a": "3", "b": json.Number("4")}
+	_, _, err := core.TransformData(input, plan)
+	var budgetErr *core.LossBudgetError
+	if !errors.As(err, &budgetErr) || budgetErr.Violations[0].Actual != 2 {
+		t.Fatalf("expected two affected records, got %v", err)
+	}
+}
@@ -0,0 +1,163 @@
+package core_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"reshape/internal/core"
+)
+
+func TestCoerceExactIntegersAndDecimals(t *testing.T) {
+	input := core.CanonicalData{Values: core.DataValues{Records: []core.Record{
+		{"id": "9007199254740993", "price": " 0.10 ", "count": json.Number("4")},
+	}}}
+	plan := core.ConversionPlan{
+		TypeCoercions: []core.TypeCoercionRule{
+			{Path: "count", TargetType: core.LogicalTypeDecimal},
+			{Path: "id", TargetType: core.LogicalTypeInteger},
+			{Path: "price", TargetType: core.LogicalTypeDecimal},
+		},
+		LossyDecisions: []core.LossyDecision{
+			{FieldPath: "count", Reason: core.LossReasonUserRequest, Strategy: core.StrategyCoerceType},
+			{FieldPath: "id", Reason: core.LossReasonUserRequest, Strategy: core.StrategyCoerceType},
+			{FieldPath: "price", Reason: core.LossReasonUserRequest, Strategy: core.StrategyCoerceType},
+		},
+	}
+
+	output, warnings, err := core.TransformData(input, plan)
+	if err != nil {
+		t.Fatalf("transform: %v", err)
+	}
+	expected := core.Record{"id": json.Number("9007199254740993"), "price": json.Number("0.10"), "count": json.Number("4")}
+	if !reflect.DeepEqual(output.Values.Records[0], expected) {
+		t.Fatalf("unexpected record\nexpected: %v\nactual: %v", expected, output.Values.Records[0])
+	}
+	for _, warning := range warnings {
+		if warning.Code == core.WarningCodeNarrowNumber {
+			t.Fatalf("exact coercions must not narrow: %#v", warning)
+		}
+	}
+	types := map[string]core.LogicalType{}
+	for _, field := range output.Shape.Fields {
+		types[field.Path] = field.Type
+	}
+	expectedTypes := map[string]core.LogicalType{"id": core.LogicalTypeInteger, "price": core.LogicalTypeDecimal, "count": core.LogicalTypeInteger}
+	if !reflect.DeepEqual(types, expectedTypes) {
+		t.Fatalf("unexpected types: %v", types)
+	}
+
+	_, _, err = core.TransformData(core.CanonicalData{Values: core.DataValues{Records: []core.Record{{"id": "1.5"}}}}, core.ConversionPlan{
+		TypeCoercions:  []core.TypeCoercionRule{{Path: "id", TargetType: core.LogicalTypeInteger}},
+		LossyDecisions: []core.LossyDecision{{FieldPath: "id", Reason: core.LossReasonUserRequest, Strategy: core.StrategyCoerceType}},
+	})
+	if err == nil || !strings.Contains(err.Error(), "non-integral value to integer: 1.5") {
+		t.Fatalf("expected integer coercion error, got %v", err)
+	}
+}
+
+func TestCoerceToNumberRequiresNarrowDecision(t *testing.T) {
+	input := core.CanonicalData{Values: core.DataValues{Records: []core.Record{
+		{"id": json.Number("9007199254740993")},
+		{"id": json.Number("0.1")},
+		{"id": json.Number("12")},
+	}}}
+	plan := core.ConversionPlan{
+		TypeCoercions:  []core.TypeCoercionRule{{Path: "id", TargetType: core.LogicalTypeNumber}},
+		LossyDecisions: []core.LossyDecision{{FieldPath: "id", Reason: core.LossReasonUserRequest, Strategy: core.StrategyCoerceType}},
+	}
+
+	_, _, err := core.TransformData(input, plan)
+	if err == nil || !strings.Contains(err.Error(), "requires narrow_number lossy_decisions entry for path: id") {
+		t.Fatalf("expected narrow_number error, got %v", err)
+	}
+
+	plan.LossyDecisions = append(plan.LossyDecisions, core.LossyDecision{
+		FieldPath: "id", Reason: core.LossReasonUserRequest, Strategy: core.StrategyNarrowNumber,
+	})
+	output, warnings, err := core.TransformData(input, plan)
+	if err != nil {
+		t.Fatalf("transform: %v", err)
+	}
+	if output.Values.Records[0]["id"] != 9007199254740992.0 || output.Values.Records[1]["id"] != 0.1 {
+		t.Fatalf("unexpected values: %v", output.Values.Records)
+	}
+	var narrowed *core.Warning
+	for index := range warnings {
+		if warnings[index].Code == core.WarningCodeNarrowNumber {
+			narrowed = &warnings[index]
+		}
+	}
+	if narrowed == nil || narrowed.AffectedCount != 1 || !reflect.DeepEqual(narrowed.RecordIndices, []int{0}) {
+		t.Fatalf("expected one narrow_number warning for record 0, got %#v", warnings)
+	}
+	if strategy, ok := core.StrategyForWarning(core.WarningCodeNarrowNumber); !ok || strategy != core.StrategyNarrowNumber {
+		t.Fatalf("unexpected strategy mapping: %v %v", strategy, ok)
+	}
+}
+
+func TestBuildShapeWidensExactNumbers(t *testing.T) {
+	shape := core.BuildShapeFromRecords([]core.Record{
+		{"a": json.Number("1"), "b": json.Number("1"), "c": json.Number("2")},
+		{"a": json.Number("1.5"), "b": 2.0, "c": json.Number("3")},
+	})
+	types := map[string]core.LogicalType{}
+	for _, field := range shape.Fields {
+		types[field.Path] = field.Type
+	}
+	expected := map[string]core.LogicalType{"a": core.LogicalTypeDecimal, "b": core.LogicalTypeNumber, "c": core.LogicalTypeInteger}
+	if !reflect.DeepEqual(types, expected) {
+		t.Fatalf("unexpected types\nexpected: %v\nactual: %v", expected, types)
+	}
+}
+
+func TestCoerceSkipsValuesAlreadyOfTargetType(t *testing.T) {
+	input := core.CanonicalData{Values: core.DataValues{Records: []core.Record{
+		{"n": json.Number("1"), "ok": true, "name": "ada"},
+		{"n": "2", "ok": "false", "name": "bob"},
+	}}}
+	plan := core.ConversionPlan{
+		TypeCoercions: []core.TypeCoercionRule{
+			{Path: "n", TargetType: core.LogicalTypeInteger},
+			{Path: "name", TargetType: core.LogicalTypeString},
+			{Path: "ok", TargetType: core.LogicalTypeBoolean},
+		},
+		LossyDecisions: []core.LossyDecision{
+			{FieldPath: "n", Reason: core.LossReasonUserRequest, Strategy: core.StrategyCoerceType},
+			{FieldPath: "name", Reason: core.LossReasonUserRequest, Strategy: core.StrategyCoerceType},
+			{FieldPath: "ok", Reason: core.LossReasonUserRequest, Strategy: core.StrategyCoerceType},
+		},
+	}
+	_, warnings, err := core.TransformData(input, plan)
+	if err != nil {
+		t.Fatalf("transform: %v", err)
+	}
+	counts := map[string]int{}
+	indices := map[string][]int{}
+	for _, warning := range warnings {
+		counts[warning.Path] = warning.AffectedCount
+		indices[warning.Path] = warning.RecordIndices
+	}
+	if !reflect.DeepEqual(counts, map[string]int{"n": 1, "name": 0, "ok": 1}) {
+		t.Fatalf("expected only changed values counted, got %v", counts)
+	}
+	if !reflect.DeepEqual(indices["n"], []int{1}) || !reflect.DeepEqual(indices["ok"], []int{1}) {
+		t.Fatalf("expected only record 1 affected, got %v", indices)
+	}
+}
+
+func TestCoerceErrorsNamePathAndRecord(t *testing.T) {
+	input := core.CanonicalData{Values: core.DataValues{Records: []core.Record{
+		{"items": []any{map[string]any{"n": json.Number("1")}}},
+		{"items": []any{map[string]any{"n": json.Number("2")}, map[string]any{"n": json.Number("2.5")}}},
+	}}}
+	plan := core.ConversionPlan{
+		TypeCoercions:  []core.TypeCoercionRule{{Path: "items[*].n", TargetType: core.LogicalTypeInteger}},
+		LossyDecisions: []core.LossyDecision{{FieldPath: "items[*].n", Reason: core.LossReasonUserRequest, Strategy: core.StrategyCoerceType}},
+	}
+	_, _, err := core.TransformData(input, plan)
+	if err == nil || err.Error() != "cannot coerce non-integral value to integer: 2.5 at path: items[*].n (record 1)" {
+		t.Fatalf("expected coercion error naming path and record, got %v", err)
+	}
+}
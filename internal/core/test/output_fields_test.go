@@ -0,0 +1,123 @@
+package core_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"reshape/internal/core"
+)
+
+func outputFieldsInput() core.CanonicalData {
+	return core.CanonicalData{Values: core.DataValues{Records: []core.Record{
+		{"id": 1.0, "name": "Ada", "user": map[string]any{"email": "ada@example.com", "age": 36.0}},
+		{"id": 2.0, "name": "Linus", "notes": "x"},
+	}}}
+}
+
+func shapeColumns(shape core.DataShape) []string {
+	columns := []string{}
+	for _, field := range shape.Fields {
+		column := field.Path
+		if field.Label != "" {
+			column += "=" + field.Label
+		}
+		columns = append(columns, column)
+	}
+	return columns
+}
+
+func TestOutputFieldsOrderAndAppend(t *testing.T) {
+	output, warnings, err := core.TransformData(outputFieldsInput(), core.ConversionPlan{
+		OutputFields: []core.OutputField{
+			{Path: "user.email", Header: "Email"},
+			{Path: "id", Header: "ID"},
+			{Path: "missing"},
+		},
+		UnlistedFields: core.UnlistedFieldsAppend,
+	})
+	if err != nil {
+		t.Fatalf("transform: %v", err)
+	}
+	if len(warnings) != 0 {
+		t.Fatalf("expected no warnings, got %#v", warnings)
+	}
+	if output.Shape.Order != core.ShapeOrderDeclared {
+		t.Fatalf("expected declared order, got %q", output.Shape.Order)
+	}
+	expected := []string{"user.email=Email", "id=ID", "missing", "name", "notes", "user.age"}
+	if actual := shapeColumns(output.Shape); !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("unexpected columns\nexpected: %v\nactual: %v", expected, actual)
+	}
+}
+
+func TestOutputFieldsRejectUnlistedByDefault(t *testing.T) {
+	_, _, err := core.TransformData(outputFieldsInput(), core.ConversionPlan{
+		OutputFields: []core.OutputField{{Path: "id"}, {Path: "name"}},
+	})
+	if err == nil || !strings.Contains(err.Error(), "fields not listed in output_fields: notes, user") {
+		t.Fatalf("expected unlisted error, got %v", err)
+	}
+}
+
+func TestOutputFieldsDropUnlisted(t *testing.T) {
+	plan := core.ConversionPlan{
+		OutputFields:   []core.OutputField{{Path: "name"}, {Path: "user.email"}},
+		UnlistedFields: core.UnlistedFieldsDrop,
+		LossyDecisions: []core.LossyDecision{
+			{FieldPath: "id", Reason: core.LossReasonUserRequest, Strategy: core.StrategyDropField},
+			{FieldPath: "notes", Reason: core.LossReasonUserRequest, Strategy: core.StrategyDropField},
+		},
+	}
+	_, _, err := core.TransformData(outputFieldsInput(), plan)
+	if err == nil || !strings.Contains(err.Error(), "drop_field lossy_decisions entry for path: user.age") {
+		t.Fatalf("expected missing decision error, got %v", err)
+	}
+
+	plan.LossyDecisions = append(plan.LossyDecisions, core.LossyDecision{
+		FieldPath: "user.age", Reason: core.LossReasonUserRequest, Strategy: core.StrategyDropField,
+	})
+	output, warnings, err := core.TransformData(outputFieldsInput(), plan)
+	if err != nil {
+		t.Fatalf("transform: %v", err)
+	}
+	expectedRecords := []core.Record{
+		{"name": "Ada", "user": map[string]any{"email": "ada@example.com"}},
+		{"name": "Linus"},
+	}
+	if !reflect.DeepEqual(output.Values.Records, expectedRecords) {
+		t.Fatalf("unexpected records\nexpected: %v\nactual: %v", expectedRecords, output.Values.Records)
+	}
+	if actual := shapeColumns(output.Shape); !reflect.DeepEqual(actual, []string{"name", "user.email"}) {
+		t.Fatalf("unexpected columns: %v", actual)
+	}
+	counts := map[string]int{}
+	for _, warning := range warnings {
+		if warning.Code != core.WarningCodeDropField {
+			t.Fatalf("unexpected warning: %#v", warning)
+		}
+		counts[warning.Path] = warning.AffectedCount
+	}
+	if !reflect.DeepEqual(counts, map[string]int{"id": 2, "notes": 1, "user.age": 1}) {
+		t.Fatalf("unexpected drop counts: %v", counts)
+	}
+}
+
+func TestOutputFieldsValidation(t *testing.T) {
+	cases := []struct {
+		plan     core.ConversionPlan
+		expected string
+	}{
+		{core.ConversionPlan{UnlistedFields: core.UnlistedFieldsDrop}, "unlisted_fields requires output_fields"},
+		{core.ConversionPlan{OutputFields: []core.OutputField{{Path: "id"}, {Path: "id"}}}, "listed twice: id"},
+		{core.ConversionPlan{OutputFields: []core.OutputField{{Path: "id", Header: "x"}, {Path: "name", Header: "x"}}}, "header is used twice: x"},
+		{core.ConversionPlan{OutputFields: []core.OutputField{{Path: "user"}, {Path: "user.email"}}}, "overlaps another listed path: user.email"},
+		{core.ConversionPlan{OutputFields: []core.OutputField{{Path: "id"}}, UnlistedFields: "keep"}, "unsupported unlisted_fields: keep"},
+	}
+	for _, testCase := range cases {
+		_, _, err := core.TransformData(outputFieldsInput(), testCase.plan)
+		if err == nil || !strings.Contains(err.Error(), testCase.expected) {
+			t.Fatalf("expected %q, got %v", testCase.expected, err)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package core_test
+
+import (
+	"encoding/json"
+	"strconv"
+	"testing"
+
+	"reshape/internal/core"
+)
+
+func parallelTestRecords(count int) []core.Record {
+	records := make([]core.Record, count)
+	for index := range records {
+		tags := []any{"t" + strconv.Itoa(index%4), "shared"}
+		if index%7 == 0 {
+			tags = append(tags, "a,b")
+		}
+		record := core.Record{
+			"id":     strconv.Itoa(index),
+			"meta":   map[string]any{"source": "s" + strconv.Itoa(index%3), "batch": strconv.Itoa(index / 100)},
+			"tags":   tags,
+			"items":  []any{"x", "y"}[:1+index%2],
+			"list":   " p | q ",
+			"name":   "n" + strconv.Itoa(index),
+			"secret": "hidden",
+		}
+		if index%5 != 0 {
+			record["note"] = "present"
+		}
+		records[index] = record
+	}
+	return records
+}
+
+func parallelTestPlan() core.ConversionPlan {
+	decision := func(path string, strategy core.Strategy) core.LossyDecision {
+		return core.LossyDecision{FieldPath: path, Reason: core.LossReasonUserRequest, Strategy: strategy}
+	}
+	return core.ConversionPlan{
+		FlattenFields: []string{"meta"},
+		JoinArrays:    []core.JoinArrayRule{{Path: "tags", Delimiter: ",", OnCollision: core.DelimiterCollisionAcknowledge}},
+		SplitStrings:  []core.SplitStringRule{{Path: "list", Delimiter: "|", Trim: true}},
+		ExplodeArrays: []string{"items"},
+		TypeCoercions: []core.TypeCoercionRule{{Path: "id", TargetType: core.LogicalTypeInteger}},
+		DefaultValues: []core.DefaultValueRule{{Path: "note", Value: "missing"}},
+		DropFields:    []string{"secret"},
+		RenameFields:  []core.RenameFieldRule{{Path: "name", To: "label"}},
+		LossyDecisions: []core.LossyDecision{
+			decision("tags", core.StrategyJoinArray),
+			decision("tags", core.StrategyJoinCollision),
+			decision("list", core.StrategySplitString),
+			decision("id", core.StrategyCoerceType),
+			decision("secret", core.StrategyDropField),
+		},
+	}
+}
+
+func marshalTransform(t *testing.T, records []core.Record, plan core.ConversionPlan) (string, error) {
+	t.Helper()
+	output, warnings, err := core.TransformData(core.CanonicalData{Values: core.DataValues{Records: records}}, plan)
+	encoded, marshalErr := json.Marshal(struct {
+		Output   core.CanonicalData `json:"output"`
+		Warnings []core.Warning     `json:"warnings"`
+	}{output, warnings})
+	if marshalErr != nil {
+		t.Fatalf("marshal: %v", marshalErr)
+	}
+	return string(encoded), err
+}
+
+func TestParallelTransformMatchesSequential(t *testing.T) {
+	records := parallelTestRecords(500)
+	expected, err := marshalTransform(t, records, parallelTestPlan())
+	if err != nil {
+		t.Fatalf("sequential transform: %v", err)
+	}
+	for _, options := range []core.ParallelOptions{
+		{Workers: 1},
+		{Workers: 2, ChunkSize: 1},
+		{Workers: 4, ChunkSize: 7},
+		{Workers: 8, ChunkSize: 64},
+		{Workers: 3},
+	} {
+		options := options
+		plan := parallelTestPlan()
+		plan.Parallel = &options
+		actual, err := marshalTransform(t, records, plan)
+		if err != nil {
+			t.Fatalf("parallel transform %+v: %v", options, err)
+		}
+		if actual != expected {
+			t.Fatalf("parallel output %+v differs from sequential\nsequential: %s\nparallel:   %s", options, expected, actual)
+		}
+	}
+}
+
+func TestParallelTransformReturnsFirstErrorByRecordIndex(t *testing.T) {
+	records := make([]core.Record, 100)
+	for index := range records {
+		records[index] = core.Record{"flag": "true"}
+	}
+	records[9]["flag"] = []any{"yes"}
+	records[60]["flag"] = "maybe"
+	plan := core.ConversionPlan{
+		TypeCoercions:  []core.TypeCoercionRule{{Path: "flag", TargetType: core.LogicalTypeBoolean}},
+		LossyDecisions: []core.LossyDecision{{FieldPath: "flag", Reason: core.LossReasonUserRequest, Strategy: core.StrategyCoerceType}},
+	}
+	_, sequentialErr := marshalTransform(t, records, plan)
+	if sequentialErr == nil || sequentialErr.Error() != "cannot coerce value to boolean at path: flag (record 9)" {
+		t.Fatalf("unexpected sequential error: %v", sequentialErr)
+	}
+	for _, chunkSize := range []int{1, 5, 10, 50} {
+		plan.Parallel = &core.ParallelOptions{Workers: 4, ChunkSize: chunkSize}
+		for attempt := 0; attempt < 20; attempt++ {
+			_, err := marshalTransform(t, records, plan)
+			if err == nil || err.Error() != sequentialErr.Error() {
+				t.Fatalf("chunk size %d: expected %q, got %v", chunkSize, sequentialErr, err)
+			}
+		}
+	}
+}
+
+func TestParallelOptionsValidation(t *testing.T) {
+	cases := map[string]core.ParallelOptions{
+		"parallel workers must be at least 1":      {Workers: 0},
+		"parallel chunk_size must not be negative": {Workers: 2, ChunkSize: -1},
+	}
+	for expected, options := range cases {
+		options := options
+		_, _, err := core.TransformData(core.CanonicalData{}, core.ConversionPlan{Parallel: &options})
+		if err == nil || err.Error() != expected {
+			t.Fatalf("expected %q, got %v", expected, err)
+		}
+	}
+}
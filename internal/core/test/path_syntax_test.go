@@ -0,0 +1,148 @@
+package core_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"reshape/internal/core"
+)
+
+func TestParsePathPrintsCanonicalForm(t *testing.T) {
+	cases := map[string]string{
+		"user.name":                    "user.name",
+		"  user.name ":                 "user.name",
+		`domains["example.com"].owner`: `domains["example.com"].owner`,
+		`["a.b"]["c"]`:                 `["a.b"]["c"]`,
+		`quote["say \"hi\""]`:          `quote["say \"hi\""]`,
+		`slash["a\\b"].c`:              `slash["a\\b"].c`,
+		`empty[""]`:                    `empty[""]`,
+	}
+	for input, expected := range cases {
+		path, err := core.ParsePath(input)
+		if err != nil {
+			t.Fatalf("parse %q: %v", input, err)
+		}
+		if path.String() != expected {
+			t.Fatalf("expected %q to print as %q, got %q", input, expected, path.String())
+		}
+		reparsed, err := core.ParsePath(path.String())
+		if err != nil || reparsed.String() != expected {
+			t.Fatalf("expected %q to reparse unchanged, got %q (%v)", expected, reparsed.String(), err)
+		}
+	}
+}
+
+func TestParsePathErrors(t *testing.T) {
+	cases := map[string]string{
+		"":             "path is empty",
+		"a..b":         "path key is empty at offset 2: a..b",
+		"a.":           "path key is empty at offset 2: a.",
+		".a":           "path key is empty at offset 0: .a",
+		"a.[\"b\"]":    `path key is empty at offset 2: a.["b"]`,
+		"a]":           "path has ']' without '[' at offset 1: a]",
+		`a"b`:          `path key with " must be quoted in brackets at offset 1: a"b`,
+		"a[b]":         "path bracket must hold a quoted key, an index, or * at offset 1: a[b]",
+		`a["b\n"]`:     `path escape must be \" or \\ at offset 4: a["b\n"]`,
+		`a["b"`:        `path bracket is not closed at offset 5: a["b"`,
+		`a["b`:         `path quoted key is not closed: a["b`,
+		`a["b"]c`:      `path expects '.' or '[' after ']' at offset 6: a["b"]c`,
+		`a["b"]x["c"]`: `path expects '.' or '[' after ']' at offset 6: a["b"]x["c"]`,
+	}
+	for input, expected := range cases {
+		_, err := core.ParsePath(input)
+		if err == nil || err.Error() != expected {
+			t.Fatalf("expected %q to fail with %q, got %v", input, expected, err)
+		}
+	}
+}
+
+func TestQuotedPathIsExact(t *testing.T) {
+	record := core.Record{
+		"a.b": "literal",
+		"a":   map[string]any{"b": "nested"},
+	}
+	cases := map[string]any{
+		"a.b":        "literal",
+		`["a.b"]`:    "literal",
+		`a["b"]`:     "nested",
+		`["a"].b`:    "nested",
+		`["a"]["b"]`: "nested",
+	}
+	for path, expected := range cases {
+		value, exists, err := core.ValueAtPath(record, path)
+		if err != nil || !exists || value != expected {
+			t.Fatalf("expected %s to find %v, got %v %v %v", path, expected, value, exists, err)
+		}
+	}
+
+	if err := core.SetValueAtPath(record, `["a.c"]`, "set"); err != nil {
+		t.Fatalf("set quoted path: %v", err)
+	}
+	if record["a.c"] != "set" {
+		t.Fatalf("expected quoted path to set the literal key, got %v", record)
+	}
+	if _, _, err := core.ValueAtPath(record, "a[b"); err == nil {
+		t.Fatalf("expected malformed path to fail")
+	}
+}
+
+func TestShapePathsQuoteKeysHoldingFields(t *testing.T) {
+	records := []core.Record{{
+		"domains":   map[string]any{"example.com": map[string]any{"owner": "ann"}},
+		"user.name": "x",
+		"odd[key]":  "y",
+		"list.of":   []any{map[string]any{"id": "1"}},
+	}}
+	shape := core.BuildShapeFromRecords(records)
+	paths := []string{}
+	for _, field := range shape.Fields {
+		paths = append(paths, field.Path)
+	}
+	expected := []string{
+		`["list.of"]`,
+		`["list.of"][*].id`,
+		`["odd[key]"]`,
+		"domains",
+		`domains["example.com"]`,
+		`domains["example.com"].owner`,
+		"user.name",
+	}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Fatalf("expected shape paths %v, got %v", expected, paths)
+	}
+}
+
+func TestPlanRulesAcceptQuotedPaths(t *testing.T) {
+	data := core.CanonicalData{Values: core.DataValues{Records: []core.Record{{
+		"domains": map[string]any{
+			"example.com": map[string]any{"owner": "ann", "tier": "1"},
+		},
+		"domains.example.com": "flat",
+	}}}}
+	plan := core.ConversionPlan{
+		TypeCoercions: []core.TypeCoercionRule{{Path: ` domains["example.com"].tier `, TargetType: core.LogicalTypeInteger}},
+		RenameFields:  []core.RenameFieldRule{{Path: `domains["example.com"].owner`, To: "owner"}},
+		DropFields:    []string{`["domains.example.com"]`},
+		LossyDecisions: []core.LossyDecision{
+			{FieldPath: `domains["example.com"].tier`, Reason: core.LossReasonUserRequest, Strategy: core.StrategyCoerceType},
+			{FieldPath: `["domains.example.com"]`, Reason: core.LossReasonUserRequest, Strategy: core.StrategyDropField},
+		},
+	}
+	result, _, err := core.TransformData(data, plan)
+	if err != nil {
+		t.Fatalf("transform: %v", err)
+	}
+	expected := core.Record{
+		"domains": map[string]any{"example.com": map[string]any{"tier": json.Number("1")}},
+		"owner":   "ann",
+	}
+	if !reflect.DeepEqual(result.Values.Records[0], expected) {
+		t.Fatalf("expected %v, got %v", expected, result.Values.Records[0])
+	}
+
+	plan.DropFields = []string{"domains..example"}
+	if _, _, err := core.TransformData(data, plan); err == nil || err.Error() != "drop_fields: path key is empty at offset 8: domains..example" {
+		t.Fatalf("expected drop_fields path error, got %v", err)
+	}
+}
@@ -1,6 +1,8 @@
 package core_test
 
 import (
+	"errors"
+	"reflect"
 	"strings"
 	"testing"
 
@@ -25,6 +27,60 @@ func TestValueAtPathPrefersLiteralKey(t *testing.T) {
 	}
 }
 
+func mustParsePath(t *testing.T, text string) core.Path {
+	t.Helper()
+	path, err := core.ParsePath(text)
+	if err != nil {
+		t.Fatalf("parse path %q: %v", text, err)
+	}
+	return path
+}
+
+func TestCompiledPathMatchesValueAtPath(t *testing.T) {
+	record := core.Record{
+		"a": map[string]any{
+			"b.c": "literal",
+			"b":   map[string]any{"c": "nested", "d": "deep"},
+		},
+		"x.y": map[string]any{"z": "under literal"},
+		"n":   "scalar",
+	}
+	for _, path := range []string{"a.b.c", "a.b.d", "a.b", " x.y.z ", "x.y", "a.missing", "n", "n.more"} {
+		value, exists, err := mustParsePath(t, path).Value(record)
+		expectedValue, expectedExists, expectedErr := core.ValueAtPath(record, path)
+		if !reflect.DeepEqual(value, expectedValue) || exists != expectedExists || (err == nil) != (expectedErr == nil) {
+			t.Fatalf("path %q: compiled (%v, %v, %v), string (%v, %v, %v)", path, value, exists, err, expectedValue, expectedExists, expectedErr)
+		}
+	}
+	if value, _, _ := mustParsePath(t, "a.b.c").Value(record); value != "literal" {
+		t.Fatalf("expected nested literal key to win, got %v", value)
+	}
+	if _, _, err := mustParsePath(t, "n.more").Value(record); err == nil || err.Error() != "path segment is not an object: more" {
+		t.Fatalf("expected non-object error, got %v", err)
+	}
+	if _, err := core.ParsePath("  "); err == nil || err.Error() != "path is empty" {
+		t.Fatalf("expected empty path error, got %v", err)
+	}
+	if path := mustParsePath(t, " x.y.z "); path.String() != "x.y.z" {
+		t.Fatalf("expected trimmed path, got %q", path.String())
+	}
+}
+
+func TestCompiledPathSetPrefersLiteralKey(t *testing.T) {
+	record := core.Record{"a": map[string]any{"b.c": "literal"}}
+	path := mustParsePath(t, "a.b.c")
+	if err := path.Set(record, "updated"); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	if err := mustParsePath(t, "a.e.f").Set(record, "created"); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	expected := core.Record{"a": map[string]any{"b.c": "updated", "e": map[string]any{"f": "created"}}}
+	if !reflect.DeepEqual(record, expected) {
+		t.Fatalf("unexpected record\nexpected: %v\nactual: %v", expected, record)
+	}
+}
+
 func TestTransformFlattensNestedObject(t *testing.T) {
 	input := core.CanonicalData{Values: core.DataValues{Records: []core.Record{
 		{"user": map[string]any{"name": "Ada"}},
@@ -57,3 +113,173 @@ func TestTransformRejectsFlattenNonObject(t *testing.T) {
 		t.Fatalf("unexpected error: %v", err)
 	}
 }
+
+func TestTransformUnflattensDottedKeys(t *testing.T) {
+	input := core.CanonicalData{Values: core.DataValues{Records: []core.Record{
+		{"id": "1", "customer.name": "Ada", "customer.address.city": "London", "customer.address.zip": "N1"},
+		{"id": "2", "customer.name": "Linus"},
+	}}}
+	plan := core.ConversionPlan{UnflattenFields: []string{"customer", "customer.address"}}
+
+	output, warnings, err := core.TransformData(input, plan)
+	if err != nil {
+		t.Fatalf("transform: %v", err)
+	}
+	if len(warnings) != 0 {
+		t.Fatalf("expected no warnings, got %v", warnings)
+	}
+
+	expected := []core.Record{
+		{"id": "1", "customer": map[string]any{"name": "Ada", "address": map[string]any{"city": "London", "zip": "N1"}}},
+		{"id": "2", "customer": map[string]any{"name": "Linus"}},
+	}
+	if !reflect.DeepEqual(output.Values.Records, expected) {
+		t.Fatalf("unexpected records\nexpected: %v\nactual: %v", expected, output.Values.Records)
+	}
+	if field := fieldByPath(output.Shape, "customer.address.city"); field.Type != core.LogicalTypeString {
+		t.Fatalf("expected nested shape path, got %#v", output.Shape.Fields)
+	}
+}
+
+func TestTransformUnflattenRejectsCollisions(t *testing.T) {
+	cases := []core.Record{
+		{"user": "Ada", "user.name": "Ada"},
+		{"user": map[string]any{"name": "Ada"}, "user.name": "Linus"},
+		{"user.address.city": "Oslo", "user.name": "Ada"},
+	}
+	for _, record := range cases {
+		input := core.CanonicalData{Values: core.DataValues{Records: []core.Record{record}}}
+		plan := core.ConversionPlan{UnflattenFields: []string{"user"}}
+		if _, exists := record["user.address.city"]; exists {
+			plan.UnflattenFields = []string{"user.address"}
+		}
+
+		_, _, err := core.TransformData(input, plan)
+		if err == nil {
+			t.Fatalf("expected collision error for %v", record)
+		}
+		if !strings.Contains(err.Error(), "collides") {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+}
+
+// deepPathRecords builds records with values nested depth objects deep under
+// each of width top-level keys.
+func deepPathRecords(count int, width int, depth int) ([]core.Record, []string) {
+	paths := make([]string, width)
+	for column := range paths {
+		segments := make([]string, depth)
+		for level := range segments {
+			segments[level] = "level" + string(rune('a'+level))
+		}
+		segments[0] = "column" + string(rune('a'+column))
+		paths[column] = strings.Join(segments, ".")
+	}
+	records := make([]core.Record, count)
+	for index := range records {
+		record := core.Record{}
+		for _, path := range paths {
+			if err := core.SetValueAtPath(record, path, "42"); err != nil {
+				panic(err)
+			}
+		}
+		records[index] = record
+	}
+	return records, paths
+}
+
+// splitPathValue is the resolver ValueAtPath used before paths were
+// compiled: it splits the path on every call and, at each level, joins the
+// remaining segments to try the literal key first. It is kept as the
+// baseline BenchmarkSplitPathValue measures.
+func splitPathValue(record core.Record, path string) (any, bool, error) {
+	segments := strings.Split(strings.TrimSpace(path), ".")
+	current := any(record)
+	for index, segment := range segments {
+		var currentMap map[string]any
+		switch typed := current.(type) {
+		case map[string]any:
+			currentMap = typed
+		case core.Record:
+			currentMap = typed
+		default:
+			return nil, false, errors.New("path segment is not an object: " + segment)
+		}
+		if value, exists := currentMap[strings.Join(segments[index:], ".")]; exists {
+			return value, true, nil
+		}
+		value, exists := currentMap[segment]
+		if !exists {
+			return nil, false, nil
+		}
+		current = value
+	}
+	return current, true, nil
+}
+
+func TestSplitPathValueMatchesValueAtPath(t *testing.T) {
+	records, paths := deepPathRecords(1, 2, 8)
+	records[0]["columna.levelb"] = map[string]any{"levelc": "literal"}
+	paths = append(paths, "columna.levelb.levelc", "columnb.levelb.missing")
+	for _, path := range paths {
+		expected, expectedExists, expectedErr := splitPathValue(records[0], path)
+		value, exists, err := core.ValueAtPath(records[0], path)
+		if !reflect.DeepEqual(value, expected) || exists != expectedExists || (err == nil) != (expectedErr == nil) {
+			t.Fatalf("expected %s to resolve as %v %v %v, got %v %v %v", path, expected, expectedExists, expectedErr, value, exists, err)
+		}
+	}
+}
+
+func BenchmarkSplitPathValue(b *testing.B) {
+	records, paths := deepPathRecords(1, 1, 8)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, _, err := splitPathValue(records[0], paths[0]); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkValueAtPath(b *testing.B) {
+	records, paths := deepPathRecords(1, 1, 8)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, _, err := core.ValueAtPath(records[0], paths[0]); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCompiledPathValue(b *testing.B) {
+	records, paths := deepPathRecords(1, 1, 8)
+	path, err := core.ParsePath(paths[0])
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, _, err := path.Value(records[0]); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkTransformDeepPaths(b *testing.B) {
+	plan := core.ConversionPlan{}
+	_, paths := deepPathRecords(0, 4, 6)
+	for _, path := range paths {
+		plan.TypeCoercions = append(plan.TypeCoercions, core.TypeCoercionRule{Path: path, TargetType: core.LogicalTypeInteger})
+		plan.DefaultValues = append(plan.DefaultValues, core.DefaultValueRule{Path: path, Value: "0"})
+		plan.LossyDecisions = append(plan.LossyDecisions, core.LossyDecision{FieldPath: path, Reason: core.LossReasonUserRequest, Strategy: core.StrategyCoerceType})
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		records, _ := deepPathRecords(1000, 4, 6)
+		b.StartTimer()
+		if _, _, err := core.TransformData(core.CanonicalData{Values: core.DataValues{Records: records}}, plan); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,275 @@
+package core_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"reshape/internal/core"
+)
+
+func TestParsePathIndexAndWildcard(t *testing.T) {
+	cases := map[string]string{
+		"contacts[0]":        "contacts[0]",
+		"items[*].price":     "items[*].price",
+		"prices.*":           "prices[*]",
+		"*.name":             "[*].name",
+		"matrix[1][*]":       "matrix[1][*]",
+		`stars["*"]`:         `stars["*"]`,
+		`a["b.c"][2].d`:      `a["b.c"][2].d`,
+		"rows[*].cells[0].v": "rows[*].cells[0].v",
+	}
+	for input, expected := range cases {
+		if path := mustParsePath(t, input); path.String() != expected {
+			t.Fatalf("expected %q to print as %q, got %q", input, expected, path.String())
+		}
+	}
+	for _, input := range []string{"a[-1]", "a[]", "a[*", "a[1x]", "a[*]b"} {
+		if _, err := core.ParsePath(input); err == nil {
+			t.Fatalf("expected %q to fail", input)
+		}
+	}
+	if key := core.AppendPathKey("stars", "*", false); key != `stars["*"]` {
+		t.Fatalf("expected a * key to be quoted, got %s", key)
+	}
+}
+
+func TestValuesAtPathFollowsIndicesAndWildcards(t *testing.T) {
+	record := core.Record{
+		"items":  []any{map[string]any{"price": "1"}, map[string]any{"qty": 2}, map[string]any{"price": "3"}},
+		"prices": map[string]any{"us": "12", "eu": "10"},
+		"matrix": []any{[]any{1, 2}, []any{3}},
+	}
+	cases := map[string][]any{
+		"items[*].price": {"1", "3"},
+		"prices.*":       {"10", "12"},
+		"matrix[*][*]":   {1, 2, 3},
+		"matrix[1][0]":   {3},
+		"items[5].price": {},
+		"missing[*]":     {},
+	}
+	for path, expected := range cases {
+		values, err := core.ValuesAtPath(record, path)
+		if err != nil {
+			t.Fatalf("values at %s: %v", path, err)
+		}
+		if !reflect.DeepEqual(values, expected) {
+			t.Fatalf("expected %s to match %v, got %v", path, expected, values)
+		}
+	}
+
+	if value, exists, err := core.ValueAtPath(record, "items[2].price"); err != nil || !exists || value != "3" {
+		t.Fatalf("expected indexed value, got %v %v %v", value, exists, err)
+	}
+	if _, _, err := core.ValueAtPath(record, "items[*].price"); err == nil || err.Error() != "path with a wildcard has many values: items[*].price" {
+		t.Fatalf("expected wildcard value error, got %v", err)
+	}
+	if _, err := core.ValuesAtPath(record, "prices[0]"); err == nil || err.Error() != "path segment is not an array: [0]" {
+		t.Fatalf("expected index on object error, got %v", err)
+	}
+}
+
+func TestSetAndDeleteValueAtPathWithIndices(t *testing.T) {
+	record := core.Record{
+		"items":    []any{map[string]any{"price": "1", "secret": "x"}, map[string]any{"secret": "y"}},
+		"contacts": []any{"a", "b", "c"},
+	}
+	if err := core.SetValueAtPath(record, "items[*].currency", "EUR"); err != nil {
+		t.Fatalf("set wildcard: %v", err)
+	}
+	if err := core.SetValueAtPath(record, "contacts[1]", "B"); err != nil {
+		t.Fatalf("set index: %v", err)
+	}
+	if err := core.SetValueAtPath(record, "contacts[3]", "d"); err == nil || err.Error() != "path index is out of range: contacts[3]" {
+		t.Fatalf("expected out of range error, got %v", err)
+	}
+	removed, err := core.DeleteValueAtPath(record, "items[*].secret")
+	if err != nil || !reflect.DeepEqual(removed, []any{"x", "y"}) {
+		t.Fatalf("expected removed secrets, got %v %v", removed, err)
+	}
+	if _, err := core.DeleteValueAtPath(record, "contacts[0]"); err != nil {
+		t.Fatalf("delete index: %v", err)
+	}
+	expected := core.Record{
+		"items":    []any{map[string]any{"price": "1", "currency": "EUR"}, map[string]any{"currency": "EUR"}},
+		"contacts": []any{"B", "c"},
+	}
+	if !reflect.DeepEqual(record, expected) {
+		t.Fatalf("expected %v, got %v", expected, record)
+	}
+	if _, err := core.DeleteValueAtPath(record, "contacts[*]"); err != nil {
+		t.Fatalf("delete wildcard: %v", err)
+	}
+	if !reflect.DeepEqual(record["contacts"], []any{}) {
+		t.Fatalf("expected every item removed, got %v", record["contacts"])
+	}
+}
+
+func TestPlanRulesApplyToEveryMatch(t *testing.T) {
+	data := core.CanonicalData{Values: core.DataValues{Records: []core.Record{
+		{
+			"items":    []any{map[string]any{"price": "1.50", "cost": "1"}, map[string]any{"price": "2"}},
+			"contacts": []any{"first", "second"},
+		},
+		{"items": []any{}, "contacts": []any{}},
+	}}}
+	plan := core.ConversionPlan{
+		TypeCoercions: []core.TypeCoercionRule{{Path: "items[*].price", TargetType: core.LogicalTypeDecimal}},
+		DefaultValues: []core.DefaultValueRule{{Path: "items.*.qty", Value: 1}},
+		DropFields:    []string{"contacts[0]"},
+		RenameFields:  []core.RenameFieldRule{{Path: "items[*].cost", To: "items[*].unit_cost"}},
+		LossyDecisions: []core.LossyDecision{
+			{FieldPath: "items[*].price", Reason: core.LossReasonUserRequest, Strategy: core.StrategyCoerceType},
+			{FieldPath: "contacts[0]", Reason: core.LossReasonUserRequest, Strategy: core.StrategyDropField},
+		},
+	}
+	result, warnings, err := core.TransformData(data, plan)
+	if err != nil {
+		t.Fatalf("transform: %v", err)
+	}
+	expected := []core.Record{
+		{
+			"items": []any{
+				map[string]any{"price": json.Number("1.50"), "unit_cost": "1", "qty": 1},
+				map[string]any{"price": json.Number("2"), "qty": 1},
+			},
+			"contacts": []any{"second"},
+		},
+		{"items": []any{}, "contacts": []any{}},
+	}
+	if !reflect.DeepEqual(result.Values.Records, expected) {
+		t.Fatalf("expected %v, got %v", expected, result.Values.Records)
+	}
+
+	coercion := findWarning(t, warnings, core.WarningCodeCoerceType)
+	if coercion.Path != "items[*].price" || coercion.AffectedCount != 1 {
+		t.Fatalf("expected one affected record for the wildcard coercion, got %#v", coercion)
+	}
+	if !reflect.DeepEqual(coercion.Samples[0].Before, []any{"1.50", "2"}) {
+		t.Fatalf("expected samples to list every match, got %#v", coercion.Samples[0])
+	}
+	if drop := findWarning(t, warnings, core.WarningCodeDropField); drop.Path != "contacts[0]" || drop.Samples[0].Before != "first" {
+		t.Fatalf("expected indexed drop warning, got %#v", drop)
+	}
+}
+
+func TestDefaultValuesFillOnlyExistingIndices(t *testing.T) {
+	data := core.CanonicalData{Values: core.DataValues{Records: []core.Record{
+		{"contacts": []any{"a", nil}, "items": []any{}},
+	}}}
+	plan := core.ConversionPlan{DefaultValues: []core.DefaultValueRule{
+		{Path: "contacts[1]", Value: "b"},
+		{Path: "items[*].qty", Value: 1},
+	}}
+	result, _, err := core.TransformData(data, plan)
+	if err != nil {
+		t.Fatalf("transform: %v", err)
+	}
+	expected := core.Record{"contacts": []any{"a", "b"}, "items": []any{}}
+	if !reflect.DeepEqual(result.Values.Records[0], expected) {
+		t.Fatalf("expected %v, got %v", expected, result.Values.Records[0])
+	}
+
+	for _, path := range []string{"contacts[5]", "new[0]"} {
+		plan := core.ConversionPlan{DefaultValues: []core.DefaultValueRule{{Path: path, Value: "x"}}}
+		expected := "default_values index is out of range for path: " + path
+		if _, _, err := core.TransformData(data, plan); err == nil || err.Error() != expected {
+			t.Fatalf("expected %q, got %v", expected, err)
+		}
+	}
+}
+
+func findWarning(t *testing.T, warnings []core.Warning, code core.WarningCode) core.Warning {
+	t.Helper()
+	for _, warning := range warnings {
+		if warning.Code == code {
+			return warning
+		}
+	}
+	t.Fatalf("expected %s warning in %#v", code, warnings)
+	return core.Warning{}
+}
+
+func TestPlanRulesRejectUnsupportedIndexedPaths(t *testing.T) {
+	data := core.CanonicalData{Values: core.DataValues{Records: []core.Record{{"items": []any{}}}}}
+	cases := []struct {
+		plan     core.ConversionPlan
+		expected string
+	}{
+		{
+			plan:     core.ConversionPlan{FlattenFields: []string{"items[0]"}},
+			expected: "flatten path must not have an index or wildcard: items[0]",
+		},
+		{
+			plan:     core.ConversionPlan{ExplodeArrays: []string{"items[*].tags"}},
+			expected: "explode path must not have a wildcard: items[*].tags",
+		},
+		{
+			plan:     core.ConversionPlan{RenameFields: []core.RenameFieldRule{{Path: "items[*].cost", To: "cost"}}},
+			expected: "rename to must keep the wildcards of path: items[*].cost -> cost",
+		},
+		{
+			plan:     core.ConversionPlan{RenameFields: []core.RenameFieldRule{{Path: "cost", To: "items[*].cost"}}},
+			expected: "rename to must keep the wildcards of path: cost -> items[*].cost",
+		},
+		{
+			plan:     core.ConversionPlan{RenameFields: []core.RenameFieldRule{{Path: "items[*]", To: "list[*]"}}},
+			expected: "rename path must not end with a wildcard: items[*]",
+		},
+	}
+	for _, tc := range cases {
+		if _, _, err := core.TransformData(data, tc.plan); err == nil || err.Error() != tc.expected {
+			t.Fatalf("expected %q, got %v", tc.expected, err)
+		}
+	}
+}
+
+func TestConstraintsCheckEveryMatch(t *testing.T) {
+	data := core.CanonicalData{Values: core.DataValues{Records: []core.Record{
+		{"items": []any{map[string]any{"price": 5}, map[string]any{"price": -1}, map[string]any{"price": -2}}},
+		{"items": []any{map[string]any{"price": 3}}},
+	}}}
+	minimum := 0.0
+	plan := core.ConversionPlan{
+		Constraints: []core.ConstraintRule{{
+			Path:             "items[*].price",
+			FieldConstraints: core.FieldConstraints{Minimum: &minimum, OnViolation: core.ConstraintPolicyNull},
+		}},
+		LossyDecisions: []core.LossyDecision{{FieldPath: "items[*].price", Reason: core.LossReasonUserRequest, Strategy: core.StrategyNullInvalid}},
+	}
+	result, warnings, err := core.TransformData(data, plan)
+	if err != nil {
+		t.Fatalf("transform: %v", err)
+	}
+	expected := []any{map[string]any{"price": 5}, map[string]any{"price": nil}, map[string]any{"price": nil}}
+	if !reflect.DeepEqual(result.Values.Records[0]["items"], expected) {
+		t.Fatalf("expected only violating matches nulled, got %v", result.Values.Records[0]["items"])
+	}
+	if warning := findWarning(t, warnings, core.WarningCodeNullInvalid); warning.AffectedCount != 1 {
+		t.Fatalf("expected one affected record, got %#v", warning)
+	}
+}
+
+func TestShapePathsNameArrayItems(t *testing.T) {
+	records := []core.Record{{
+		"tags":   []any{"a", "b"},
+		"items":  []any{map[string]any{"price": 1}},
+		"matrix": []any{[]any{map[string]any{"v": 1}}},
+	}}
+	shape := core.BuildShapeFromRecords(records)
+	paths := []string{}
+	for _, field := range shape.Fields {
+		paths = append(paths, field.Path)
+	}
+	expected := []string{"items", "items[*].price", "matrix", "matrix[*][*].v", "tags"}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Fatalf("expected shape paths %v, got %v", expected, paths)
+	}
+
+	inferred := core.InferConstraints(shape, records, core.ConstraintInferenceOptions{})
+	for _, field := range inferred.Fields {
+		if field.Path == "items[*].price" && (field.Constraints == nil || field.Constraints.Minimum == nil) {
+			t.Fatalf("expected constraints inferred for items[*].price, got %#v", field)
+		}
+	}
+}
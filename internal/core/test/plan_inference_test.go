@@ -1,6 +1,7 @@
 package core_test
 
 import (
+	"reflect"
 	"testing"
 
 	"reshape/internal/core"
@@ -64,3 +65,44 @@ func hasLossyDecision(decisions []core.LossyDecision, path string, strategy core
 	}
 	return false
 }
+
+func TestInferConversionPlanForJSONProposesCoercions(t *testing.T) {
+	data := core.CanonicalData{Values: core.DataValues{Records: []core.Record{
+		{"id": "1", "price": "-2.50", "active": "true", "zip": "01234", "name": "Ada", "rank": "3"},
+		{"id": "2", "price": "10", "active": "FALSE", "zip": "98765", "name": "7", "rank": nil},
+	}}}
+
+	plan := core.InferConversionPlan(data, "json")
+
+	expectedCoercions := []core.TypeCoercionRule{
+		{Path: "active", TargetType: core.LogicalTypeBoolean},
+		{Path: "id", TargetType: core.LogicalTypeInteger},
+		{Path: "price", TargetType: core.LogicalTypeDecimal},
+		{Path: "rank", TargetType: core.LogicalTypeInteger},
+	}
+	if !reflect.DeepEqual(plan.TypeCoercions, expectedCoercions) {
+		t.Fatalf("unexpected coercions\nexpected: %v\nactual: %v", expectedCoercions, plan.TypeCoercions)
+	}
+	for _, rule := range expectedCoercions {
+		if !hasLossyDecision(plan.LossyDecisions, rule.Path, core.StrategyCoerceType) {
+			t.Fatalf("expected lossy decision for %s", rule.Path)
+		}
+	}
+	if len(plan.LossyDecisions) != len(expectedCoercions) {
+		t.Fatalf("unexpected lossy decisions: %v", plan.LossyDecisions)
+	}
+}
+
+func TestInferConversionPlanForJSONSkipsTypedValues(t *testing.T) {
+	data := core.CanonicalData{Values: core.DataValues{Records: []core.Record{
+		{"age": 30.0, "tags": []any{"1", "2"}, "user": map[string]any{"id": "7"}},
+		{"age": "31"},
+	}}}
+
+	plan := core.InferConversionPlan(data, "json")
+
+	expected := []core.TypeCoercionRule{{Path: "user.id", TargetType: core.LogicalTypeInteger}}
+	if !reflect.DeepEqual(plan.TypeCoercions, expected) {
+		t.Fatalf("unexpected coercions\nexpected: %v\nactual: %v", expected, plan.TypeCoercions)
+	}
+}
@@ -0,0 +1,144 @@
+package core_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"reshape/internal/core"
+)
+
+func TestTransformRunsStepsInDeclaredOrder(t *testing.T) {
+	input := core.CanonicalData{Values: core.DataValues{Records: []core.Record{
+		{"id": "1", "items": []any{map[string]any{"sku": "a", "secret": "x"}, map[string]any{"sku": "b"}}, "total": nil},
+	}}}
+
+	var plan core.ConversionPlan
+	err := json.Unmarshal([]byte(`{
+  "steps": [
+    {"op": "default_value", "path": "total", "value": "0"},
+    {"op": "coerce_type", "path": "total", "target_type": "number"},
+    {"op": "drop_field", "path": "items"},
+    {"op": "coerce_type", "path": "id", "target_type": "number"}
+  ],
+  "lossy_decisions": [
+    {"field_path": "total", "strategy": "coerce_type", "reason": "user_request"},
+    {"field_path": "id", "strategy": "coerce_type", "reason": "user_request"},
+    {"field_path": "items", "strategy": "drop_field", "reason": "user_request"}
+  ]
+}`), &plan)
+	if err != nil {
+		t.Fatalf("unmarshal plan: %v", err)
+	}
+
+	output, warnings, err := core.TransformData(input, plan)
+	if err != nil {
+		t.Fatalf("transform: %v", err)
+	}
+
+	expected := []core.Record{{"id": 1.0, "total": 0.0}}
+	if !reflect.DeepEqual(output.Values.Records, expected) {
+		t.Fatalf("unexpected records\nexpected: %v\nactual: %v", expected, output.Values.Records)
+	}
+	if len(warnings) != 3 {
+		t.Fatalf("unexpected warnings: %#v", warnings)
+	}
+}
+
+func TestTransformStepsDropBeforeExplode(t *testing.T) {
+	input := core.CanonicalData{Values: core.DataValues{Records: []core.Record{
+		{"items": []any{map[string]any{"sku": "a"}, map[string]any{"sku": "b"}}, "blob": "large"},
+	}}}
+	plan := core.ConversionPlan{
+		Steps: []core.PlanStep{
+			{Operation: core.StepDropField, Path: "blob"},
+			{Operation: core.StepExplodeArray, Path: "items"},
+			{Operation: core.StepFlatten, Path: "items"},
+		},
+		LossyDecisions: []core.LossyDecision{
+			{FieldPath: "blob", Strategy: core.StrategyDropField, Reason: core.LossReasonUserRequest},
+		},
+	}
+
+	output, warnings, err := core.TransformData(input, plan)
+	if err != nil {
+		t.Fatalf("transform: %v", err)
+	}
+
+	expected := []core.Record{{"items.sku": "a"}, {"items.sku": "b"}}
+	if !reflect.DeepEqual(output.Values.Records, expected) {
+		t.Fatalf("unexpected records\nexpected: %v\nactual: %v", expected, output.Values.Records)
+	}
+	if len(warnings) != 1 || warnings[0].AffectedCount != 1 || !reflect.DeepEqual(warnings[0].RecordIndices, []int{0}) {
+		t.Fatalf("expected drop to affect the single pre-explode record: %#v", warnings)
+	}
+}
+
+func TestPlanStepsDesugarsGroupedFields(t *testing.T) {
+	plan := core.ConversionPlan{
+		DropFields:      []string{"secret"},
+		TypeCoercions:   []core.TypeCoercionRule{{Path: "age", TargetType: core.LogicalTypeNumber}},
+		FlattenFields:   []string{"user"},
+		UnflattenFields: []string{"a", "a.b"},
+		ExplodeArrays:   []string{"items"},
+	}
+
+	steps, err := core.PlanSteps(plan)
+	if err != nil {
+		t.Fatalf("plan steps: %v", err)
+	}
+
+	expected := []core.PlanStep{
+		{Operation: core.StepFlatten, Path: "user"},
+		{Operation: core.StepUnflatten, Path: "a.b"},
+		{Operation: core.StepUnflatten, Path: "a"},
+		{Operation: core.StepExplodeArray, Path: "items"},
+		{Operation: core.StepCoerceType, Path: "age", TargetType: core.LogicalTypeNumber},
+		{Operation: core.StepDropField, Path: "secret"},
+	}
+	if !reflect.DeepEqual(steps, expected) {
+		t.Fatalf("unexpected steps\nexpected: %#v\nactual: %#v", expected, steps)
+	}
+}
+
+func TestPlanStepsRejectsInvalidPlans(t *testing.T) {
+	cases := []struct {
+		plan    core.ConversionPlan
+		message string
+	}{
+		{
+			core.ConversionPlan{Steps: []core.PlanStep{{Operation: core.StepFlatten, Path: "a"}}, DropFields: []string{"b"}},
+			"cannot be combined",
+		},
+		{
+			core.ConversionPlan{Steps: []core.PlanStep{{Operation: "rename", Path: "a"}}},
+			"steps[0]: unsupported step op",
+		},
+		{
+			core.ConversionPlan{Steps: []core.PlanStep{{Operation: core.StepDropField, Path: "a", Delimiter: ","}}},
+			"only accepts a path",
+		},
+	}
+	for _, testCase := range cases {
+		_, err := core.PlanSteps(testCase.plan)
+		if err == nil || !strings.Contains(err.Error(), testCase.message) {
+			t.Fatalf("expected error containing %q, got %v", testCase.message, err)
+		}
+	}
+}
+
+func TestValidateLossyDecisionsCoversSteps(t *testing.T) {
+	plan := core.ConversionPlan{Steps: []core.PlanStep{
+		{Operation: core.StepFlatten, Path: "user"},
+		{Operation: core.StepJoinArray, Path: "tags", Delimiter: ","},
+	}}
+
+	err := core.ValidateLossyDecisions(plan)
+	if err == nil {
+		t.Fatalf("expected missing lossy decision error")
+	}
+	if !strings.Contains(err.Error(), "steps[1] join_array requires lossy_decisions") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
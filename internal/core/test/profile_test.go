@@ -0,0 +1,89 @@
+package core_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"reshape/internal/core"
+)
+
+func TestProfileRecordsReportsPathStatistics(t *testing.T) {
+	records := []core.Record{
+		{"age": json.Number("30"), "name": "Ada", "tags": []any{"a", "b"}},
+		{"age": json.Number("41.5"), "name": "Linus", "tags": []any{"a"}},
+		{"age": nil, "name": "Ada"},
+		{"name": "Grace"},
+	}
+	profile := core.ProfileRecords(records, core.ProfileOptions{TopK: 2})
+	if profile.RecordCount != 4 {
+		t.Fatalf("expected 4 records, got %d", profile.RecordCount)
+	}
+
+	age := profileByPath(profile, "age")
+	if age.PresentCount != 3 || age.PresenceRate != 0.75 || age.NullCount != 1 || age.ValueCount != 3 {
+		t.Fatalf("unexpected age counts: %+v", age)
+	}
+	if !reflect.DeepEqual(age.TypeCounts, map[core.LogicalType]int{core.LogicalTypeInteger: 1, core.LogicalTypeDecimal: 1}) {
+		t.Fatalf("unexpected age type counts: %v", age.TypeCounts)
+	}
+	if age.Type != core.LogicalTypeDecimal {
+		t.Fatalf("expected age type decimal, got %s", age.Type)
+	}
+	if age.Numeric == nil || age.Numeric.Minimum != "30" || age.Numeric.Maximum != "41.5" || age.Numeric.Mean != 35.75 {
+		t.Fatalf("unexpected age numeric profile: %+v", age.Numeric)
+	}
+
+	name := profileByPath(profile, "name")
+	if name.DistinctCount != 3 || name.DistinctApproximate {
+		t.Fatalf("expected 3 exact distinct names, got %+v", name)
+	}
+	if !reflect.DeepEqual(name.TopValues, []core.ValueCount{{Value: "Ada", Count: 2}, {Value: "Grace", Count: 1}}) {
+		t.Fatalf("unexpected top names: %v", name.TopValues)
+	}
+	lengths := name.StringLengths
+	if lengths == nil || lengths.Minimum != 3 || lengths.Maximum != 5 || lengths.Mean != 4 {
+		t.Fatalf("unexpected name lengths: %+v", lengths)
+	}
+	expectedHistogram := []core.LengthBucket{{Minimum: 2, Maximum: 3, Count: 2}, {Minimum: 4, Maximum: 7, Count: 2}}
+	if !reflect.DeepEqual(lengths.Histogram, expectedHistogram) {
+		t.Fatalf("unexpected name histogram: %+v", lengths.Histogram)
+	}
+
+	tags := profileByPath(profile, "tags")
+	if tags.PresentCount != 2 || tags.ValueCount != 3 || tags.DistinctCount != 2 {
+		t.Fatalf("unexpected tags profile: %+v", tags)
+	}
+}
+
+func TestProfileRecordsEstimatesHighCardinality(t *testing.T) {
+	records := make([]core.Record, 0, 5000)
+	for index := 0; index < 5000; index++ {
+		value := "common"
+		if index%2 == 0 {
+			value = "id-" + strconv.Itoa(index)
+		}
+		records = append(records, core.Record{"key": value})
+	}
+	field := profileByPath(core.ProfileRecords(records, core.ProfileOptions{TopK: 1, DistinctLimit: 256}), "key")
+
+	if !field.DistinctApproximate {
+		t.Fatalf("expected an approximate distinct count")
+	}
+	if field.DistinctCount < 2000 || field.DistinctCount > 3000 {
+		t.Fatalf("expected a distinct estimate near 2501, got %d", field.DistinctCount)
+	}
+	if !field.TopValuesApproximate || len(field.TopValues) != 1 || field.TopValues[0].Value != "common" {
+		t.Fatalf("expected common as the approximate top value, got %+v", field.TopValues)
+	}
+}
+
+func profileByPath(profile core.Profile, path string) core.FieldProfile {
+	for _, field := range profile.Fields {
+		if field.Path == path {
+			return field
+		}
+	}
+	return core.FieldProfile{}
+}
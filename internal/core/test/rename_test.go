@@ -0,0 +1,120 @@
+package core_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"reshape/internal/core"
+)
+
+func TestTransformRenamesAndMovesFields(t *testing.T) {
+	input := core.CanonicalData{Values: core.DataValues{Records: []core.Record{
+		{"user": map[string]any{"id": 1.0}, "meta": map[string]any{"active": true, "source": "api"}},
+		{"user": map[string]any{"id": 2.0, "name": "Linus"}},
+	}}}
+	plan := core.ConversionPlan{
+		FlattenFields: []string{"meta"},
+		RenameFields: []core.RenameFieldRule{
+			{Path: "user.id", To: "user_id"},
+			{Path: "meta.active", To: "active"},
+		},
+	}
+
+	output, warnings, err := core.TransformData(input, plan)
+	if err != nil {
+		t.Fatalf("transform: %v", err)
+	}
+	if len(warnings) != 0 {
+		t.Fatalf("expected lossless renames, got %#v", warnings)
+	}
+
+	expected := []core.Record{
+		{"user_id": 1.0, "active": true, "meta.source": "api"},
+		{"user_id": 2.0, "user": map[string]any{"name": "Linus"}},
+	}
+	if !reflect.DeepEqual(output.Values.Records, expected) {
+		t.Fatalf("unexpected records\nexpected: %v\nactual: %v", expected, output.Values.Records)
+	}
+
+	paths := []string{}
+	for _, field := range output.Shape.Fields {
+		paths = append(paths, field.Path)
+	}
+	expectedPaths := []string{"active", "meta.source", "user", "user.name", "user_id"}
+	if !reflect.DeepEqual(paths, expectedPaths) {
+		t.Fatalf("unexpected shape paths\nexpected: %v\nactual: %v", expectedPaths, paths)
+	}
+}
+
+func TestTransformRenameCollisions(t *testing.T) {
+	input := core.CanonicalData{Values: core.DataValues{Records: []core.Record{
+		{"id": 1.0, "user": map[string]any{"id": 2.0}},
+	}}}
+
+	_, _, err := core.TransformData(input, core.ConversionPlan{
+		RenameFields: []core.RenameFieldRule{{Path: "user.id", To: "id"}},
+	})
+	if err == nil || !strings.Contains(err.Error(), "rename target already exists: id") {
+		t.Fatalf("expected collision error, got %v", err)
+	}
+
+	_, _, err = core.TransformData(input, core.ConversionPlan{
+		RenameFields: []core.RenameFieldRule{{Path: "user.id", To: "id", OnConflict: core.RenameConflictOverwrite}},
+	})
+	if err == nil || !strings.Contains(err.Error(), "overwrite_field") {
+		t.Fatalf("expected missing lossy decision error, got %v", err)
+	}
+
+	output, warnings, err := core.TransformData(input, core.ConversionPlan{
+		RenameFields: []core.RenameFieldRule{{Path: "user.id", To: "id", OnConflict: core.RenameConflictOverwrite}},
+		LossyDecisions: []core.LossyDecision{
+			{FieldPath: "id", Strategy: core.StrategyOverwriteField, Reason: core.LossReasonUserRequest},
+		},
+	})
+	if err != nil {
+		t.Fatalf("transform: %v", err)
+	}
+	if !reflect.DeepEqual(output.Values.Records, []core.Record{{"id": 2.0}}) {
+		t.Fatalf("unexpected records: %v", output.Values.Records)
+	}
+	expectedSamples := []core.WarningSample{{RecordIndex: 0, Before: 1.0, After: 2.0}}
+	if len(warnings) != 1 || warnings[0].Code != core.WarningCodeOverwriteField || !reflect.DeepEqual(warnings[0].Samples, expectedSamples) {
+		t.Fatalf("unexpected warnings: %#v", warnings)
+	}
+}
+
+func TestTransformRenameRejectsOverlap(t *testing.T) {
+	input := core.CanonicalData{Values: core.DataValues{Records: []core.Record{{"user": map[string]any{"id": 1.0}}}}}
+
+	_, _, err := core.TransformData(input, core.ConversionPlan{
+		RenameFields: []core.RenameFieldRule{{Path: "user", To: "user.profile"}},
+	})
+	if err == nil || !strings.Contains(err.Error(), "overlaps") {
+		t.Fatalf("expected overlap error, got %v", err)
+	}
+}
+
+func TestTransformDropsLiteralDottedKey(t *testing.T) {
+	input := core.CanonicalData{Values: core.DataValues{Records: []core.Record{
+		{"meta": map[string]any{"active": true}},
+	}}}
+	plan := core.ConversionPlan{
+		FlattenFields: []string{"meta"},
+		DropFields:    []string{"meta.active"},
+		LossyDecisions: []core.LossyDecision{
+			{FieldPath: "meta.active", Strategy: core.StrategyDropField, Reason: core.LossReasonUserRequest},
+		},
+	}
+
+	output, warnings, err := core.TransformData(input, plan)
+	if err != nil {
+		t.Fatalf("transform: %v", err)
+	}
+	if !reflect.DeepEqual(output.Values.Records, []core.Record{{}}) {
+		t.Fatalf("expected flattened key to be dropped, got %v", output.Values.Records)
+	}
+	if warnings[0].AffectedCount != 1 {
+		t.Fatalf("unexpected warnings: %#v", warnings)
+	}
+}
@@ -0,0 +1,176 @@
+package core_test
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"reshape/internal/core"
+)
+
+func streamTestRecords() []core.Record {
+	return []core.Record{
+		{"id": "1", "tags": []any{"a", "b"}, "score": "10", "note": "x"},
+		{"id": "2", "tags": []any{"c"}, "score": "-1", "note": "y"},
+		{"id": "3", "tags": []any{}, "score": "7", "note": nil},
+	}
+}
+
+func streamTestPlan() core.ConversionPlan {
+	minimum := 0.0
+	return core.ConversionPlan{
+		ExplodeArrays: []string{"tags"},
+		TypeCoercions: []core.TypeCoercionRule{{Path: "score", TargetType: core.LogicalTypeInteger}},
+		Constraints: []core.ConstraintRule{{Path: "score", FieldConstraints: core.FieldConstraints{
+			Minimum:     &minimum,
+			OnViolation: core.ConstraintPolicyDropRecord,
+		}}},
+		OutputFields:   []core.OutputField{{Path: "id", Header: "ID"}, {Path: "tags"}, {Path: "score"}},
+		UnlistedFields: core.UnlistedFieldsDrop,
+		LossyDecisions: []core.LossyDecision{
+			{FieldPath: "score", Reason: core.LossReasonUserRequest, Strategy: core.StrategyCoerceType},
+			{FieldPath: "score", Reason: core.LossReasonUserRequest, Strategy: core.StrategyDropRecord},
+			{FieldPath: "note", Reason: core.LossReasonUserRequest, Strategy: core.StrategyDropField},
+		},
+	}
+}
+
+func TestRecordStreamMatchesTransformData(t *testing.T) {
+	plan := streamTestPlan()
+	expected, expectedWarnings, err := core.TransformData(core.CanonicalData{Values: core.DataValues{Records: streamTestRecords()}}, plan)
+	if err != nil {
+		t.Fatalf("transform: %v", err)
+	}
+
+	pass, err := core.NewShapePass(plan)
+	if err != nil {
+		t.Fatalf("new shape pass: %v", err)
+	}
+	for _, record := range streamTestRecords() {
+		if err := pass.Process(record); err != nil {
+			t.Fatalf("measure: %v", err)
+		}
+	}
+	shape, _, err := pass.Finish(nil)
+	if err != nil {
+		t.Fatalf("finish shape pass: %v", err)
+	}
+
+	stream, err := core.NewRecordStream(plan, &shape)
+	if err != nil {
+		t.Fatalf("new record stream: %v", err)
+	}
+	records := []core.Record{}
+	for _, record := range streamTestRecords() {
+		if err := stream.Process(record, func(result core.Record) error {
+			records = append(records, result)
+			return nil
+		}); err != nil {
+			t.Fatalf("process: %v", err)
+		}
+	}
+	warnings, err := stream.Finish()
+	if err != nil {
+		t.Fatalf("finish: %v", err)
+	}
+
+	if !reflect.DeepEqual(records, expected.Values.Records) {
+		t.Fatalf("records differ\nstream: %v\nbatch:  %v", records, expected.Values.Records)
+	}
+	if !reflect.DeepEqual(*stream.Shape(), expected.Shape) {
+		t.Fatalf("shape differs\nstream: %+v\nbatch:  %+v", *stream.Shape(), expected.Shape)
+	}
+	streamJSON, _ := json.Marshal(warnings)
+	batchJSON, _ := json.Marshal(expectedWarnings)
+	if string(streamJSON) != string(batchJSON) {
+		t.Fatalf("warnings differ\nstream: %s\nbatch:  %s", streamJSON, batchJSON)
+	}
+}
+
+func TestRecordStreamReportsConstraintErrorsWithoutEmitting(t *testing.T) {
+	minimum := 0.0
+	plan := core.ConversionPlan{Constraints: []core.ConstraintRule{{Path: "n", FieldConstraints: core.FieldConstraints{Minimum: &minimum}}}}
+	stream, err := core.NewRecordStream(plan, nil)
+	if err != nil {
+		t.Fatalf("new record stream: %v", err)
+	}
+	emitted := 0
+	for _, record := range []core.Record{{"n": 1.0}, {"n": -1.0}, {"n": 2.0}, {"n": -2.0}} {
+		if err := stream.Process(record, func(core.Record) error {
+			emitted++
+			return nil
+		}); err != nil {
+			t.Fatalf("process: %v", err)
+		}
+	}
+	_, err = stream.Finish()
+	var constraintErr *core.ConstraintError
+	if !errors.As(err, &constraintErr) || len(constraintErr.Violations) != 2 {
+		t.Fatalf("expected both violations, got %v", err)
+	}
+	if emitted != 1 {
+		t.Fatalf("expected records after the first violation to be held back, emitted %d", emitted)
+	}
+}
+
+func TestRecordStreamKeepsFirstConstraintViolations(t *testing.T) {
+	minimum := 0.0
+	plan := core.ConversionPlan{Constraints: []core.ConstraintRule{{Path: "n", FieldConstraints: core.FieldConstraints{Minimum: &minimum}}}}
+	stream, err := core.NewRecordStream(plan, nil)
+	if err != nil {
+		t.Fatalf("new record stream: %v", err)
+	}
+	for index := 0; index < 1000; index++ {
+		if err := stream.Process(core.Record{"n": -1.0}, func(core.Record) error { return nil }); err != nil {
+			t.Fatalf("process: %v", err)
+		}
+	}
+	_, err = stream.Finish()
+	var constraintErr *core.ConstraintError
+	if !errors.As(err, &constraintErr) {
+		t.Fatalf("expected constraint error, got %v", err)
+	}
+	if len(constraintErr.Violations) != 5 || constraintErr.Count != 1000 {
+		t.Fatalf("expected the first 5 of 1000 violations, got %d of %d", len(constraintErr.Violations), constraintErr.Count)
+	}
+	if constraintErr.Violations[4].RecordIndex != 4 || !strings.HasSuffix(err.Error(), "; and 995 more") {
+		t.Fatalf("unexpected violations: %v", err)
+	}
+}
+
+func TestRecordStreamRequiresShapeForWholeDatasetRules(t *testing.T) {
+	_, err := core.NewRecordStream(core.ConversionPlan{FieldOrder: core.FieldOrderInput}, nil)
+	if err == nil || !strings.Contains(err.Error(), "field_order input requires streaming shape") {
+		t.Fatalf("expected field_order error, got %v", err)
+	}
+	_, err = core.NewRecordStream(core.ConversionPlan{OutputFields: []core.OutputField{{Path: "a"}}}, nil)
+	if err == nil || !strings.Contains(err.Error(), "output_fields requires streaming shape") {
+		t.Fatalf("expected output_fields error, got %v", err)
+	}
+
+	declared := core.DataShape{Fields: []core.FieldDefinition{{Path: "b", Type: core.LogicalTypeString}, {Path: "a", Type: core.LogicalTypeString}}}
+	stream, err := core.NewRecordStream(core.ConversionPlan{FieldOrder: core.FieldOrderInput}, &declared)
+	if err != nil {
+		t.Fatalf("new record stream: %v", err)
+	}
+	if shape := stream.Shape(); shape.Order != core.ShapeOrderDeclared || shape.Fields[0].Path != "b" {
+		t.Fatalf("expected declared field order kept, got %+v", shape)
+	}
+}
+
+func TestValidateStreamingOptions(t *testing.T) {
+	cases := map[string]core.StreamingOptions{
+		"streaming shape declared requires declared_shape":  {Shape: core.StreamShapeDeclared},
+		"streaming declared_shape requires shape declared":  {Shape: core.StreamShapeTwoPass, DeclaredShape: &core.DataShape{}},
+		"unsupported streaming shape: everything_in_memory": {Shape: "everything_in_memory"},
+	}
+	for expected, options := range cases {
+		options := options
+		_, _, err := core.TransformData(core.CanonicalData{}, core.ConversionPlan{Streaming: &options})
+		if err == nil || err.Error() != expected {
+			t.Fatalf("expected %q, got %v", expected, err)
+		}
+	}
+}
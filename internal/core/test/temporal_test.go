@@ -0,0 +1,142 @@
+package core_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"reshape/internal/core"
+)
+
+func coercionPlan(rule core.TypeCoercionRule) core.ConversionPlan {
+	return core.ConversionPlan{
+		TypeCoercions:  []core.TypeCoercionRule{rule},
+		LossyDecisions: []core.LossyDecision{{FieldPath: rule.Path, Reason: core.LossReasonUserRequest, Strategy: core.StrategyCoerceType}},
+	}
+}
+
+func TestCoerceDateWithLayoutsReportsAmbiguity(t *testing.T) {
+	rule := core.TypeCoercionRule{Path: "when", TargetType: core.LogicalTypeDate, Layouts: []string{"01/02/2006", "02/01/2006"}}
+
+	input := core.CanonicalData{Values: core.DataValues{Records: []core.Record{{"when": "13/04/2025"}, {"when": "04/04/2025"}}}}
+	output, _, err := core.TransformData(input, coercionPlan(rule))
+	if err != nil {
+		t.Fatalf("transform: %v", err)
+	}
+	expected := []any{core.Date{Year: 2025, Month: time.April, Day: 13}, core.Date{Year: 2025, Month: time.April, Day: 4}}
+	for index, record := range output.Values.Records {
+		if record["when"] != expected[index] {
+			t.Fatalf("record %d: expected %v, got %v", index, expected[index], record["when"])
+		}
+	}
+	if field := fieldByPath(output.Shape, "when"); field.Type != core.LogicalTypeDate {
+		t.Fatalf("expected date shape, got %#v", field)
+	}
+
+	ambiguous := core.CanonicalData{Values: core.DataValues{Records: []core.Record{{"when": "03/04/2025"}}}}
+	_, _, err = core.TransformData(ambiguous, coercionPlan(rule))
+	if err == nil || !strings.Contains(err.Error(), `ambiguous date "03/04/2025" (01/02/2006 → 2025-03-04, 02/01/2006 → 2025-04-03) at path: when (record 0)`) {
+		t.Fatalf("expected ambiguity error, got %v", err)
+	}
+}
+
+func TestCoerceDatetimeUsesTimezone(t *testing.T) {
+	rule := core.TypeCoercionRule{Path: "at", TargetType: core.LogicalTypeDatetime, Layouts: []string{"2006-01-02 15:04"}, Timezone: "America/New_York"}
+	input := core.CanonicalData{Values: core.DataValues{Records: []core.Record{{"at": "2025-01-15 09:30"}}}}
+
+	output, _, err := core.TransformData(input, coercionPlan(rule))
+	if err != nil {
+		t.Fatalf("transform: %v", err)
+	}
+	value, ok := output.Values.Records[0]["at"].(time.Time)
+	if !ok || value.Format(time.RFC3339) != "2025-01-15T09:30:00-05:00" {
+		t.Fatalf("unexpected datetime: %#v", output.Values.Records[0]["at"])
+	}
+
+	rule.Timezone = "Mars/Olympus"
+	_, _, err = core.TransformData(input, coercionPlan(rule))
+	if err == nil || !strings.Contains(err.Error(), "unknown timezone: Mars/Olympus") {
+		t.Fatalf("expected timezone error, got %v", err)
+	}
+
+	_, _, err = core.TransformData(input, coercionPlan(core.TypeCoercionRule{Path: "at", TargetType: core.LogicalTypeDate, Timezone: "UTC"}))
+	if err == nil || !strings.Contains(err.Error(), "timezone requires target_type datetime") {
+		t.Fatalf("expected timezone target error, got %v", err)
+	}
+}
+
+func TestCoerceDurations(t *testing.T) {
+	input := core.CanonicalData{Values: core.DataValues{Records: []core.Record{
+		{"d": "P1DT2H30M"},
+		{"d": "90m"},
+		{"d": "PT0.5S"},
+	}}}
+
+	output, _, err := core.TransformData(input, coercionPlan(core.TypeCoercionRule{Path: "d", TargetType: core.LogicalTypeDuration}))
+	if err != nil {
+		t.Fatalf("transform: %v", err)
+	}
+	rendered := []string{}
+	for _, record := range output.Values.Records {
+		rendered = append(rendered, record["d"].(core.Duration).String())
+	}
+	if !reflect.DeepEqual(rendered, []string{"PT26H30M", "PT1H30M", "PT0.5S"}) {
+		t.Fatalf("unexpected durations: %v", rendered)
+	}
+
+	years := core.CanonicalData{Values: core.DataValues{Records: []core.Record{{"d": "P1Y"}}}}
+	_, _, err = core.TransformData(years, coercionPlan(core.TypeCoercionRule{Path: "d", TargetType: core.LogicalTypeDuration}))
+	if err == nil || !strings.Contains(err.Error(), `cannot coerce string to duration: "P1Y"`) {
+		t.Fatalf("expected duration error, got %v", err)
+	}
+}
+
+func TestCoerceDateRejectsClockLayouts(t *testing.T) {
+	input := core.CanonicalData{Values: core.DataValues{Records: []core.Record{{"when": "2025-01-15 09:30"}}}}
+	for _, layout := range []string{"2006-01-02 15:04", "2006-01-02T15:04:05Z07:00", "2006-01-02 MST"} {
+		rule := core.TypeCoercionRule{Path: "when", TargetType: core.LogicalTypeDate, Layouts: []string{"01/02/2006", layout}}
+		_, _, err := core.TransformData(input, coercionPlan(rule))
+		expected := "type_coercions when: layouts with a time of day or zone require target_type datetime: " + layout
+		if err == nil || err.Error() != expected {
+			t.Fatalf("expected %q, got %v", expected, err)
+		}
+	}
+}
+
+func TestCoerceDatetimeToDateRequiresTruncateTime(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatalf("load location: %v", err)
+	}
+	input := core.CanonicalData{Values: core.DataValues{Records: []core.Record{
+		{"when": time.Date(2025, time.January, 15, 0, 0, 0, 0, time.UTC)},
+		{"when": time.Date(2025, time.January, 15, 23, 30, 0, 0, newYork)},
+	}}}
+	rule := core.TypeCoercionRule{Path: "when", TargetType: core.LogicalTypeDate}
+	plan := coercionPlan(rule)
+
+	_, _, err = core.TransformData(input, plan)
+	if err == nil || err.Error() != "datetime loses its time of day or zone as a date; requires truncate_time lossy_decisions entry for path: when" {
+		t.Fatalf("expected truncate_time error, got %v", err)
+	}
+
+	plan.LossyDecisions = append(plan.LossyDecisions, core.LossyDecision{FieldPath: "when", Reason: core.LossReasonUserRequest, Strategy: core.StrategyTruncateTime})
+	output, warnings, err := core.TransformData(input, plan)
+	if err != nil {
+		t.Fatalf("transform: %v", err)
+	}
+	expected := []any{core.Date{Year: 2025, Month: time.January, Day: 15}, core.Date{Year: 2025, Month: time.January, Day: 15}}
+	for index, record := range output.Values.Records {
+		if record["when"] != expected[index] {
+			t.Fatalf("record %d: expected %v, got %v", index, expected[index], record["when"])
+		}
+	}
+	truncated := findWarning(t, warnings, core.WarningCodeTruncateTime)
+	if truncated.AffectedCount != 1 || !reflect.DeepEqual(truncated.RecordIndices, []int{1}) {
+		t.Fatalf("expected only the record with a time of day, got %#v", truncated)
+	}
+	if strategy, ok := core.StrategyForWarning(core.WarningCodeTruncateTime); !ok || strategy != core.StrategyTruncateTime {
+		t.Fatalf("expected truncate_time strategy, got %v", strategy)
+	}
+}
@@ -0,0 +1,108 @@
+package core_test
+
+import (
+	"reflect"
+	"testing"
+
+	"reshape/internal/core"
+)
+
+func TestWarningsReportAffectedRecordsAndSamples(t *testing.T) {
+	records := []core.Record{}
+	for index := 0; index < 7; index++ {
+		record := core.Record{"total": float64(index)}
+		if index%2 == 0 {
+			record["secret"] = "s"
+		}
+		records = append(records, record)
+	}
+	records[3]["total"] = nil
+
+	plan := core.ConversionPlan{
+		TypeCoercions: []core.TypeCoercionRule{{Path: "total", TargetType: core.LogicalTypeString}},
+		DropFields:    []string{"secret"},
+		LossyDecisions: []core.LossyDecision{
+			{FieldPath: "total", Strategy: core.StrategyCoerceType, Reason: core.LossReasonUserRequest},
+			{FieldPath: "secret", Strategy: core.StrategyDropField, Reason: core.LossReasonUserRequest},
+		},
+	}
+
+	_, warnings, err := core.TransformData(core.CanonicalData{Values: core.DataValues{Records: records}}, plan)
+	if err != nil {
+		t.Fatalf("transform: %v", err)
+	}
+
+	expected := []core.Warning{
+		{
+			Code:          core.WarningCodeDropField,
+			Message:       core.WarningMessage(core.WarningCodeDropField),
+			Path:          "secret",
+			AffectedCount: 4,
+			RecordIndices: []int{0, 2, 4, 6},
+			Samples: []core.WarningSample{
+				{RecordIndex: 0, Before: "s"},
+				{RecordIndex: 2, Before: "s"},
+				{RecordIndex: 4, Before: "s"},
+				{RecordIndex: 6, Before: "s"},
+			},
+		},
+		{
+			Code:          core.WarningCodeCoerceType,
+			Message:       core.WarningMessage(core.WarningCodeCoerceType),
+			Path:          "total",
+			AffectedCount: 6,
+			RecordIndices: []int{0, 1, 2, 4, 5},
+			Samples: []core.WarningSample{
+				{RecordIndex: 0, Before: 0.0, After: "0"},
+				{RecordIndex: 1, Before: 1.0, After: "1"},
+				{RecordIndex: 2, Before: 2.0, After: "2"},
+				{RecordIndex: 4, Before: 4.0, After: "4"},
+				{RecordIndex: 5, Before: 5.0, After: "5"},
+			},
+		},
+	}
+	if !reflect.DeepEqual(warnings, expected) {
+		t.Fatalf("unexpected warnings\nexpected: %#v\nactual: %#v", expected, warnings)
+	}
+}
+
+func TestWarningsReportZeroAffectedRecords(t *testing.T) {
+	input := core.CanonicalData{Values: core.DataValues{Records: []core.Record{{"name": "Ada"}}}}
+	plan := core.ConversionPlan{
+		DropFields: []string{"secret"},
+		LossyDecisions: []core.LossyDecision{
+			{FieldPath: "secret", Strategy: core.StrategyDropField, Reason: core.LossReasonUserRequest},
+		},
+	}
+
+	_, warnings, err := core.TransformData(input, plan)
+	if err != nil {
+		t.Fatalf("transform: %v", err)
+	}
+	if len(warnings) != 1 || warnings[0].AffectedCount != 0 || len(warnings[0].Samples) != 0 {
+		t.Fatalf("unexpected warnings: %#v", warnings)
+	}
+}
+
+func TestJoinWarningSamplesKeepOriginalArray(t *testing.T) {
+	input := core.CanonicalData{Values: core.DataValues{Records: []core.Record{
+		{"tags": []any{"a,b", "c"}},
+	}}}
+	plan := core.InferConversionPlan(input, "csv")
+
+	_, warnings, err := core.TransformData(input, plan)
+	if err != nil {
+		t.Fatalf("transform: %v", err)
+	}
+	if len(warnings) != 1 {
+		t.Fatalf("unexpected warnings: %#v", warnings)
+	}
+	join := warnings[0]
+	if join.Code != core.WarningCodeJoinArray || join.AffectedCount != 1 {
+		t.Fatalf("unexpected join warning: %#v", join)
+	}
+	expectedSample := core.WarningSample{RecordIndex: 0, Before: []any{"a,b", "c"}, After: `a\,b,c`}
+	if !reflect.DeepEqual(join.Samples, []core.WarningSample{expectedSample}) {
+		t.Fatalf("unexpected samples: %#v", join.Samples)
+	}
+}
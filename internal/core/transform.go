@@ -1,152 +1,634 @@
 package core
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
-	"sort"
+	"reflect"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // TransformData applies the conversion plan to canonical data.
+// When a constraint with the error policy is violated, or the plan's loss
+// budget is exceeded, the warnings are returned along with a
+// *ConstraintError or *LossBudgetError.
 func TransformData(input CanonicalData, plan ConversionPlan) (CanonicalData, []Warning, error) {
-	normalizedPlan := NormalizePlan(plan)
-	if err := ValidateLossyDecisions(normalizedPlan); err != nil {
+	prepared, err := preparePlan(plan)
+	if err != nil {
 		return CanonicalData{}, nil, err
 	}
-	records := deepCopyRecords(input.Values.Records)
-	decisionMap := map[string]LossyDecision{}
-	for _, decision := range normalizedPlan.LossyDecisions {
-		key := string(decision.Strategy) + ":" + decision.FieldPath
-		decisionMap[key] = decision
+	normalizedPlan := prepared.plan
+	state := newTransformState(normalizedPlan, deepCopyRecords(input.Values.Records), input.Shape.SourceOrder)
+
+	for _, step := range prepared.steps {
+		if err := state.apply(step); err != nil {
+			return CanonicalData{}, nil, err
+		}
+	}
+	if err := state.validateConstraints(prepared.constraints); err != nil {
+		var constraintErr *ConstraintError
+		if errors.As(err, &constraintErr) {
+			return CanonicalData{}, state.warnings.list(), err
+		}
+		return CanonicalData{}, nil, err
 	}
-	warnings := []Warning{}
-	warningSet := map[string]struct{}{}
 
-	for _, path := range normalizedPlan.FlattenFields {
-		for index := range records {
-			if err := flattenAtPath(records[index], path); err != nil {
+	output := CanonicalData{Shape: state.shape(input.Shape, normalizedPlan.FieldOrder)}
+	if len(normalizedPlan.OutputFields) > 0 {
+		if normalizedPlan.UnlistedFields == UnlistedFieldsDrop {
+			if err := state.dropUnlisted(output.Shape, normalizedPlan.OutputFields); err != nil {
 				return CanonicalData{}, nil, err
 			}
+			output.Shape = state.shape(input.Shape, normalizedPlan.FieldOrder)
+		}
+		output.Shape, err = layoutShape(output.Shape, normalizedPlan.OutputFields, normalizedPlan.UnlistedFields)
+		if err != nil {
+			return CanonicalData{}, nil, err
+		}
+	}
+	output.Values = DataValues{Records: state.records}
+	warningList := state.warnings.list()
+	if normalizedPlan.LossBudget != nil {
+		if err := EvaluateLossBudget(*normalizedPlan.LossBudget, warningList, state.warnings.strategyRecords()); err != nil {
+			return CanonicalData{}, warningList, err
+		}
+	}
+	return output, warningList, nil
+}
+
+// preparedPlan is a normalized plan that passed validation.
+type preparedPlan struct {
+	plan        ConversionPlan
+	steps       []PlanStep
+	constraints []compiledConstraint
+}
+
+func preparePlan(plan ConversionPlan) (preparedPlan, error) {
+	canonicalPlan, err := canonicalizePlanPaths(plan)
+	if err != nil {
+		return preparedPlan{}, err
+	}
+	normalizedPlan := NormalizePlan(canonicalPlan)
+	if err := ValidateLossyDecisions(normalizedPlan); err != nil {
+		return preparedPlan{}, err
+	}
+	steps, err := PlanSteps(normalizedPlan)
+	if err != nil {
+		return preparedPlan{}, err
+	}
+	if err := validateFieldOrder(normalizedPlan.FieldOrder); err != nil {
+		return preparedPlan{}, err
+	}
+	if err := validateOutputFields(normalizedPlan); err != nil {
+		return preparedPlan{}, err
+	}
+	if err := validateStreaming(normalizedPlan.Streaming); err != nil {
+		return preparedPlan{}, err
+	}
+	if err := validateParallel(normalizedPlan.Parallel); err != nil {
+		return preparedPlan{}, err
+	}
+	if err := validateLossBudget(normalizedPlan.LossBudget); err != nil {
+		return preparedPlan{}, err
+	}
+	constraints, err := compileConstraints(normalizedPlan.Constraints)
+	if err != nil {
+		return preparedPlan{}, err
+	}
+	return preparedPlan{plan: normalizedPlan, steps: steps, constraints: constraints}, nil
+}
+
+func validateParallel(options *ParallelOptions) error {
+	if options == nil {
+		return nil
+	}
+	if options.Workers < 1 {
+		return errors.New("parallel workers must be at least 1")
+	}
+	if options.ChunkSize < 0 {
+		return errors.New("parallel chunk_size must not be negative")
+	}
+	return nil
+}
+
+type transformState struct {
+	records   []Record
+	order     []string
+	decisions map[string]LossyDecision
+	warnings  *warningCollector
+	// workers and chunkSize spread parallel steps over goroutines; one
+	// worker runs every step in sequence.
+	workers   int
+	chunkSize int
+	// regrouped holds the paths the plan unflattens.
+	regrouped map[string]struct{}
+}
+
+func newTransformState(plan ConversionPlan, records []Record, order []string) *transformState {
+	state := &transformState{
+		records:   records,
+		order:     append([]string(nil), order...),
+		decisions: map[string]LossyDecision{},
+		warnings:  newWarningCollector(),
+		workers:   1,
+		regrouped: map[string]struct{}{},
+	}
+	for _, path := range plan.UnflattenFields {
+		state.regrouped[path] = struct{}{}
+	}
+	for _, step := range plan.Steps {
+		if step.Operation == StepUnflatten {
+			state.regrouped[step.Path] = struct{}{}
+		}
+	}
+	if plan.Parallel != nil {
+		state.workers = plan.Parallel.Workers
+		state.chunkSize = plan.Parallel.ChunkSize
+	}
+	if state.chunkSize == 0 {
+		state.chunkSize = DefaultParallelChunkSize
+	}
+	for _, decision := range plan.LossyDecisions {
+		key := string(decision.Strategy) + ":" + decision.FieldPath
+		state.decisions[key] = decision
+	}
+	return state
+}
+
+// shape describes the current records, falling back to the input shape when
+// there are none, in the requested field order.
+func (s *transformState) shape(input DataShape, order FieldOrder) DataShape {
+	shape := input
+	if len(s.records) > 0 {
+		shape = BuildShapeFromRecords(s.records)
+	}
+	if order == FieldOrderInput {
+		return orderShape(shape, s.order)
+	}
+	shape.SourceOrder = s.order
+	return shape
+}
+
+// recordStep is a plan step compiled to run one record at a time. apply
+// receives each record with its position among the step's input records and
+// the collector for its warnings, and passes the resulting records to emit;
+// finish runs after the last record.
+type recordStep struct {
+	apply  func(record Record, index int, warnings *warningCollector, emit func(Record) error) error
+	finish func() error
+	// parallel marks steps whose apply touches nothing shared but the
+	// warnings it is given, so chunks of records can run concurrently.
+	parallel bool
+}
+
+func (s *transformState) apply(step PlanStep) error {
+	compiled, err := s.compileStep(step)
+	if err != nil {
+		return err
+	}
+	return s.run(compiled)
+}
+
+// run applies a compiled step to every record in memory.
+func (s *transformState) run(step recordStep) error {
+	if step.parallel && s.workers > 1 && len(s.records) > s.chunkSize {
+		return s.runChunks(step)
+	}
+	next, err := applyToRecords(step, s.records, 0, s.warnings)
+	if err != nil {
+		return err
+	}
+	s.records = next
+	return step.finish()
+}
+
+// applyToRecords applies step to records numbered from offset, stopping at
+// the first error.
+func applyToRecords(step recordStep, records []Record, offset int, warnings *warningCollector) ([]Record, error) {
+	next := make([]Record, 0, len(records))
+	emit := func(record Record) error {
+		next = append(next, record)
+		return nil
+	}
+	for index, record := range records {
+		if err := step.apply(record, offset+index, warnings, emit); err != nil {
+			return nil, err
+		}
+	}
+	return next, nil
+}
+
+// chunkResult is the outcome of applying a step to one chunk of records.
+type chunkResult struct {
+	records  []Record
+	warnings *warningCollector
+	err      error
+}
+
+// runChunks applies a parallel step to chunks of records on s.workers
+// goroutines. Each chunk collects its own warnings, merged in record order
+// afterwards, and the error of the first failing chunk is returned, so the
+// result is the one a sequential run would produce. Chunks after a failed
+// one are skipped.
+func (s *transformState) runChunks(step recordStep) error {
+	chunkCount := (len(s.records) + s.chunkSize - 1) / s.chunkSize
+	results := make([]chunkResult, chunkCount)
+	var firstFailed atomic.Int64
+	firstFailed.Store(int64(chunkCount))
+	chunks := make(chan int)
+	var workers sync.WaitGroup
+	for worker := 0; worker < s.workers && worker < chunkCount; worker++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for chunk := range chunks {
+				if int64(chunk) > firstFailed.Load() {
+					continue
+				}
+				start := chunk * s.chunkSize
+				end := start + s.chunkSize
+				if end > len(s.records) {
+					end = len(s.records)
+				}
+				warnings := newWarningCollector()
+				records, err := applyToRecords(step, s.records[start:end], start, warnings)
+				results[chunk] = chunkResult{records: records, warnings: warnings, err: err}
+				for err != nil {
+					failed := firstFailed.Load()
+					if int64(chunk) >= failed || firstFailed.CompareAndSwap(failed, int64(chunk)) {
+						break
+					}
+				}
+			}
+		}()
+	}
+	for chunk := 0; chunk < chunkCount; chunk++ {
+		chunks <- chunk
+	}
+	close(chunks)
+	workers.Wait()
+
+	next := make([]Record, 0, len(s.records))
+	for _, result := range results {
+		if result.err != nil {
+			return result.err
 		}
+		next = append(next, result.records...)
+		s.warnings.merge(result.warnings)
 	}
+	s.records = next
+	return step.finish()
+}
 
-	for _, path := range normalizedPlan.ExplodeArrays {
-		var expanded []Record
-		for _, record := range records {
-			value, exists, err := getValueAtPath(record, path)
+func (s *transformState) compileStep(step PlanStep) (recordStep, error) {
+	switch step.Operation {
+	case StepFlatten:
+		path, err := parseKeyPath(step.Path, "flatten")
+		if err != nil {
+			return recordStep{}, err
+		}
+		return s.inPlace(func(record Record, index int, warnings *warningCollector) error {
+			return flattenAtPath(record, path)
+		}), nil
+	case StepUnflatten:
+		path, err := parseKeyPath(step.Path, "unflatten")
+		if err != nil {
+			return recordStep{}, err
+		}
+		return s.inPlace(func(record Record, index int, warnings *warningCollector) error {
+			return unflattenAtPath(record, path, s.regrouped)
+		}), nil
+	case StepSplitString:
+		return s.splitStrings(step.splitRule())
+	case StepExplodeArray:
+		return s.explodeArray(step.Path)
+	case StepJoinArray:
+		return s.joinArray(step.joinRule())
+	case StepCoerceType:
+		return s.coerceType(step.coercionRule())
+	case StepDefaultValue:
+		return s.defaultValue(DefaultValueRule{Path: step.Path, Value: step.Value})
+	case StepDropField:
+		return s.dropField(step.Path)
+	case StepRenameField:
+		return s.renameField(RenameFieldRule{Path: step.Path, To: step.To, OnConflict: step.OnConflict})
+	default:
+		return recordStep{}, errors.New("unsupported step op: " + string(step.Operation))
+	}
+}
+
+// parseKeyPath parses the path of a rule that works on object keys only.
+func parseKeyPath(text string, operation string) (Path, error) {
+	path, err := ParsePath(text)
+	if err != nil {
+		return Path{}, err
+	}
+	if !path.KeysOnly() {
+		return Path{}, errors.New(operation + " path must not have an index or wildcard: " + path.String())
+	}
+	return path, nil
+}
+
+// inPlace builds a parallel step that changes each record in place and has
+// nothing to check after the last record.
+func (s *transformState) inPlace(change func(record Record, index int, warnings *warningCollector) error) recordStep {
+	return recordStep{
+		apply: func(record Record, index int, warnings *warningCollector, emit func(Record) error) error {
+			if err := change(record, index, warnings); err != nil {
+				return err
+			}
+			return emit(record)
+		},
+		finish:   func() error { return nil },
+		parallel: true,
+	}
+}
+
+func (s *transformState) splitStrings(rule SplitStringRule) (recordStep, error) {
+	if err := validateSplitEscaping(rule); err != nil {
+		return recordStep{}, err
+	}
+	path, err := ParsePath(rule.Path)
+	if err != nil {
+		return recordStep{}, err
+	}
+	step := s.inPlace(func(record Record, index int, warnings *warningCollector) error {
+		split, narrowed := path.changes(), path.changes()
+		err := path.update(record, func(value any) (any, error) {
+			text, ok := value.(string)
+			if !ok {
+				return nil, errors.New("split target is not a string: " + rule.Path)
+			}
+			parts, narrowedParts, err := splitStringValue(text, rule)
 			if err != nil {
-				return CanonicalData{}, nil, err
+				return nil, err
+			}
+			if narrowedParts {
+				narrowed.add(text, parts)
+			}
+			split.add(text, parts)
+			return parts, nil
+		})
+		if err != nil {
+			return err
+		}
+		if err := s.narrowNumber(warnings, rule.Path, index, narrowed); err != nil {
+			return err
+		}
+		if rule.IsLossy() {
+			split.affect(warnings, rule.Path, WarningCodeSplitString, index)
+		}
+		return nil
+	})
+	step.finish = func() error {
+		if rule.IsLossy() {
+			if _, err := requireLossyDecision(s.decisions, StrategySplitString, rule.Path); err != nil {
+				return err
+			}
+			s.warnings.note(rule.Path, WarningCodeSplitString)
+		}
+		return nil
+	}
+	return step, nil
+}
+
+func (s *transformState) explodeArray(path string) (recordStep, error) {
+	compiled, err := ParsePath(path)
+	if err != nil {
+		return recordStep{}, err
+	}
+	if compiled.wildcard {
+		return recordStep{}, errors.New("explode path must not have a wildcard: " + path)
+	}
+	return recordStep{
+		apply: func(record Record, index int, warnings *warningCollector, emit func(Record) error) error {
+			value, exists, err := compiled.Value(record)
+			if err != nil {
+				return err
 			}
 			if !exists || value == nil {
-				expanded = append(expanded, record)
-				continue
+				return emit(record)
 			}
 			sliceValue, ok := value.([]any)
 			if !ok {
-				return CanonicalData{}, nil, errors.New("explode target is not an array: " + path)
+				return errors.New("explode target is not an array: " + path)
 			}
 			if len(sliceValue) == 0 {
-				expanded = append(expanded, record)
-				continue
+				return emit(record)
 			}
 			for _, item := range sliceValue {
 				copied := deepCopyRecord(record)
-				if err := setValueAtPath(copied, path, item); err != nil {
-					return CanonicalData{}, nil, err
+				if err := compiled.Set(copied, item); err != nil {
+					return err
+				}
+				if err := emit(copied); err != nil {
+					return err
 				}
-				expanded = append(expanded, copied)
 			}
-		}
-		records = expanded
-	}
+			return nil
+		},
+		finish: func() error { return nil },
+	}, nil
+}
 
-	for _, rule := range normalizedPlan.JoinArrays {
-		for index := range records {
-			value, exists, err := getValueAtPath(records[index], rule.Path)
-			if err != nil {
-				return CanonicalData{}, nil, err
-			}
-			if !exists || value == nil {
-				continue
-			}
+func (s *transformState) joinArray(rule JoinArrayRule) (recordStep, error) {
+	if err := validateJoinCollision(rule); err != nil {
+		return recordStep{}, err
+	}
+	path, err := ParsePath(rule.Path)
+	if err != nil {
+		return recordStep{}, err
+	}
+	step := s.inPlace(func(record Record, index int, warnings *warningCollector) error {
+		joins, collisions := path.changes(), path.changes()
+		err := path.update(record, func(value any) (any, error) {
 			sliceValue, ok := value.([]any)
 			if !ok {
-				return CanonicalData{}, nil, errors.New("join target is not an array: " + rule.Path)
+				return nil, errors.New("join target is not an array: " + rule.Path)
 			}
-			joined, err := joinArrayValues(sliceValue, rule.Delimiter)
+			joined, collided, err := joinArrayValues(sliceValue, rule)
 			if err != nil {
-				return CanonicalData{}, nil, err
+				return nil, err
 			}
-			if err := setValueAtPath(records[index], rule.Path, joined); err != nil {
-				return CanonicalData{}, nil, err
+			joins.add(sliceValue, joined)
+			// Escaping and quoting keep collided elements recoverable, so
+			// only an acknowledged collision loses data.
+			if collided && rule.OnCollision == DelimiterCollisionAcknowledge {
+				collisions.add(sliceValue, joined)
 			}
+			return joined, nil
+		})
+		if err != nil {
+			return err
 		}
-		if _, err := requireLossyDecision(decisionMap, StrategyJoinArray, rule.Path); err != nil {
-			return CanonicalData{}, nil, err
+		joins.affect(warnings, rule.Path, WarningCodeJoinArray, index)
+		collisions.affect(warnings, rule.Path, WarningCodeJoinCollision, index)
+		return nil
+	})
+	step.finish = func() error {
+		if _, err := requireLossyDecision(s.decisions, StrategyJoinArray, rule.Path); err != nil {
+			return err
+		}
+		s.warnings.note(rule.Path, WarningCodeJoinArray)
+		if s.warnings.affected(rule.Path, WarningCodeJoinCollision) {
+			if _, err := requireLossyDecision(s.decisions, StrategyJoinCollision, rule.Path); err != nil {
+				return err
+			}
 		}
-		addWarningOnce(&warnings, warningSet, rule.Path, WarningCodeJoinArray)
+		return nil
 	}
+	return step, nil
+}
 
-	for _, rule := range normalizedPlan.TypeCoercions {
-		for index := range records {
-			value, exists, err := getValueAtPath(records[index], rule.Path)
+func (s *transformState) coerceType(rule TypeCoercionRule) (recordStep, error) {
+	if err := validateTemporalOptions(rule.TargetType, rule.Layouts, rule.Timezone); err != nil {
+		return recordStep{}, errors.New("type_coercions " + rule.Path + ": " + err.Error())
+	}
+	path, err := ParsePath(rule.Path)
+	if err != nil {
+		return recordStep{}, err
+	}
+	step := s.inPlace(func(record Record, index int, warnings *warningCollector) error {
+		coercions, narrowed, truncated := path.changes(), path.changes(), path.changes()
+		err := path.update(record, func(value any) (any, error) {
+			coerced, narrowedValue, err := coerceRuleValue(value, rule)
 			if err != nil {
-				return CanonicalData{}, nil, err
+				return nil, errors.New(err.Error() + " at path: " + rule.Path + " (record " + strconv.Itoa(index) + ")")
 			}
-			if !exists || value == nil {
-				continue
+			if narrowedValue {
+				narrowed.add(value, coerced)
 			}
-			coerced, err := coerceValue(value, rule.TargetType)
-			if err != nil {
-				return CanonicalData{}, nil, err
+			if rule.TargetType == LogicalTypeDate && truncatesTime(value) {
+				truncated.add(value, coerced)
 			}
-			if err := setValueAtPath(records[index], rule.Path, coerced); err != nil {
-				return CanonicalData{}, nil, err
+			if coercionChanged(value, coerced) {
+				coercions.add(value, coerced)
 			}
+			return coerced, nil
+		})
+		if err != nil {
+			return err
 		}
-		if _, err := requireLossyDecision(decisionMap, StrategyCoerceType, rule.Path); err != nil {
-			return CanonicalData{}, nil, err
+		if err := s.narrowNumber(warnings, rule.Path, index, narrowed); err != nil {
+			return err
+		}
+		if err := s.truncateTime(warnings, rule.Path, index, truncated); err != nil {
+			return err
 		}
-		addWarningOnce(&warnings, warningSet, rule.Path, WarningCodeCoerceType)
+		coercions.affect(warnings, rule.Path, WarningCodeCoerceType, index)
+		return nil
+	})
+	step.finish = func() error {
+		if _, err := requireLossyDecision(s.decisions, StrategyCoerceType, rule.Path); err != nil {
+			return err
+		}
+		s.warnings.note(rule.Path, WarningCodeCoerceType)
+		return nil
 	}
+	return step, nil
+}
 
-	for _, rule := range normalizedPlan.DefaultValues {
-		for index := range records {
-			value, exists, err := getValueAtPath(records[index], rule.Path)
-			if err != nil {
-				return CanonicalData{}, nil, err
-			}
-			if !exists || value == nil {
-				if err := setValueAtPath(records[index], rule.Path, rule.Value); err != nil {
-					return CanonicalData{}, nil, err
-				}
+func (s *transformState) defaultValue(rule DefaultValueRule) (recordStep, error) {
+	path, err := ParsePath(rule.Path)
+	if err != nil {
+		return recordStep{}, err
+	}
+	return s.inPlace(func(record Record, index int, warnings *warningCollector) error {
+		targets, err := path.targets(record, true)
+		if err != nil {
+			return err
+		}
+		// Arrays are never grown, so an index past the end, or into a
+		// missing array, has no slot to fill.
+		if len(targets) == 0 && !path.wildcard {
+			return errors.New("default_values index is out of range for path: " + rule.Path)
+		}
+		for _, target := range targets {
+			if value, exists := target.value(); !exists || value == nil {
+				target.set(deepCopyValue(rule.Value))
 			}
 		}
+		return nil
+	}), nil
+}
+
+func (s *transformState) dropField(path string) (recordStep, error) {
+	compiled, err := ParsePath(path)
+	if err != nil {
+		return recordStep{}, err
+	}
+	step := s.inPlace(func(record Record, index int, warnings *warningCollector) error {
+		removed, err := compiled.remove(record)
+		if err != nil {
+			return err
+		}
+		drops := compiled.changes()
+		for _, value := range removed {
+			drops.add(value, nil)
+		}
+		drops.affect(warnings, path, WarningCodeDropField, index)
+		return nil
+	})
+	step.finish = func() error {
+		if _, err := requireLossyDecision(s.decisions, StrategyDropField, path); err != nil {
+			return err
+		}
+		s.warnings.note(path, WarningCodeDropField)
+		return nil
 	}
+	return step, nil
+}
 
-	for _, path := range normalizedPlan.DropFields {
-		for index := range records {
-			if err := deleteValueAtPath(records[index], path); err != nil {
-				return CanonicalData{}, nil, err
-			}
+func (s *transformState) renameField(rule RenameFieldRule) (recordStep, error) {
+	if err := validateRename(rule); err != nil {
+		return recordStep{}, err
+	}
+	overwrite := rule.OnConflict == RenameConflictOverwrite
+	move, err := newPathMove(rule.Path, rule.To)
+	if err != nil {
+		return recordStep{}, err
+	}
+	step := s.inPlace(func(record Record, index int, warnings *warningCollector) error {
+		overwritten := &pathChanges{wildcard: move.scope != nil}
+		if err := move.apply(record, overwrite, overwritten); err != nil {
+			return err
 		}
-		if _, err := requireLossyDecision(decisionMap, StrategyDropField, path); err != nil {
-			return CanonicalData{}, nil, err
+		overwritten.affect(warnings, rule.To, WarningCodeOverwriteField, index)
+		return nil
+	})
+	step.finish = func() error {
+		if overwrite {
+			if _, err := requireLossyDecision(s.decisions, StrategyOverwriteField, rule.To); err != nil {
+				return err
+			}
+			s.warnings.note(rule.To, WarningCodeOverwriteField)
 		}
-		addWarningOnce(&warnings, warningSet, path, WarningCodeDropField)
+		s.order = renameOrder(s.order, rule.Path, rule.To)
+		return nil
 	}
+	return step, nil
+}
 
-	output := CanonicalData{Values: DataValues{Records: records}}
-	if len(records) > 0 {
-		output.Shape = BuildShapeFromRecords(records)
-	} else {
-		output.Shape = input.Shape
+func validateRename(rule RenameFieldRule) error {
+	switch rule.OnConflict {
+	case "", RenameConflictError, RenameConflictOverwrite:
+	default:
+		return errors.New("unsupported rename on_conflict strategy for path: " + rule.Path)
 	}
-	return output, warnings, nil
+	if rule.Path == "" || rule.To == "" {
+		return errors.New("rename requires path and to")
+	}
+	if PathWithin(rule.To, rule.Path) || PathWithin(rule.Path, rule.To) {
+		return errors.New("rename target overlaps source: " + rule.Path + " -> " + rule.To)
+	}
+	return nil
 }
 
-func joinArrayValues(values []any, delimiter string) (string, error) {
+func joinArrayValues(values []any, rule JoinArrayRule) (string, bool, error) {
 	parts := make([]string, 0, len(values))
 	for _, item := range values {
 		if item == nil {
@@ -156,6 +638,10 @@ func joinArrayValues(values []any, delimiter string) (string, error) {
 		switch value := item.(type) {
 		case string:
 			parts = append(parts, value)
+		case json.Number:
+			parts = append(parts, value.String())
+		case Date, time.Time, Duration:
+			parts = append(parts, valueText(value))
 		case float64:
 			parts = append(parts, strconv.FormatFloat(value, 'f', -1, 64))
 		case float32:
@@ -165,95 +651,133 @@ func joinArrayValues(values []any, delimiter string) (string, error) {
 		case bool:
 			parts = append(parts, strconv.FormatBool(value))
 		default:
-			return "", errors.New("join array contains non-scalar value")
+			return "", false, errors.New("join array contains non-scalar value")
+		}
+	}
+	return encodeJoinParts(parts, rule)
+}
+
+// splitStringValue splits text into array items. An empty string yields an
+// empty array; empty parts become nil when coerced to a non-string type,
+// mirroring joinArrayValues which renders nil items as empty strings.
+// The bool reports an element that lost precision.
+func splitStringValue(text string, rule SplitStringRule) ([]any, bool, error) {
+	if text == "" {
+		return []any{}, false, nil
+	}
+	pieces, err := decodeSplitParts(text, rule)
+	if err != nil {
+		return nil, false, err
+	}
+	narrowedAny := false
+	parts := make([]any, 0, len(pieces))
+	for _, piece := range pieces {
+		if rule.Trim {
+			piece = strings.TrimSpace(piece)
+		}
+		if rule.ElementType == "" || rule.ElementType == LogicalTypeString {
+			parts = append(parts, piece)
+			continue
 		}
+		if piece == "" {
+			parts = append(parts, nil)
+			continue
+		}
+		coerced, narrowed, err := coerceValue(piece, rule.ElementType)
+		if err != nil {
+			return nil, false, errors.New(err.Error() + " in split string at path: " + rule.Path)
+		}
+		narrowedAny = narrowedAny || narrowed
+		parts = append(parts, coerced)
 	}
-	return strings.Join(parts, delimiter), nil
+	return parts, narrowedAny, nil
 }
 
-func coerceValue(value any, targetType LogicalType) (any, error) {
+// coercionChanged reports whether a coercion changed the Go type or the
+// rendered text of a value, so values already of the target type are not
+// reported as coerced.
+func coercionChanged(before any, after any) bool {
+	return reflect.TypeOf(before) != reflect.TypeOf(after) || valueText(before) != valueText(after)
+}
+
+// coerceRuleValue applies a coercion rule to one value, honoring the rule's
+// layouts and timezone for temporal targets.
+func coerceRuleValue(value any, rule TypeCoercionRule) (any, bool, error) {
+	if isTemporalType(rule.TargetType) {
+		coerced, err := coerceTemporal(value, rule)
+		return coerced, false, err
+	}
+	return coerceValue(value, rule.TargetType)
+}
+
+// coerceValue converts value to targetType. narrowed reports a conversion
+// to number that changed the value.
+func coerceValue(value any, targetType LogicalType) (coerced any, narrowed bool, err error) {
 	switch targetType {
 	case LogicalTypeString:
-		return fmt.Sprint(value), nil
+		return valueText(value), false, nil
+	case LogicalTypeDate, LogicalTypeDatetime, LogicalTypeDuration:
+		coerced, err := coerceTemporal(value, TypeCoercionRule{TargetType: targetType})
+		return coerced, false, err
 	case LogicalTypeNumber:
-		switch typed := value.(type) {
-		case float64:
-			return typed, nil
-		case float32:
-			return float64(typed), nil
-		case int:
-			return float64(typed), nil
-		case int64:
-			return float64(typed), nil
-		case int32:
-			return float64(typed), nil
-		case uint:
-			return float64(typed), nil
-		case uint64:
-			return float64(typed), nil
-		case uint32:
-			return float64(typed), nil
-		case bool:
-			if typed {
-				return float64(1), nil
-			}
-			return float64(0), nil
-		case string:
-			parsed, err := strconv.ParseFloat(strings.TrimSpace(typed), 64)
-			if err != nil {
-				return nil, errors.New("cannot coerce string to number")
-			}
-			return parsed, nil
-		default:
-			return nil, errors.New("cannot coerce value to number")
-		}
+		coerced, narrowed, err := coerceNumber(value)
+		return coerced, narrowed, err
+	case LogicalTypeInteger:
+		coerced, err := coerceInteger(value)
+		return coerced, false, err
+	case LogicalTypeDecimal:
+		coerced, err := coerceDecimal(value)
+		return coerced, false, err
 	case LogicalTypeBoolean:
 		switch typed := value.(type) {
 		case bool:
-			return typed, nil
+			return typed, false, nil
 		case string:
 			parsed, err := strconv.ParseBool(strings.TrimSpace(typed))
 			if err != nil {
-				return nil, errors.New("cannot coerce string to boolean")
+				return nil, false, errors.New("cannot coerce string to boolean")
 			}
-			return parsed, nil
-		case float64:
-			return typed != 0, nil
-		case float32:
-			return typed != 0, nil
-		case int:
-			return typed != 0, nil
-		case int64:
-			return typed != 0, nil
-		case int32:
-			return typed != 0, nil
-		case uint:
-			return typed != 0, nil
-		case uint64:
-			return typed != 0, nil
-		case uint32:
-			return typed != 0, nil
+			return parsed, false, nil
+		case json.Number, float64, float32, int, int64, int32, uint, uint64, uint32:
+			exact, ok := exactValue(typed)
+			if !ok {
+				return nil, false, errors.New("cannot coerce value to boolean")
+			}
+			return exact.Sign() != 0, false, nil
 		default:
-			return nil, errors.New("cannot coerce value to boolean")
+			return nil, false, errors.New("cannot coerce value to boolean")
 		}
 	default:
-		return nil, errors.New("unsupported target type for coercion")
+		return nil, false, errors.New("unsupported target type for coercion")
 	}
 }
 
-func addWarningOnce(warnings *[]Warning, warningSet map[string]struct{}, path string, code WarningCode) {
-	key := path + ":" + string(code)
-	if _, exists := warningSet[key]; exists {
-		return
+// narrowNumber records the conversions that changed a number's value in one
+// record, if any. They need
+// a narrow_number lossy decision for the path.
+func (s *transformState) narrowNumber(warnings *warningCollector, path string, index int, narrowed *pathChanges) error {
+	if !narrowed.changed() {
+		return nil
 	}
-	warningSet[key] = struct{}{}
-	*warnings = append(*warnings, WarningFor(code, path))
-	sort.SliceStable(*warnings, func(i, j int) bool {
-		if (*warnings)[i].Path == (*warnings)[j].Path {
-			return (*warnings)[i].Code < (*warnings)[j].Code
-		}
-		return (*warnings)[i].Path < (*warnings)[j].Path
-	})
+	if _, err := requireLossyDecision(s.decisions, StrategyNarrowNumber, path); err != nil {
+		return errors.New("number loses precision as floating point; requires narrow_number lossy_decisions entry for path: " + path)
+	}
+	narrowed.affect(warnings, path, WarningCodeNarrowNumber, index)
+	return nil
+}
+
+// truncateTime records the datetimes whose time of day or zone a date
+// coercion dropped in one record, if any. They need a truncate_time lossy
+// decision for the path.
+func (s *transformState) truncateTime(warnings *warningCollector, path string, index int, truncated *pathChanges) error {
+	if !truncated.changed() {
+		return nil
+	}
+	if _, err := requireLossyDecision(s.decisions, StrategyTruncateTime, path); err != nil {
+		return errors.New("datetime loses its time of day or zone as a date; requires truncate_time lossy_decisions entry for path: " + path)
+	}
+	truncated.affect(warnings, path, WarningCodeTruncateTime, index)
+	return nil
 }
 
 func requireLossyDecision(decisions map[string]LossyDecision, strategy Strategy, path string) (LossyDecision, error) {
@@ -281,6 +805,12 @@ func deepCopyRecord(record Record) Record {
 	return copied
 }
 
+// DeepCopyValue returns a copy of value that shares no maps or slices with
+// it.
+func DeepCopyValue(value any) any {
+	return deepCopyValue(value)
+}
+
 func deepCopyValue(value any) any {
 	switch typed := value.(type) {
 	case map[string]any:
@@ -1,5 +1,13 @@
 package core
 
+import (
+	"math/bits"
+	"sort"
+)
+
+// WarningSampleLimit caps the record indices and samples kept per warning.
+const WarningSampleLimit = 5
+
 // WarningMessage returns the default message for a warning code.
 func WarningMessage(code WarningCode) string {
 	switch code {
@@ -9,6 +17,22 @@ func WarningMessage(code WarningCode) string {
 		return "dropped field"
 	case WarningCodeCoerceType:
 		return "coerced type"
+	case WarningCodeSplitString:
+		return "split string into array"
+	case WarningCodeJoinCollision:
+		return "joined array elements contained the delimiter"
+	case WarningCodeOverwriteField:
+		return "rename overwrote existing field"
+	case WarningCodeNarrowNumber:
+		return "number lost precision as floating point"
+	case WarningCodeTruncateTime:
+		return "datetime lost its time of day or zone as a date"
+	case WarningCodeConstraintViolation:
+		return "value violates constraint"
+	case WarningCodeNullInvalid:
+		return "replaced value violating constraint with null"
+	case WarningCodeDropRecord:
+		return "dropped record violating constraint"
 	default:
 		return "warning"
 	}
@@ -21,3 +45,181 @@ func WarningFor(code WarningCode, path string) Warning {
 		Message: WarningMessage(code),
 	}
 }
+
+// warningCollector aggregates warnings by path, code, and rule, and the
+// records each lossy strategy affected across all paths.
+type warningCollector struct {
+	byKey      map[string]*Warning
+	byStrategy map[Strategy]*recordSet
+}
+
+func newWarningCollector() *warningCollector {
+	return &warningCollector{byKey: map[string]*Warning{}, byStrategy: map[Strategy]*recordSet{}}
+}
+
+// note registers a warning without attributing it to a record.
+func (c *warningCollector) note(path string, code WarningCode) *Warning {
+	return c.noteRule(path, code, "")
+}
+
+func (c *warningCollector) noteRule(path string, code WarningCode, rule string) *Warning {
+	key := path + ":" + string(code) + ":" + rule
+	warning, exists := c.byKey[key]
+	if !exists {
+		created := WarningFor(code, path)
+		created.Rule = rule
+		warning = &created
+		c.byKey[key] = warning
+	}
+	return warning
+}
+
+// affect records that the operation changed a record.
+func (c *warningCollector) affect(path string, code WarningCode, recordIndex int, before any, after any) {
+	c.affectRule(path, code, "", recordIndex, before, after)
+}
+
+// affectRule records a record affected under a named rule, such as a
+// violated constraint.
+func (c *warningCollector) affectRule(path string, code WarningCode, rule string, recordIndex int, before any, after any) {
+	warning := c.noteRule(path, code, rule)
+	warning.AffectedCount++
+	if strategy, ok := StrategyForWarning(code); ok {
+		set, exists := c.byStrategy[strategy]
+		if !exists {
+			set = &recordSet{}
+			c.byStrategy[strategy] = set
+		}
+		set.add(recordIndex)
+	}
+	if len(warning.RecordIndices) >= WarningSampleLimit {
+		return
+	}
+	warning.RecordIndices = append(warning.RecordIndices, recordIndex)
+	warning.Samples = append(warning.Samples, WarningSample{
+		RecordIndex: recordIndex,
+		Before:      deepCopyValue(before),
+		After:       deepCopyValue(after),
+	})
+}
+
+// affected reports whether any record was affected under path and code.
+func (c *warningCollector) affected(path string, code WarningCode) bool {
+	warning, exists := c.byKey[path+":"+string(code)+":"]
+	return exists && warning.AffectedCount > 0
+}
+
+// merge adds the warnings other collected for records that follow the ones
+// collected here, as if both had been collected in sequence.
+func (c *warningCollector) merge(other *warningCollector) {
+	for strategy, records := range other.byStrategy {
+		set, exists := c.byStrategy[strategy]
+		if !exists {
+			set = &recordSet{}
+			c.byStrategy[strategy] = set
+		}
+		set.merge(records)
+	}
+	for _, warning := range other.byKey {
+		target := c.noteRule(warning.Path, warning.Code, warning.Rule)
+		target.AffectedCount += warning.AffectedCount
+		for index, recordIndex := range warning.RecordIndices {
+			if len(target.RecordIndices) >= WarningSampleLimit {
+				break
+			}
+			target.RecordIndices = append(target.RecordIndices, recordIndex)
+			target.Samples = append(target.Samples, warning.Samples[index])
+		}
+	}
+}
+
+// strategyRecords returns the number of distinct records each lossy
+// strategy affected.
+func (c *warningCollector) strategyRecords() map[Strategy]int {
+	counts := make(map[Strategy]int, len(c.byStrategy))
+	for strategy, set := range c.byStrategy {
+		counts[strategy] = set.count
+	}
+	return counts
+}
+
+// recordSet holds record indices as bits, so counting distinct records
+// costs one bit per record.
+type recordSet struct {
+	words []uint64
+	count int
+}
+
+func (r *recordSet) add(index int) {
+	word := index / 64
+	for len(r.words) <= word {
+		r.words = append(r.words, 0)
+	}
+	bit := uint64(1) << (index % 64)
+	if r.words[word]&bit == 0 {
+		r.words[word] |= bit
+		r.count++
+	}
+}
+
+func (r *recordSet) merge(other *recordSet) {
+	for len(r.words) < len(other.words) {
+		r.words = append(r.words, 0)
+	}
+	for index, word := range other.words {
+		r.count += bits.OnesCount64(word &^ r.words[index])
+		r.words[index] |= word
+	}
+}
+
+// list returns warnings ordered by path, code, then rule.
+func (c *warningCollector) list() []Warning {
+	warnings := make([]Warning, 0, len(c.byKey))
+	for _, warning := range c.byKey {
+		warnings = append(warnings, *warning)
+	}
+	sort.Slice(warnings, func(i, j int) bool {
+		if warnings[i].Path == warnings[j].Path {
+			if warnings[i].Code == warnings[j].Code {
+				return warnings[i].Rule < warnings[j].Rule
+			}
+			return warnings[i].Code < warnings[j].Code
+		}
+		return warnings[i].Path < warnings[j].Path
+	})
+	return warnings
+}
+
+// pathChanges gathers the values a rule changes at the matches of its path
+// in one record, so the record counts once in the warning. For a path with
+// a wildcard the samples list the values of every match.
+type pathChanges struct {
+	wildcard bool
+	before   []any
+	after    []any
+}
+
+func (p Path) changes() *pathChanges {
+	return &pathChanges{wildcard: p.wildcard}
+}
+
+func (c *pathChanges) add(before any, after any) {
+	c.before = append(c.before, before)
+	c.after = append(c.after, after)
+}
+
+func (c *pathChanges) changed() bool {
+	return len(c.before) > 0
+}
+
+// affect records the record as affected when any match changed.
+func (c *pathChanges) affect(warnings *warningCollector, path string, code WarningCode, recordIndex int) {
+	if !c.changed() {
+		return
+	}
+	if c.wildcard {
+		warnings.affect(path, code, recordIndex, c.before, c.after)
+		return
+	}
+	warnings.affect(path, code, recordIndex, c.before[0], c.after[0])
+}
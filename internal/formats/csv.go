@@ -3,9 +3,12 @@ package formats
 import (
 	"bytes"
 	"encoding/csv"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"sort"
+	"time"
 
 	"reshape/internal/core"
 )
@@ -23,59 +26,104 @@ func ParseCSV(input []byte) (core.CanonicalData, error) {
 	headers := rows[0]
 	records := make([]core.Record, 0, len(rows)-1)
 	for _, row := range rows[1:] {
-		if len(row) != len(headers) {
-			return core.CanonicalData{}, errors.New("csv row has different column count than headers")
-		}
-		record := core.Record{}
-		for index, header := range headers {
-			value := row[index]
-			if value == "" {
-				record[header] = nil
-				continue
-			}
-			record[header] = value
+		record, err := csvRecord(headers, row)
+		if err != nil {
+			return core.CanonicalData{}, err
 		}
 		records = append(records, record)
 	}
 
 	shape := core.BuildShapeFromRecords(records)
+	shape.SourceOrder = headerPaths(headers)
 	return core.CanonicalData{
 		Shape:  shape,
 		Values: core.DataValues{Records: records},
 	}, nil
 }
 
-// RenderCSV converts canonical data into CSV bytes.
+// headerPaths returns the paths of the fields named by headers. Each header
+// is a top-level key, quoted where a bare key cannot hold it.
+func headerPaths(headers []string) []string {
+	paths := make([]string, len(headers))
+	for index, header := range headers {
+		paths[index] = core.AppendPathKey("", header, false)
+	}
+	return paths
+}
+
+// csvRecord maps a row to its headers. Empty cells become nil.
+func csvRecord(headers []string, row []string) (core.Record, error) {
+	if len(row) != len(headers) {
+		return nil, errors.New("csv row has different column count than headers")
+	}
+	record := core.Record{}
+	for index, header := range headers {
+		value := row[index]
+		if value == "" {
+			record[header] = nil
+			continue
+		}
+		record[header] = value
+	}
+	return record, nil
+}
+
+// CSVOptions configures how CSV output renders temporal values. Layouts are
+// Go time layouts; empty layouts render ISO 8601. Durations always use
+// ISO 8601.
+type CSVOptions struct {
+	DateLayout     string
+	DatetimeLayout string
+}
+
+// ParseCSVOptions builds CSVOptions from format options.
+// Supported keys are "date_layout" and "datetime_layout".
+func ParseCSVOptions(options Options) (CSVOptions, error) {
+	parsed := CSVOptions{}
+	keys := make([]string, 0, len(options))
+	for key := range options {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value := options[key]
+		switch key {
+		case "date_layout":
+			parsed.DateLayout = value
+		case "datetime_layout":
+			parsed.DatetimeLayout = value
+		default:
+			return CSVOptions{}, errors.New("unknown csv option: " + key)
+		}
+	}
+	return parsed, nil
+}
+
+// RenderCSV converts canonical data into CSV bytes. Columns are sorted by
+// path unless the shape declares its order; field labels replace paths in
+// the header row.
 func RenderCSV(data core.CanonicalData) ([]byte, error) {
-	headers := schemaHeaders(data)
+	return RenderCSVWithOptions(data, CSVOptions{})
+}
+
+// RenderCSVWithOptions converts canonical data into CSV bytes using explicit
+// temporal layouts.
+func RenderCSVWithOptions(data core.CanonicalData, options CSVOptions) ([]byte, error) {
+	headers, labels := schemaHeaders(data)
+	paths, err := parseHeaders(headers)
+	if err != nil {
+		return nil, err
+	}
 
 	buffer := &bytes.Buffer{}
 	writer := csv.NewWriter(buffer)
-	if err := writer.Write(headers); err != nil {
+	if err := writer.Write(labels); err != nil {
 		return nil, err
 	}
 	for _, record := range data.Values.Records {
-		row := make([]string, len(headers))
-		for index, header := range headers {
-			value, exists, err := core.ValueAtPath(record, header)
-			if err != nil {
-				return nil, err
-			}
-			if !exists || value == nil {
-				row[index] = ""
-				continue
-			}
-			if _, ok := value.(map[string]any); ok {
-				return nil, fmt.Errorf("csv output requires scalar at path %s", header)
-			}
-			if _, ok := value.([]any); ok {
-				return nil, fmt.Errorf("csv output requires scalar at path %s", header)
-			}
-			scalar, err := formatScalar(value)
-			if err != nil {
-				return nil, err
-			}
-			row[index] = scalar
+		row, err := csvRow(record, paths, options)
+		if err != nil {
+			return nil, err
 		}
 		if err := writer.Write(row); err != nil {
 			return nil, err
@@ -88,7 +136,63 @@ func RenderCSV(data core.CanonicalData) ([]byte, error) {
 	return buffer.Bytes(), nil
 }
 
-func schemaHeaders(data core.CanonicalData) []string {
+// parseHeaders parses the header paths once for every row.
+func parseHeaders(headers []string) ([]core.Path, error) {
+	paths := make([]core.Path, len(headers))
+	for index, header := range headers {
+		path, err := core.ParsePath(header)
+		if err != nil {
+			return nil, err
+		}
+		paths[index] = path
+	}
+	return paths, nil
+}
+
+// csvRow renders the values of record at the header paths.
+func csvRow(record core.Record, paths []core.Path, options CSVOptions) ([]string, error) {
+	row := make([]string, len(paths))
+	for index, path := range paths {
+		value, exists, err := path.Value(record)
+		if err != nil {
+			return nil, err
+		}
+		if !exists || value == nil {
+			row[index] = ""
+			continue
+		}
+		if _, ok := value.(map[string]any); ok {
+			return nil, fmt.Errorf("csv output requires scalar at path %s", path)
+		}
+		if _, ok := value.([]any); ok {
+			return nil, fmt.Errorf("csv output requires scalar at path %s", path)
+		}
+		scalar, err := formatScalar(value, options)
+		if err != nil {
+			return nil, err
+		}
+		row[index] = scalar
+	}
+	return row, nil
+}
+
+// schemaHeaders returns the column paths and their header labels. A
+// top-level field is labeled with its raw key, so headers a CSV input
+// carried come back unchanged; nested fields are labeled with their path.
+func schemaHeaders(data core.CanonicalData) ([]string, []string) {
+	if data.Shape.Order == core.ShapeOrderDeclared && len(data.Shape.Fields) > 0 {
+		headers := make([]string, 0, len(data.Shape.Fields))
+		labels := make([]string, 0, len(data.Shape.Fields))
+		for _, field := range data.Shape.Fields {
+			headers = append(headers, field.Path)
+			label := field.Label
+			if label == "" {
+				label = headerLabel(field.Path)
+			}
+			labels = append(labels, label)
+		}
+		return headers, labels
+	}
 	headers := []string{}
 	for _, field := range data.Shape.Fields {
 		headers = append(headers, field.Path)
@@ -96,8 +200,39 @@ func schemaHeaders(data core.CanonicalData) []string {
 	if len(headers) == 0 {
 		headers = recordHeaders(data.Values.Records)
 	}
-	sort.Strings(headers)
-	return headers
+	labels := make([]string, len(headers))
+	for index, header := range headers {
+		labels[index] = headerLabel(header)
+	}
+	order := make([]int, len(headers))
+	for index := range order {
+		order[index] = index
+	}
+	sort.Slice(order, func(i, j int) bool {
+		if labels[order[i]] != labels[order[j]] {
+			return labels[order[i]] < labels[order[j]]
+		}
+		return headers[order[i]] < headers[order[j]]
+	})
+	sortedHeaders := make([]string, len(order))
+	sortedLabels := make([]string, len(order))
+	for index, column := range order {
+		sortedHeaders[index] = headers[column]
+		sortedLabels[index] = labels[column]
+	}
+	return sortedHeaders, sortedLabels
+}
+
+// headerLabel returns the raw key of a top-level path, or the path itself.
+func headerLabel(path string) string {
+	parsed, err := core.ParsePath(path)
+	if err != nil {
+		return path
+	}
+	if key, ok := parsed.TopLevelKey(); ok {
+		return key
+	}
+	return path
 }
 
 func recordHeaders(records []core.Record) []string {
@@ -109,18 +244,143 @@ func recordHeaders(records []core.Record) []string {
 	}
 	result := make([]string, 0, len(set))
 	for key := range set {
-		result = append(result, key)
+		result = append(result, core.AppendPathKey("", key, false))
 	}
 	return result
 }
 
-func formatScalar(value any) (string, error) {
+func newCSVEncoder(options Options) (Encoder, error) {
+	parsed, err := ParseCSVOptions(options)
+	if err != nil {
+		return nil, err
+	}
+	return EncoderFunc(func(data core.CanonicalData) ([]byte, error) {
+		return RenderCSVWithOptions(data, parsed)
+	}), nil
+}
+
+func formatScalar(value any, options CSVOptions) (string, error) {
 	switch typed := value.(type) {
 	case string:
 		return typed, nil
+	case core.Date:
+		if options.DateLayout != "" {
+			return typed.Format(options.DateLayout), nil
+		}
+		return typed.String(), nil
+	case time.Time:
+		if options.DatetimeLayout != "" {
+			return typed.Format(options.DatetimeLayout), nil
+		}
+		return typed.Format(core.DefaultDatetimeLayout), nil
+	case core.Duration:
+		return typed.String(), nil
+	case json.Number:
+		return typed.String(), nil
 	case float64, float32, int, int64, int32, uint, uint64, uint32, bool:
 		return fmt.Sprint(typed), nil
 	default:
 		return "", errors.New("csv output contains unsupported value type")
 	}
 }
+
+// csvRecordReader reads one row per record.
+type csvRecordReader struct {
+	reader  *csv.Reader
+	headers []string
+}
+
+func newCSVRecordReader(input io.Reader, options Options) (RecordReader, error) {
+	if err := rejectOptions(options, "format does not accept decode options: csv"); err != nil {
+		return nil, err
+	}
+	reader := csv.NewReader(input)
+	reader.ReuseRecord = true
+	headers, err := reader.Read()
+	if err == io.EOF {
+		return nil, errors.New("csv input is empty")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &csvRecordReader{reader: reader, headers: append([]string(nil), headers...)}, nil
+}
+
+func (r *csvRecordReader) Read() (core.Record, error) {
+	row, err := r.reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	return csvRecord(r.headers, row)
+}
+
+func (r *csvRecordReader) SourceOrder() []string {
+	return headerPaths(r.headers)
+}
+
+// csvRecordWriter writes the header row from the shape up front, so every
+// record field must be one of its columns.
+type csvRecordWriter struct {
+	writer  *csv.Writer
+	paths   []core.Path
+	columns map[string]struct{}
+	options CSVOptions
+}
+
+func newCSVRecordWriter(output io.Writer, shape *core.DataShape, options Options) (RecordWriter, error) {
+	parsed, err := ParseCSVOptions(options)
+	if err != nil {
+		return nil, err
+	}
+	if shape == nil {
+		return nil, errors.New("csv streaming output requires streaming shape declared or two_pass")
+	}
+	headers, labels := schemaHeaders(core.CanonicalData{Shape: *shape})
+	paths, err := parseHeaders(headers)
+	if err != nil {
+		return nil, err
+	}
+	writer := csv.NewWriter(output)
+	if err := writer.Write(labels); err != nil {
+		return nil, err
+	}
+	columns := make(map[string]struct{}, len(headers))
+	for _, header := range headers {
+		columns[header] = struct{}{}
+	}
+	return &csvRecordWriter{writer: writer, paths: paths, columns: columns, options: parsed}, nil
+}
+
+func (w *csvRecordWriter) Write(record core.Record) error {
+	if err := w.checkColumns(map[string]any(record), ""); err != nil {
+		return err
+	}
+	row, err := csvRow(record, w.paths, w.options)
+	if err != nil {
+		return err
+	}
+	return w.writer.Write(row)
+}
+
+// checkColumns fails for values that no column would hold.
+func (w *csvRecordWriter) checkColumns(value map[string]any, prefix string) error {
+	for key, nested := range value {
+		path := core.ChildPath(prefix, key, nested)
+		if _, exists := w.columns[path]; exists {
+			continue
+		}
+		if object, ok := nested.(map[string]any); ok && len(object) > 0 {
+			if err := w.checkColumns(object, path); err != nil {
+				return err
+			}
+			continue
+		}
+		return errors.New("csv record has field not in shape: " + path)
+	}
+	return nil
+}
+
+func (w *csvRecordWriter) Close() error {
+	w.writer.Flush()
+	return w.writer.Error()
+}
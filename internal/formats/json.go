@@ -3,21 +3,160 @@ package formats
 import (
 	"encoding/json"
 	"errors"
+	"sort"
+	"strings"
 
 	"reshape/internal/core"
 )
 
-// ParseJSON converts JSON bytes into canonical data.
+// JSONEnvelope describes how records are laid out in a JSON document.
+type JSONEnvelope string
+
+const (
+	// JSONEnvelopeArray always uses a top-level array of records.
+	JSONEnvelopeArray JSONEnvelope = "array"
+	// JSONEnvelopeObjectWhenSingle uses a bare object for exactly one record
+	// and an array otherwise. This is the behavior of ParseJSON and RenderJSON.
+	JSONEnvelopeObjectWhenSingle JSONEnvelope = "object_when_single"
+	// JSONEnvelopeWrapped places the record array under RecordsKey of a
+	// top-level object, e.g. {"records": [...]}.
+	JSONEnvelopeWrapped JSONEnvelope = "wrapped"
+)
+
+// JSONOptions configures the JSON record layout.
+//
+// RecordRoot and CarryFields apply to decoding only. RecordRoot is a path
+// selecting the value the envelope applies to, and each CarryFields path is
+// read from the whole document and copied to the same path in every record.
+type JSONOptions struct {
+	Envelope    JSONEnvelope
+	RecordsKey  string
+	RecordRoot  string
+	CarryFields []string
+}
+
+// ParseJSONOptions builds JSONOptions from format options.
+// Supported keys are "envelope", "records_key", "record_root", and
+// "carry_fields" (a comma-separated list of paths).
+func ParseJSONOptions(options Options) (JSONOptions, error) {
+	parsed := JSONOptions{Envelope: JSONEnvelopeObjectWhenSingle}
+	keys := make([]string, 0, len(options))
+	for key := range options {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value := options[key]
+		switch key {
+		case "envelope":
+			parsed.Envelope = JSONEnvelope(value)
+		case "records_key":
+			parsed.RecordsKey = value
+		case "record_root":
+			parsed.RecordRoot = strings.TrimSpace(value)
+		case "carry_fields":
+			for _, path := range strings.Split(value, ",") {
+				path = strings.TrimSpace(path)
+				if path == "" {
+					return JSONOptions{}, errors.New("json carry_fields contains an empty path")
+				}
+				parsed.CarryFields = append(parsed.CarryFields, path)
+			}
+		default:
+			return JSONOptions{}, errors.New("unknown json option: " + key)
+		}
+	}
+	if err := parsed.validate(); err != nil {
+		return JSONOptions{}, err
+	}
+	return parsed.canonicalPaths()
+}
+
+// canonicalPaths returns o with RecordRoot and CarryFields in canonical
+// path form, so they can be compared with the paths of decoded fields. Both
+// name object keys only.
+func (o JSONOptions) canonicalPaths() (JSONOptions, error) {
+	if o.RecordRoot != "" {
+		path, err := parseJSONKeyPath(o.RecordRoot, "record_root")
+		if err != nil {
+			return JSONOptions{}, err
+		}
+		o.RecordRoot = path
+	}
+	var carried []string
+	for _, text := range o.CarryFields {
+		path, err := parseJSONKeyPath(text, "carry_fields")
+		if err != nil {
+			return JSONOptions{}, err
+		}
+		carried = append(carried, path)
+	}
+	o.CarryFields = carried
+	return o, nil
+}
+
+func parseJSONKeyPath(text string, option string) (string, error) {
+	path, err := core.ParsePath(text)
+	if err != nil {
+		return "", errors.New("json " + option + ": " + err.Error())
+	}
+	if !path.KeysOnly() {
+		return "", errors.New("json " + option + " must not have an index or wildcard: " + path.String())
+	}
+	return path.String(), nil
+}
+
+func (o JSONOptions) validate() error {
+	switch o.Envelope {
+	case JSONEnvelopeArray, JSONEnvelopeObjectWhenSingle:
+		if o.RecordsKey != "" {
+			return errors.New("json records_key requires envelope wrapped")
+		}
+	case JSONEnvelopeWrapped:
+		if o.RecordsKey == "" {
+			return errors.New("json envelope wrapped requires records_key")
+		}
+	default:
+		return errors.New("unsupported json envelope: " + string(o.Envelope))
+	}
+	return nil
+}
+
+// ParseJSON converts JSON bytes into canonical data. Numbers are kept as
+// json.Number so integers and decimals keep their exact text.
 func ParseJSON(input []byte) (core.CanonicalData, error) {
-	var decoded any
-	if err := json.Unmarshal(input, &decoded); err != nil {
+	return ParseJSONWithOptions(input, JSONOptions{Envelope: JSONEnvelopeObjectWhenSingle})
+}
+
+// ParseJSONWithOptions converts JSON bytes into canonical data using an explicit envelope.
+func ParseJSONWithOptions(input []byte, options JSONOptions) (core.CanonicalData, error) {
+	if err := options.validate(); err != nil {
+		return core.CanonicalData{}, err
+	}
+	options, err := options.canonicalPaths()
+	if err != nil {
 		return core.CanonicalData{}, err
 	}
-	records, err := jsonToRecords(decoded)
+	decoded, keys, err := decodeOrderedJSON(input)
 	if err != nil {
 		return core.CanonicalData{}, err
 	}
+	root, err := jsonRecordRoot(decoded, options.RecordRoot)
+	if err != nil {
+		return core.CanonicalData{}, err
+	}
+	records, err := jsonEnvelopeRecords(root, options)
+	if err != nil {
+		return core.CanonicalData{}, err
+	}
+	if err := carryJSONFields(decoded, records, options.CarryFields); err != nil {
+		return core.CanonicalData{}, err
+	}
 	shape := core.BuildShapeFromRecords(records)
+	shape.SourceOrder, err = jsonSourceOrder(decoded, records, keys, options)
+	if err != nil {
+		return core.CanonicalData{}, err
+	}
 	return core.CanonicalData{
 		Shape:  shape,
 		Values: core.DataValues{Records: records},
@@ -26,13 +165,142 @@ func ParseJSON(input []byte) (core.CanonicalData, error) {
 
 // RenderJSON converts canonical data into JSON bytes.
 func RenderJSON(data core.CanonicalData) ([]byte, error) {
-	if len(data.Values.Records) == 0 {
-		return json.Marshal([]any{})
+	return RenderJSONWithOptions(data, JSONOptions{Envelope: JSONEnvelopeObjectWhenSingle})
+}
+
+// RenderJSONWithOptions converts canonical data into JSON bytes using an explicit envelope.
+// Object keys follow the shape when it declares its order.
+func RenderJSONWithOptions(data core.CanonicalData, options JSONOptions) ([]byte, error) {
+	if err := options.validate(); err != nil {
+		return nil, err
+	}
+	records := data.Values.Records
+	if records == nil {
+		records = []core.Record{}
+	}
+	switch options.Envelope {
+	case JSONEnvelopeWrapped:
+		encoded, err := marshalRecords(records, data.Shape)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(map[string]json.RawMessage{options.RecordsKey: encoded})
+	case JSONEnvelopeObjectWhenSingle:
+		if len(records) == 1 {
+			return marshalRecord(records[0], data.Shape)
+		}
+	}
+	return marshalRecords(records, data.Shape)
+}
+
+func newJSONDecoder(options Options) (Decoder, error) {
+	parsed, err := ParseJSONOptions(options)
+	if err != nil {
+		return nil, err
+	}
+	return DecoderFunc(func(input []byte) (core.CanonicalData, error) {
+		return ParseJSONWithOptions(input, parsed)
+	}), nil
+}
+
+func newJSONEncoder(options Options) (Encoder, error) {
+	parsed, err := parseJSONEncodeOptions(options)
+	if err != nil {
+		return nil, err
+	}
+	return EncoderFunc(func(data core.CanonicalData) ([]byte, error) {
+		return RenderJSONWithOptions(data, parsed)
+	}), nil
+}
+
+func parseJSONEncodeOptions(options Options) (JSONOptions, error) {
+	parsed, err := ParseJSONOptions(options)
+	if err != nil {
+		return JSONOptions{}, err
+	}
+	if parsed.RecordRoot != "" || len(parsed.CarryFields) > 0 {
+		return JSONOptions{}, errors.New("json record_root and carry_fields are decode-only options")
+	}
+	return parsed, nil
+}
+
+func jsonRecordRoot(document any, path string) (any, error) {
+	if path == "" {
+		return document, nil
 	}
-	if len(data.Values.Records) == 1 {
-		return json.Marshal(data.Values.Records[0])
+	object, ok := document.(map[string]any)
+	if !ok {
+		return nil, errors.New("json record_root requires a top-level object")
+	}
+	value, exists, err := core.ValueAtPath(core.Record(object), path)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, errors.New("json record_root not found: " + path)
+	}
+	return value, nil
+}
+
+func carryJSONFields(document any, records []core.Record, paths []string) error {
+	if len(paths) == 0 {
+		return nil
+	}
+	object, ok := document.(map[string]any)
+	if !ok {
+		return errors.New("json carry_fields requires a top-level object")
+	}
+	for _, path := range paths {
+		compiled, err := core.ParsePath(path)
+		if err != nil {
+			return err
+		}
+		value, exists, err := compiled.Value(core.Record(object))
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return errors.New("json carry_fields path not found: " + path)
+		}
+		for _, record := range records {
+			_, present, err := compiled.Value(record)
+			if err != nil {
+				return err
+			}
+			if present {
+				return errors.New("json carry_fields path collides with record field: " + path)
+			}
+			// Each record gets its own copy, so changing one record's
+			// carried object leaves the others alone.
+			if err := compiled.Set(record, core.DeepCopyValue(value)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func jsonEnvelopeRecords(value any, options JSONOptions) ([]core.Record, error) {
+	switch options.Envelope {
+	case JSONEnvelopeArray:
+		if _, ok := value.([]any); !ok {
+			return nil, errors.New("json envelope array requires a top-level array")
+		}
+	case JSONEnvelopeWrapped:
+		wrapper, ok := value.(map[string]any)
+		if !ok {
+			return nil, errors.New("json envelope wrapped requires a top-level object")
+		}
+		nested, exists := wrapper[options.RecordsKey]
+		if !exists {
+			return nil, errors.New("json envelope wrapped is missing records_key: " + options.RecordsKey)
+		}
+		if _, ok := nested.([]any); !ok {
+			return nil, errors.New("json records_key must hold an array: " + options.RecordsKey)
+		}
+		value = nested
 	}
-	return json.Marshal(data.Values.Records)
+	return jsonToRecords(value)
 }
 
 func jsonToRecords(value any) ([]core.Record, error) {
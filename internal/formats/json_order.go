@@ -0,0 +1,234 @@
+package formats
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"sort"
+
+	"reshape/internal/core"
+)
+
+// pathOrder collects paths in the order they first appear. The fields of
+// array items are named under items[*], matching core.BuildShapeFromRecords.
+type pathOrder struct {
+	paths []string
+	seen  map[string]struct{}
+}
+
+func newPathOrder() *pathOrder {
+	return &pathOrder{seen: map[string]struct{}{}}
+}
+
+func (o *pathOrder) add(path string) {
+	if _, exists := o.seen[path]; exists {
+		return
+	}
+	o.seen[path] = struct{}{}
+	o.paths = append(o.paths, path)
+}
+
+// keyOrder holds the key order of each object decodeOrderedJSON built,
+// which a map does not keep, by the address of the map.
+type keyOrder map[uintptr][]string
+
+func (k keyOrder) keys(object map[string]any) []string {
+	return k[reflect.ValueOf(object).Pointer()]
+}
+
+// decodeOrderedJSON decodes a single JSON value like json.Unmarshal, except
+// that numbers are kept as json.Number so their exact text is preserved. It
+// records the key order of each object as it goes, so the input is
+// tokenized once.
+func decodeOrderedJSON(input []byte) (any, keyOrder, error) {
+	var decoded any
+	if !json.Valid(input) {
+		// Report the same syntax error json.Unmarshal would.
+		return nil, nil, json.Unmarshal(input, &decoded)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(input))
+	decoder.UseNumber()
+	keys := keyOrder{}
+	decoded, err := keys.decode(decoder)
+	if err != nil {
+		return nil, nil, err
+	}
+	return decoded, keys, nil
+}
+
+func (k keyOrder) decode(decoder *json.Decoder) (any, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch token {
+	case json.Delim('{'):
+		object := map[string]any{}
+		order := []string{}
+		for decoder.More() {
+			keyToken, err := decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+			key := keyToken.(string)
+			value, err := k.decode(decoder)
+			if err != nil {
+				return nil, err
+			}
+			if _, exists := object[key]; !exists {
+				order = append(order, key)
+			}
+			object[key] = value
+		}
+		k[reflect.ValueOf(object).Pointer()] = order
+		_, err = decoder.Token()
+		return object, err
+	case json.Delim('['):
+		items := []any{}
+		for decoder.More() {
+			item, err := k.decode(decoder)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		_, err = decoder.Token()
+		return items, err
+	default:
+		return token, nil
+	}
+}
+
+// addValue records the paths of the object keys in value under prefix, in
+// the order keys holds them.
+func (o *pathOrder) addValue(value any, prefix string, keys keyOrder) {
+	switch typed := value.(type) {
+	case map[string]any:
+		for _, key := range keys.keys(typed) {
+			path := core.ChildPath(prefix, key, typed[key])
+			o.add(path)
+			o.addValue(typed[key], path, keys)
+		}
+	case core.Record:
+		o.addValue(map[string]any(typed), prefix, keys)
+	case []any:
+		for _, item := range typed {
+			o.addValue(item, prefix+"[*]", keys)
+		}
+	}
+}
+
+// jsonSourceOrder returns the path order of the decoded records, followed by
+// the carried fields taken from document.
+func jsonSourceOrder(document any, records []core.Record, keys keyOrder, options JSONOptions) ([]string, error) {
+	order := newPathOrder()
+	for _, record := range records {
+		order.addValue(record, "", keys)
+	}
+	for _, carried := range options.CarryFields {
+		value, _, err := core.ValueAtPath(core.Record(document.(map[string]any)), carried)
+		if err != nil {
+			return nil, err
+		}
+		order.add(carried)
+		order.addValue(value, carried, keys)
+	}
+	return order.paths, nil
+}
+
+// marshalRecord encodes a record as JSON. When the shape declares its order,
+// object keys follow the shape and unknown keys come last, sorted; otherwise
+// keys are sorted as by encoding/json.
+func marshalRecord(record core.Record, shape core.DataShape) ([]byte, error) {
+	if shape.Order != core.ShapeOrderDeclared {
+		return json.Marshal(record)
+	}
+	rank := map[string]int{}
+	for index, field := range shape.Fields {
+		rank[field.Path] = index
+	}
+	buffer := &bytes.Buffer{}
+	if err := writeOrderedJSON(buffer, map[string]any(record), "", rank); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+func marshalRecords(records []core.Record, shape core.DataShape) ([]byte, error) {
+	if shape.Order != core.ShapeOrderDeclared {
+		return json.Marshal(records)
+	}
+	buffer := &bytes.Buffer{}
+	buffer.WriteByte('[')
+	for index, record := range records {
+		if index > 0 {
+			buffer.WriteByte(',')
+		}
+		encoded, err := marshalRecord(record, shape)
+		if err != nil {
+			return nil, err
+		}
+		buffer.Write(encoded)
+	}
+	buffer.WriteByte(']')
+	return buffer.Bytes(), nil
+}
+
+func writeOrderedJSON(buffer *bytes.Buffer, value any, prefix string, rank map[string]int) error {
+	switch typed := value.(type) {
+	case map[string]any:
+		keys := make([]string, 0, len(typed))
+		paths := make(map[string]string, len(typed))
+		for key, nested := range typed {
+			keys = append(keys, key)
+			paths[key] = core.ChildPath(prefix, key, nested)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			left, leftKnown := rank[paths[keys[i]]]
+			right, rightKnown := rank[paths[keys[j]]]
+			if leftKnown && rightKnown {
+				return left < right
+			}
+			if leftKnown != rightKnown {
+				return leftKnown
+			}
+			return keys[i] < keys[j]
+		})
+		buffer.WriteByte('{')
+		for index, key := range keys {
+			if index > 0 {
+				buffer.WriteByte(',')
+			}
+			encodedKey, err := json.Marshal(key)
+			if err != nil {
+				return err
+			}
+			buffer.Write(encodedKey)
+			buffer.WriteByte(':')
+			if err := writeOrderedJSON(buffer, typed[key], paths[key], rank); err != nil {
+				return err
+			}
+		}
+		buffer.WriteByte('}')
+		return nil
+	case []any:
+		buffer.WriteByte('[')
+		for index, item := range typed {
+			if index > 0 {
+				buffer.WriteByte(',')
+			}
+			if err := writeOrderedJSON(buffer, item, prefix+"[*]", rank); err != nil {
+				return err
+			}
+		}
+		buffer.WriteByte(']')
+		return nil
+	default:
+		encoded, err := json.Marshal(typed)
+		if err != nil {
+			return err
+		}
+		buffer.Write(encoded)
+		return nil
+	}
+}
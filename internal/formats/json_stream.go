@@ -0,0 +1,353 @@
+package formats
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+
+	"reshape/internal/core"
+)
+
+// jsonRecordReader walks a JSON document with a token decoder and decodes
+// one record at a time, so only the record being read is held in memory.
+// Values outside the records are skipped as they are passed.
+type jsonRecordReader struct {
+	decoder *json.Decoder
+	options JSONOptions
+	order   *pathOrder
+	started bool
+	// single holds the record of a top-level object; otherwise records come
+	// from the array the decoder is positioned in.
+	single core.Record
+	done   bool
+	// objects counts the enclosing objects entered on the way to the
+	// records, which are closed after the last record.
+	objects int
+	// preferred maps the level of an enclosing object entered through the
+	// next key of record_root to the literal key batch parsing would have
+	// preferred there; finding it while closing the object is an error.
+	preferred map[int]string
+}
+
+func newJSONRecordReader(input io.Reader, options Options) (RecordReader, error) {
+	parsed, err := ParseJSONOptions(options)
+	if err != nil {
+		return nil, err
+	}
+	if len(parsed.CarryFields) > 0 {
+		return nil, errors.New("json carry_fields is not supported when streaming")
+	}
+	return &jsonRecordReader{
+		decoder:   json.NewDecoder(bufio.NewReader(input)),
+		options:   parsed,
+		order:     newPathOrder(),
+		preferred: map[int]string{},
+	}, nil
+}
+
+func (r *jsonRecordReader) Read() (core.Record, error) {
+	if !r.started {
+		r.started = true
+		if err := r.open(); err != nil {
+			return nil, err
+		}
+	}
+	if r.done {
+		return nil, io.EOF
+	}
+	if r.single != nil {
+		record := r.single
+		r.single = nil
+		return record, r.close()
+	}
+	if !r.decoder.More() {
+		if _, err := r.decoder.Token(); err != nil {
+			return nil, err
+		}
+		if err := r.close(); err != nil {
+			return nil, err
+		}
+		return nil, io.EOF
+	}
+	var raw json.RawMessage
+	if err := r.decoder.Decode(&raw); err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(raw, []byte("{")) {
+		return nil, errors.New("json array contains non-object value")
+	}
+	return r.decodeRecord(raw)
+}
+
+func (r *jsonRecordReader) SourceOrder() []string {
+	return r.order.paths
+}
+
+// open positions the decoder at the first record.
+func (r *jsonRecordReader) open() error {
+	if err := r.descend(r.options.RecordRoot); err != nil {
+		return err
+	}
+	token, err := r.decoder.Token()
+	if err != nil {
+		return err
+	}
+	switch r.options.Envelope {
+	case JSONEnvelopeArray:
+		if token != json.Delim('[') {
+			return errors.New("json envelope array requires a top-level array")
+		}
+	case JSONEnvelopeWrapped:
+		if token != json.Delim('{') {
+			return errors.New("json envelope wrapped requires a top-level object")
+		}
+		r.objects++
+		_, found, err := r.seekKey(func(key string) bool { return key == r.options.RecordsKey })
+		if err != nil {
+			return err
+		}
+		if !found {
+			return errors.New("json envelope wrapped is missing records_key: " + r.options.RecordsKey)
+		}
+		token, err := r.decoder.Token()
+		if err != nil {
+			return err
+		}
+		if token != json.Delim('[') {
+			return errors.New("json records_key must hold an array: " + r.options.RecordsKey)
+		}
+	default:
+		switch token {
+		case json.Delim('['):
+		case json.Delim('{'):
+			record, err := r.readObject()
+			if err != nil {
+				return err
+			}
+			r.single = record
+			return nil
+		default:
+			return errors.New("json input must be an object or array of objects")
+		}
+	}
+	return nil
+}
+
+// descend enters the objects along path, matching each key against the
+// rest of the path in document order. A key holding the rest of the path
+// literally is preferred as in batch parsing; when the next key comes first
+// the literal key is only seen later, so close rejects it then.
+func (r *jsonRecordReader) descend(path string) error {
+	if path == "" {
+		return nil
+	}
+	parsed, err := core.ParsePath(path)
+	if err != nil {
+		return err
+	}
+	for depth := 0; depth < parsed.Depth(); {
+		token, err := r.decoder.Token()
+		if err != nil {
+			return err
+		}
+		if token != json.Delim('{') {
+			if r.objects == 0 {
+				return errors.New("json record_root requires a top-level object")
+			}
+			return errors.New("json record_root not found: " + path)
+		}
+		r.objects++
+		next := depth
+		_, found, err := r.seekKey(func(key string) bool {
+			matched, ok := parsed.Match(depth, key)
+			next = matched
+			return ok
+		})
+		if err != nil {
+			return err
+		}
+		if !found {
+			return errors.New("json record_root not found: " + path)
+		}
+		if literal := parsed.Literal(depth); next == depth+1 && literal != "" {
+			r.preferred[r.objects] = literal
+		}
+		depth = next
+	}
+	return nil
+}
+
+// seekKey reads the keys of the current object, skipping their values, until
+// one matches. It returns the matched key with the decoder positioned at its
+// value, or found false after consuming the end of the object.
+func (r *jsonRecordReader) seekKey(match func(key string) bool) (string, bool, error) {
+	for r.decoder.More() {
+		token, err := r.decoder.Token()
+		if err != nil {
+			return "", false, err
+		}
+		key := token.(string)
+		if match(key) {
+			return key, true, nil
+		}
+		var skipped json.RawMessage
+		if err := r.decoder.Decode(&skipped); err != nil {
+			return "", false, err
+		}
+	}
+	_, err := r.decoder.Token()
+	r.objects--
+	return "", false, err
+}
+
+// readObject reads the rest of an object whose opening brace was consumed.
+func (r *jsonRecordReader) readObject() (core.Record, error) {
+	record := core.Record{}
+	for r.decoder.More() {
+		token, err := r.decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		key := token.(string)
+		var raw json.RawMessage
+		if err := r.decoder.Decode(&raw); err != nil {
+			return nil, err
+		}
+		value, keys, err := decodeOrderedJSON(raw)
+		if err != nil {
+			return nil, err
+		}
+		path := core.ChildPath("", key, value)
+		r.order.add(path)
+		r.order.addValue(value, path, keys)
+		record[key] = value
+	}
+	if _, err := r.decoder.Token(); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+func (r *jsonRecordReader) decodeRecord(raw json.RawMessage) (core.Record, error) {
+	decoded, keys, err := decodeOrderedJSON(raw)
+	if err != nil {
+		return nil, err
+	}
+	r.order.addValue(decoded, "", keys)
+	return core.Record(decoded.(map[string]any)), nil
+}
+
+// close skips the rest of the enclosing objects and checks that nothing
+// follows the document.
+func (r *jsonRecordReader) close() error {
+	r.done = true
+	for r.objects > 0 {
+		literal, preferred := r.preferred[r.objects]
+		_, found, err := r.seekKey(func(key string) bool { return preferred && key == literal })
+		if err != nil {
+			return err
+		}
+		if found {
+			return errors.New("json record_root is ambiguous: both the nested path and the literal key exist: " + literal)
+		}
+	}
+	if _, err := r.decoder.Token(); err != io.EOF {
+		if err != nil {
+			return err
+		}
+		return errors.New("json input has data after the top-level value")
+	}
+	return nil
+}
+
+// jsonRecordWriter writes records inside the envelope as they arrive. With
+// envelope object_when_single it holds back the first record until it knows
+// whether a second one follows.
+type jsonRecordWriter struct {
+	output  io.Writer
+	shape   core.DataShape
+	options JSONOptions
+	count   int
+	pending []byte
+}
+
+func newJSONRecordWriter(output io.Writer, shape *core.DataShape, options Options) (RecordWriter, error) {
+	parsed, err := parseJSONEncodeOptions(options)
+	if err != nil {
+		return nil, err
+	}
+	writer := &jsonRecordWriter{output: output, options: parsed}
+	if shape != nil {
+		writer.shape = *shape
+	}
+	return writer, nil
+}
+
+func (w *jsonRecordWriter) Write(record core.Record) error {
+	encoded, err := marshalRecord(record, w.shape)
+	if err != nil {
+		return err
+	}
+	w.count++
+	switch {
+	case w.options.Envelope == JSONEnvelopeObjectWhenSingle && w.count == 1:
+		w.pending = encoded
+		return nil
+	case w.options.Envelope == JSONEnvelopeObjectWhenSingle && w.count == 2:
+		err = w.write([]byte("["), w.pending, []byte(","), encoded)
+		w.pending = nil
+		return err
+	case w.count == 1:
+		prefix, err := w.prefix()
+		if err != nil {
+			return err
+		}
+		return w.write(prefix, encoded)
+	default:
+		return w.write([]byte(","), encoded)
+	}
+}
+
+func (w *jsonRecordWriter) Close() error {
+	switch {
+	case w.options.Envelope == JSONEnvelopeObjectWhenSingle && w.count == 1:
+		return w.write(w.pending)
+	case w.count == 0:
+		prefix, err := w.prefix()
+		if err != nil {
+			return err
+		}
+		return w.write(prefix, w.suffix())
+	default:
+		return w.write(w.suffix())
+	}
+}
+
+func (w *jsonRecordWriter) prefix() ([]byte, error) {
+	if w.options.Envelope != JSONEnvelopeWrapped {
+		return []byte("["), nil
+	}
+	key, err := json.Marshal(w.options.RecordsKey)
+	if err != nil {
+		return nil, err
+	}
+	return append(append([]byte("{"), key...), ":["...), nil
+}
+
+func (w *jsonRecordWriter) suffix() []byte {
+	if w.options.Envelope == JSONEnvelopeWrapped {
+		return []byte("]}")
+	}
+	return []byte("]")
+}
+
+func (w *jsonRecordWriter) write(parts ...[]byte) error {
+	for _, part := range parts {
+		if _, err := w.output.Write(part); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+package formats
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"reshape/internal/core"
+)
+
+// ParseNDJSON converts newline-delimited JSON objects into canonical data.
+// Numbers are kept as json.Number.
+// Blank lines are ignored; every other line must hold exactly one object.
+func ParseNDJSON(input []byte) (core.CanonicalData, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(input))
+	scanner.Buffer(make([]byte, 0, 64*1024), len(input)+1)
+	records := []core.Record{}
+	order := newPathOrder()
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		record, err := decodeNDJSONLine(line, order)
+		if err != nil {
+			return core.CanonicalData{}, fmt.Errorf("ndjson line %d: %w", lineNumber, err)
+		}
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return core.CanonicalData{}, err
+	}
+
+	shape := core.BuildShapeFromRecords(records)
+	shape.SourceOrder = order.paths
+	return core.CanonicalData{
+		Shape:  shape,
+		Values: core.DataValues{Records: records},
+	}, nil
+}
+
+// decodeNDJSONLine decodes one non-blank line and records its key order.
+func decodeNDJSONLine(line []byte, order *pathOrder) (core.Record, error) {
+	decoded, keys, err := decodeOrderedJSON(line)
+	if err != nil {
+		return nil, err
+	}
+	mapValue, ok := decoded.(map[string]any)
+	if !ok {
+		return nil, errors.New("value is not an object")
+	}
+	order.addValue(mapValue, "", keys)
+	return core.Record(mapValue), nil
+}
+
+// RenderNDJSON converts canonical data into one compact JSON object per line.
+// Object keys follow the shape when it declares its order.
+func RenderNDJSON(data core.CanonicalData) ([]byte, error) {
+	buffer := &bytes.Buffer{}
+	for _, record := range data.Values.Records {
+		line, err := marshalRecord(record, data.Shape)
+		if err != nil {
+			return nil, err
+		}
+		buffer.Write(line)
+		buffer.WriteByte('\n')
+	}
+	return buffer.Bytes(), nil
+}
+
+// ndjsonRecordReader reads one line per record.
+type ndjsonRecordReader struct {
+	reader     *bufio.Reader
+	order      *pathOrder
+	lineNumber int
+}
+
+func newNDJSONRecordReader(input io.Reader, options Options) (RecordReader, error) {
+	if err := rejectOptions(options, "format does not accept decode options: ndjson"); err != nil {
+		return nil, err
+	}
+	return &ndjsonRecordReader{reader: bufio.NewReader(input), order: newPathOrder()}, nil
+}
+
+func (r *ndjsonRecordReader) Read() (core.Record, error) {
+	for {
+		line, readErr := r.reader.ReadBytes('\n')
+		if readErr != nil && readErr != io.EOF {
+			return nil, readErr
+		}
+		if len(line) == 0 && readErr == io.EOF {
+			return nil, io.EOF
+		}
+		r.lineNumber++
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		record, err := decodeNDJSONLine(line, r.order)
+		if err != nil {
+			return nil, fmt.Errorf("ndjson line %d: %w", r.lineNumber, err)
+		}
+		return record, nil
+	}
+}
+
+func (r *ndjsonRecordReader) SourceOrder() []string {
+	return r.order.paths
+}
+
+// ndjsonRecordWriter writes one line per record.
+type ndjsonRecordWriter struct {
+	output io.Writer
+	shape  core.DataShape
+}
+
+func newNDJSONRecordWriter(output io.Writer, shape *core.DataShape, options Options) (RecordWriter, error) {
+	if err := rejectOptions(options, "format does not accept encode options: ndjson"); err != nil {
+		return nil, err
+	}
+	writer := &ndjsonRecordWriter{output: output}
+	if shape != nil {
+		writer.shape = *shape
+	}
+	return writer, nil
+}
+
+func (w *ndjsonRecordWriter) Write(record core.Record) error {
+	line, err := marshalRecord(record, w.shape)
+	if err != nil {
+		return err
+	}
+	_, err = w.output.Write(append(line, '\n'))
+	return err
+}
+
+func (w *ndjsonRecordWriter) Close() error {
+	return nil
+}
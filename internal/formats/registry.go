@@ -0,0 +1,277 @@
+package formats
+
+import (
+	"errors"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"reshape/internal/core"
+)
+
+// Decoder converts input bytes into canonical data.
+type Decoder interface {
+	Decode(input []byte) (core.CanonicalData, error)
+}
+
+// Encoder converts canonical data into output bytes.
+type Encoder interface {
+	Encode(data core.CanonicalData) ([]byte, error)
+}
+
+// DecoderFunc adapts a function to the Decoder interface.
+type DecoderFunc func(input []byte) (core.CanonicalData, error)
+
+// Decode calls the wrapped function.
+func (f DecoderFunc) Decode(input []byte) (core.CanonicalData, error) {
+	return f(input)
+}
+
+// EncoderFunc adapts a function to the Encoder interface.
+type EncoderFunc func(data core.CanonicalData) ([]byte, error)
+
+// Encode calls the wrapped function.
+func (f EncoderFunc) Encode(data core.CanonicalData) ([]byte, error) {
+	return f(data)
+}
+
+// Options carries format-specific settings keyed by option name.
+type Options map[string]string
+
+// Format describes a named format and its decode and encode implementations.
+// Either Decoder or Encoder may be nil for input-only or output-only formats.
+// NewDecoder and NewEncoder are optional and build configured instances when
+// options are supplied. NewRecordReader and NewRecordWriter are optional and
+// stream records instead of whole documents.
+type Format struct {
+	Name            string
+	Aliases         []string
+	Extensions      []string
+	Decoder         Decoder
+	Encoder         Encoder
+	NewDecoder      func(options Options) (Decoder, error)
+	NewEncoder      func(options Options) (Encoder, error)
+	NewRecordReader func(input io.Reader, options Options) (RecordReader, error)
+	NewRecordWriter func(output io.Writer, shape *core.DataShape, options Options) (RecordWriter, error)
+}
+
+// Registry resolves formats by name, alias, or file extension.
+type Registry struct {
+	mu         sync.RWMutex
+	formats    map[string]Format
+	names      map[string]string
+	extensions map[string]string
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		formats:    map[string]Format{},
+		names:      map[string]string{},
+		extensions: map[string]string{},
+	}
+}
+
+// Register adds a format. Names, aliases, and extensions must be unique.
+func (r *Registry) Register(format Format) error {
+	name := normalizeFormatKey(format.Name)
+	if name == "" {
+		return errors.New("format name is empty")
+	}
+	if format.Decoder == nil && format.Encoder == nil {
+		return errors.New("format requires a decoder or encoder: " + name)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	keys := []string{name}
+	for _, alias := range format.Aliases {
+		keys = append(keys, normalizeFormatKey(alias))
+	}
+	for _, key := range keys {
+		if key == "" {
+			return errors.New("format alias is empty: " + name)
+		}
+		if _, exists := r.names[key]; exists {
+			return errors.New("format name already registered: " + key)
+		}
+	}
+	extensions := make([]string, 0, len(format.Extensions))
+	for _, extension := range format.Extensions {
+		key := normalizeExtension(extension)
+		if key == "" {
+			return errors.New("format extension is empty: " + name)
+		}
+		if _, exists := r.extensions[key]; exists {
+			return errors.New("format extension already registered: " + key)
+		}
+		extensions = append(extensions, key)
+	}
+
+	format.Name = name
+	r.formats[name] = format
+	for _, key := range keys {
+		r.names[key] = name
+	}
+	for _, key := range extensions {
+		r.extensions[key] = name
+	}
+	return nil
+}
+
+// Lookup returns the format registered under a name or alias.
+func (r *Registry) Lookup(name string) (Format, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	canonical, ok := r.names[normalizeFormatKey(name)]
+	if !ok {
+		return Format{}, false
+	}
+	return r.formats[canonical], true
+}
+
+// LookupExtension returns the format registered for a file extension.
+func (r *Registry) LookupExtension(extension string) (Format, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	canonical, ok := r.extensions[normalizeExtension(extension)]
+	if !ok {
+		return Format{}, false
+	}
+	return r.formats[canonical], true
+}
+
+// Decoder returns the decoder for a name or alias.
+func (r *Registry) Decoder(name string) (Decoder, error) {
+	format, ok := r.Lookup(name)
+	if !ok || format.Decoder == nil {
+		return nil, errors.New("no decoder registered for format: " + name)
+	}
+	return format.Decoder, nil
+}
+
+// Encoder returns the encoder for a name or alias.
+func (r *Registry) Encoder(name string) (Encoder, error) {
+	format, ok := r.Lookup(name)
+	if !ok || format.Encoder == nil {
+		return nil, errors.New("no encoder registered for format: " + name)
+	}
+	return format.Encoder, nil
+}
+
+// DecoderWithOptions returns a decoder for a name or alias configured with options.
+func (r *Registry) DecoderWithOptions(name string, options Options) (Decoder, error) {
+	if len(options) == 0 {
+		return r.Decoder(name)
+	}
+	format, ok := r.Lookup(name)
+	if !ok || format.Decoder == nil {
+		return nil, errors.New("no decoder registered for format: " + name)
+	}
+	if format.NewDecoder == nil {
+		return nil, errors.New("format does not accept decode options: " + format.Name)
+	}
+	return format.NewDecoder(options)
+}
+
+// EncoderWithOptions returns an encoder for a name or alias configured with options.
+func (r *Registry) EncoderWithOptions(name string, options Options) (Encoder, error) {
+	if len(options) == 0 {
+		return r.Encoder(name)
+	}
+	format, ok := r.Lookup(name)
+	if !ok || format.Encoder == nil {
+		return nil, errors.New("no encoder registered for format: " + name)
+	}
+	if format.NewEncoder == nil {
+		return nil, errors.New("format does not accept encode options: " + format.Name)
+	}
+	return format.NewEncoder(options)
+}
+
+// DecoderNames returns the sorted names of formats that can be decoded.
+func (r *Registry) DecoderNames() []string {
+	return r.sortedNames(func(format Format) bool { return format.Decoder != nil })
+}
+
+// EncoderNames returns the sorted names of formats that can be encoded.
+func (r *Registry) EncoderNames() []string {
+	return r.sortedNames(func(format Format) bool { return format.Encoder != nil })
+}
+
+func (r *Registry) sortedNames(include func(Format) bool) []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.formats))
+	for name, format := range r.formats {
+		if include(format) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func normalizeFormatKey(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+func normalizeExtension(extension string) string {
+	return strings.TrimPrefix(normalizeFormatKey(extension), ".")
+}
+
+var defaultRegistry = newBuiltinRegistry()
+
+// DefaultRegistry returns the process-wide registry holding the built-in formats.
+func DefaultRegistry() *Registry {
+	return defaultRegistry
+}
+
+// Register adds a format to the default registry.
+func Register(format Format) error {
+	return defaultRegistry.Register(format)
+}
+
+func newBuiltinRegistry() *Registry {
+	registry := NewRegistry()
+	builtins := []Format{
+		{
+			Name:       "csv",
+			Extensions: []string{"csv"},
+			Decoder:    DecoderFunc(ParseCSV),
+			Encoder:    EncoderFunc(RenderCSV),
+			NewEncoder: newCSVEncoder,
+
+			NewRecordReader: newCSVRecordReader,
+			NewRecordWriter: newCSVRecordWriter,
+		},
+		{
+			Name:       "json",
+			Extensions: []string{"json"},
+			Decoder:    DecoderFunc(ParseJSON),
+			Encoder:    EncoderFunc(RenderJSON),
+			NewDecoder: newJSONDecoder,
+			NewEncoder: newJSONEncoder,
+
+			NewRecordReader: newJSONRecordReader,
+			NewRecordWriter: newJSONRecordWriter,
+		},
+		{
+			Name:       "ndjson",
+			Aliases:    []string{"jsonl"},
+			Extensions: []string{"ndjson", "jsonl"},
+			Decoder:    DecoderFunc(ParseNDJSON),
+			Encoder:    EncoderFunc(RenderNDJSON),
+
+			NewRecordReader: newNDJSONRecordReader,
+			NewRecordWriter: newNDJSONRecordWriter,
+		},
+	}
+	for _, format := range builtins {
+		if err := registry.Register(format); err != nil {
+			panic(err)
+		}
+	}
+	return registry
+}
@@ -0,0 +1,50 @@
+package formats
+
+import (
+	"errors"
+	"io"
+
+	"reshape/internal/core"
+)
+
+// RecordReader decodes records one at a time.
+type RecordReader interface {
+	// Read returns the next record, or io.EOF after the last one.
+	Read() (core.Record, error)
+	// SourceOrder returns the record paths in the order they first appeared
+	// in the records read so far.
+	SourceOrder() []string
+}
+
+// RecordWriter encodes records one at a time.
+type RecordWriter interface {
+	Write(record core.Record) error
+	// Close completes the document and flushes buffered output.
+	Close() error
+}
+
+// RecordReader returns a streaming reader of input for a name or alias.
+func (r *Registry) RecordReader(name string, input io.Reader, options Options) (RecordReader, error) {
+	format, ok := r.Lookup(name)
+	if !ok || format.NewRecordReader == nil {
+		return nil, errors.New("no streaming decoder registered for format: " + name)
+	}
+	return format.NewRecordReader(input, options)
+}
+
+// RecordWriter returns a streaming writer to output for a name or alias.
+// shape is the shape of the records to write, or nil when it is unknown.
+func (r *Registry) RecordWriter(name string, output io.Writer, shape *core.DataShape, options Options) (RecordWriter, error) {
+	format, ok := r.Lookup(name)
+	if !ok || format.NewRecordWriter == nil {
+		return nil, errors.New("no streaming encoder registered for format: " + name)
+	}
+	return format.NewRecordWriter(output, shape, options)
+}
+
+func rejectOptions(options Options, message string) error {
+	if len(options) > 0 {
+		return errors.New(message)
+	}
+	return nil
+}
@@ -3,6 +3,7 @@ package formats_test
 import (
 	"strings"
 	"testing"
+	"time"
 
 	"reshape/internal/core"
 	"reshape/internal/formats"
@@ -78,3 +79,128 @@ func TestRenderCSVRejectsObjectValues(t *testing.T) {
 		t.Fatalf("unexpected error: %v", err)
 	}
 }
+
+func TestRenderCSVUsesDeclaredOrderAndLabels(t *testing.T) {
+	data := core.CanonicalData{
+		Shape: core.DataShape{
+			Order: core.ShapeOrderDeclared,
+			Fields: []core.FieldDefinition{
+				{Path: "user.name", Type: core.LogicalTypeString, Label: "Name"},
+				{Path: "id", Type: core.LogicalTypeNumber},
+			},
+		},
+		Values: core.DataValues{Records: []core.Record{
+			{"id": 1.0, "user.name": "Ada"},
+		}},
+	}
+
+	output, err := formats.RenderCSV(data)
+	if err != nil {
+		t.Fatalf("render csv: %v", err)
+	}
+	if string(output) != "Name,id\nAda,1\n" {
+		t.Fatalf("unexpected csv output: %s", string(output))
+	}
+}
+
+func TestCSVRoundTripKeepsHeaders(t *testing.T) {
+	input := "Amount [USD],id,\"say \"\"hi\"\"\",a..b,\" padded \",user.name\n1,2,3,4,5,6\n"
+	data, err := formats.ParseCSV([]byte(input))
+	if err != nil {
+		t.Fatalf("parse csv: %v", err)
+	}
+	output, err := formats.RenderCSV(data)
+	if err != nil {
+		t.Fatalf("render csv: %v", err)
+	}
+	expected := "\" padded \",Amount [USD],a..b,id,\"say \"\"hi\"\"\",user.name\n5,1,4,2,3,6\n"
+	if string(output) != expected {
+		t.Fatalf("unexpected csv output\nexpected: %q\nactual: %q", expected, string(output))
+	}
+
+	transformed, _, err := core.TransformData(data, core.ConversionPlan{FieldOrder: core.FieldOrderInput})
+	if err != nil {
+		t.Fatalf("transform: %v", err)
+	}
+	output, err = formats.RenderCSV(transformed)
+	if err != nil {
+		t.Fatalf("render csv: %v", err)
+	}
+	if string(output) != input {
+		t.Fatalf("expected input order round trip\nexpected: %q\nactual: %q", input, string(output))
+	}
+}
+
+func TestCSVEncoderRendersTemporalLayouts(t *testing.T) {
+	data := core.CanonicalData{Values: core.DataValues{Records: []core.Record{{
+		"day":  core.Date{Year: 2025, Month: time.March, Day: 4},
+		"at":   time.Date(2025, time.March, 4, 9, 30, 0, 0, time.UTC),
+		"span": core.Duration(90 * time.Minute),
+	}}}}
+
+	output, err := formats.RenderCSV(data)
+	if err != nil {
+		t.Fatalf("render csv: %v", err)
+	}
+	if string(output) != "at,day,span\n2025-03-04T09:30:00Z,2025-03-04,PT1H30M\n" {
+		t.Fatalf("unexpected csv output: %s", string(output))
+	}
+
+	encoder, err := formats.DefaultRegistry().EncoderWithOptions("csv", formats.Options{
+		"date_layout":     "01/02/2006",
+		"datetime_layout": "2006-01-02 15:04",
+	})
+	if err != nil {
+		t.Fatalf("encoder: %v", err)
+	}
+	output, err = encoder.Encode(data)
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	if string(output) != "at,day,span\n2025-03-04 09:30,03/04/2025,PT1H30M\n" {
+		t.Fatalf("unexpected csv output: %s", string(output))
+	}
+
+	if _, err := formats.ParseCSVOptions(formats.Options{"delimiter": ";"}); err == nil || !strings.Contains(err.Error(), "unknown csv option: delimiter") {
+		t.Fatalf("expected unknown option error, got %v", err)
+	}
+}
+
+func TestJSONRendersTemporalValuesAsISO(t *testing.T) {
+	data := core.CanonicalData{Values: core.DataValues{Records: []core.Record{{
+		"day":  core.Date{Year: 2025, Month: time.March, Day: 4},
+		"span": core.Duration(-2 * time.Hour),
+	}}}}
+
+	output, err := formats.RenderJSON(data)
+	if err != nil {
+		t.Fatalf("render json: %v", err)
+	}
+	if string(output) != `{"day":"2025-03-04","span":"-PT2H"}` {
+		t.Fatalf("unexpected json output: %s", string(output))
+	}
+}
+
+func BenchmarkRenderCSVDeepPaths(b *testing.B) {
+	data := core.CanonicalData{Values: core.DataValues{Records: make([]core.Record, 1000)}}
+	for column := 0; column < 8; column++ {
+		path := "column" + string(rune('a'+column)) + ".levelb.levelc.leveld.levele.levelf"
+		data.Shape.Fields = append(data.Shape.Fields, core.FieldDefinition{Path: path, Type: core.LogicalTypeString})
+	}
+	for index := range data.Values.Records {
+		record := core.Record{}
+		for _, field := range data.Shape.Fields {
+			if err := core.SetValueAtPath(record, field.Path, "value"); err != nil {
+				b.Fatal(err)
+			}
+		}
+		data.Values.Records[index] = record
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := formats.RenderCSV(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
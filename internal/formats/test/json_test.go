@@ -2,6 +2,7 @@ package formats_test
 
 import (
 	"encoding/json"
+	"reflect"
 	"strings"
 	"testing"
 
@@ -60,3 +61,258 @@ func TestRenderJSONShapes(t *testing.T) {
 		t.Fatalf("unexpected output: %v", decoded)
 	}
 }
+
+func TestRenderJSONWithEnvelopes(t *testing.T) {
+	data := core.CanonicalData{Values: core.DataValues{Records: []core.Record{
+		{"name": "Ada"},
+	}}}
+
+	cases := []struct {
+		options  formats.JSONOptions
+		expected string
+	}{
+		{formats.JSONOptions{Envelope: formats.JSONEnvelopeArray}, `[{"name":"Ada"}]`},
+		{formats.JSONOptions{Envelope: formats.JSONEnvelopeObjectWhenSingle}, `{"name":"Ada"}`},
+		{formats.JSONOptions{Envelope: formats.JSONEnvelopeWrapped, RecordsKey: "records"}, `{"records":[{"name":"Ada"}]}`},
+	}
+	for _, testCase := range cases {
+		output, err := formats.RenderJSONWithOptions(data, testCase.options)
+		if err != nil {
+			t.Fatalf("render %s: %v", testCase.options.Envelope, err)
+		}
+		if string(output) != testCase.expected {
+			t.Fatalf("unexpected %s output\nexpected: %s\nactual: %s", testCase.options.Envelope, testCase.expected, string(output))
+		}
+	}
+
+	empty, err := formats.RenderJSONWithOptions(core.CanonicalData{}, formats.JSONOptions{Envelope: formats.JSONEnvelopeWrapped, RecordsKey: "records"})
+	if err != nil {
+		t.Fatalf("render empty: %v", err)
+	}
+	if string(empty) != `{"records":[]}` {
+		t.Fatalf("unexpected empty output: %s", string(empty))
+	}
+}
+
+func TestParseJSONWithEnvelopes(t *testing.T) {
+	data, err := formats.ParseJSONWithOptions([]byte(`{"records":[{"name":"Ada"},{"name":"Linus"}],"count":2}`), formats.JSONOptions{Envelope: formats.JSONEnvelopeWrapped, RecordsKey: "records"})
+	if err != nil {
+		t.Fatalf("parse wrapped: %v", err)
+	}
+	if len(data.Values.Records) != 2 || data.Values.Records[1]["name"] != "Linus" {
+		t.Fatalf("unexpected records: %v", data.Values.Records)
+	}
+
+	_, err = formats.ParseJSONWithOptions([]byte(`{"name":"Ada"}`), formats.JSONOptions{Envelope: formats.JSONEnvelopeArray})
+	if err == nil || !strings.Contains(err.Error(), "top-level array") {
+		t.Fatalf("expected array envelope error, got %v", err)
+	}
+
+	_, err = formats.ParseJSONWithOptions([]byte(`{"data":[]}`), formats.JSONOptions{Envelope: formats.JSONEnvelopeWrapped, RecordsKey: "records"})
+	if err == nil || !strings.Contains(err.Error(), "missing records_key") {
+		t.Fatalf("expected missing key error, got %v", err)
+	}
+}
+
+func TestParseJSONOptionsValidation(t *testing.T) {
+	parsed, err := formats.ParseJSONOptions(formats.Options{"envelope": "wrapped", "records_key": "items"})
+	if err != nil {
+		t.Fatalf("parse options: %v", err)
+	}
+	if parsed.Envelope != formats.JSONEnvelopeWrapped || parsed.RecordsKey != "items" {
+		t.Fatalf("unexpected options: %#v", parsed)
+	}
+
+	invalid := []formats.Options{
+		{"envelope": "bare"},
+		{"envelope": "wrapped"},
+		{"envelope": "array", "records_key": "items"},
+		{"indent": "2"},
+	}
+	for _, options := range invalid {
+		if _, err := formats.ParseJSONOptions(options); err == nil {
+			t.Fatalf("expected error for options %v", options)
+		}
+	}
+}
+
+func TestParseJSONRecordRootWithCarriedFields(t *testing.T) {
+	input := []byte(`{"meta":{"request_id":"r-1","page":2},"data":{"items":[{"sku":"a"},{"sku":"b"}]}}`)
+	options, err := formats.ParseJSONOptions(formats.Options{
+		"envelope":     "array",
+		"record_root":  "data.items",
+		"carry_fields": "meta.request_id",
+	})
+	if err != nil {
+		t.Fatalf("parse options: %v", err)
+	}
+
+	data, err := formats.ParseJSONWithOptions(input, options)
+	if err != nil {
+		t.Fatalf("parse json: %v", err)
+	}
+
+	expected := []core.Record{
+		{"sku": "a", "meta": map[string]any{"request_id": "r-1"}},
+		{"sku": "b", "meta": map[string]any{"request_id": "r-1"}},
+	}
+	if !reflect.DeepEqual(data.Values.Records, expected) {
+		t.Fatalf("unexpected records\nexpected: %v\nactual: %v", expected, data.Values.Records)
+	}
+}
+
+func TestParseJSONCarriedFieldsAreCopiedPerRecord(t *testing.T) {
+	input := []byte(`{"meta":{"tags":["x"]},"items":[{"sku":"a"},{"sku":"b"}]}`)
+	data, err := formats.ParseJSONWithOptions(input, formats.JSONOptions{
+		Envelope:    formats.JSONEnvelopeArray,
+		RecordRoot:  "items",
+		CarryFields: []string{"meta"},
+	})
+	if err != nil {
+		t.Fatalf("parse json: %v", err)
+	}
+	first := data.Values.Records[0]["meta"].(map[string]any)
+	first["request_id"] = "changed"
+	first["tags"].([]any)[0] = "changed"
+
+	expected := map[string]any{"tags": []any{"x"}}
+	if !reflect.DeepEqual(data.Values.Records[1]["meta"], expected) {
+		t.Fatalf("expected the second record's carried field unchanged, got %v", data.Values.Records[1]["meta"])
+	}
+}
+
+func TestParseJSONRecordRootErrors(t *testing.T) {
+	_, err := formats.ParseJSONWithOptions([]byte(`{"data":{}}`), formats.JSONOptions{Envelope: formats.JSONEnvelopeArray, RecordRoot: "data.items"})
+	if err == nil || !strings.Contains(err.Error(), "record_root not found") {
+		t.Fatalf("expected missing root error, got %v", err)
+	}
+
+	_, err = formats.ParseJSONWithOptions([]byte(`{"id":1,"items":[{"id":2}]}`), formats.JSONOptions{
+		Envelope:    formats.JSONEnvelopeObjectWhenSingle,
+		RecordRoot:  "items",
+		CarryFields: []string{"id"},
+	})
+	if err == nil || !strings.Contains(err.Error(), "collides") {
+		t.Fatalf("expected carry collision error, got %v", err)
+	}
+
+	if _, err := formats.DefaultRegistry().EncoderWithOptions("json", formats.Options{"record_root": "data"}); err == nil {
+		t.Fatalf("expected record_root to be rejected for encoding")
+	}
+}
+
+func TestJSONRoundTripKeepsInputKeyOrder(t *testing.T) {
+	input := []byte(`{"meta":{"batch":7},"data":[{"zeta":1,"user":{"name":"Ada","id":2},"alpha":true}]}`)
+	data, err := formats.ParseJSONWithOptions(input, formats.JSONOptions{
+		Envelope:    formats.JSONEnvelopeWrapped,
+		RecordsKey:  "data",
+		CarryFields: []string{"meta"},
+	})
+	if err != nil {
+		t.Fatalf("parse json: %v", err)
+	}
+	expectedOrder := []string{"zeta", "user", "user.name", "user.id", "alpha", "meta", "meta.batch"}
+	if !reflect.DeepEqual(data.Shape.SourceOrder, expectedOrder) {
+		t.Fatalf("unexpected source order\nexpected: %v\nactual: %v", expectedOrder, data.Shape.SourceOrder)
+	}
+
+	output, _, err := core.TransformData(data, core.ConversionPlan{FieldOrder: core.FieldOrderInput})
+	if err != nil {
+		t.Fatalf("transform: %v", err)
+	}
+	rendered, err := formats.RenderJSONWithOptions(output, formats.JSONOptions{Envelope: formats.JSONEnvelopeWrapped, RecordsKey: "data"})
+	if err != nil {
+		t.Fatalf("render json: %v", err)
+	}
+	expected := `{"data":[{"zeta":1,"user":{"name":"Ada","id":2},"alpha":true,"meta":{"batch":7}}]}`
+	if string(rendered) != expected {
+		t.Fatalf("unexpected json\nexpected: %s\nactual: %s", expected, string(rendered))
+	}
+}
+
+func TestJSONSourceOrderQuotesKeysHoldingFields(t *testing.T) {
+	input := []byte(`[{"example.com":{"owner":"a","id":1},"user.name":"b"}]`)
+	data, err := formats.ParseJSONWithOptions(input, formats.JSONOptions{Envelope: formats.JSONEnvelopeArray})
+	if err != nil {
+		t.Fatalf("parse json: %v", err)
+	}
+	expectedOrder := []string{`["example.com"]`, `["example.com"].owner`, `["example.com"].id`, "user.name"}
+	if !reflect.DeepEqual(data.Shape.SourceOrder, expectedOrder) {
+		t.Fatalf("unexpected source order\nexpected: %v\nactual: %v", expectedOrder, data.Shape.SourceOrder)
+	}
+
+	output, _, err := core.TransformData(data, core.ConversionPlan{FieldOrder: core.FieldOrderInput})
+	if err != nil {
+		t.Fatalf("transform: %v", err)
+	}
+	rendered, err := formats.RenderJSONWithOptions(output, formats.JSONOptions{Envelope: formats.JSONEnvelopeArray})
+	if err != nil {
+		t.Fatalf("render json: %v", err)
+	}
+	if string(rendered) != string(input) {
+		t.Fatalf("unexpected json\nexpected: %s\nactual: %s", input, rendered)
+	}
+}
+
+func TestJSONSourceOrderNamesArrayItems(t *testing.T) {
+	input := []byte(`{"data":[{"id":1,"items":[{"sku":"a","price":2}]}]}`)
+	data, err := formats.ParseJSONWithOptions(input, formats.JSONOptions{Envelope: formats.JSONEnvelopeWrapped, RecordsKey: "data"})
+	if err != nil {
+		t.Fatalf("parse json: %v", err)
+	}
+	expectedOrder := []string{"id", "items", "items[*].sku", "items[*].price"}
+	if !reflect.DeepEqual(data.Shape.SourceOrder, expectedOrder) {
+		t.Fatalf("unexpected source order\nexpected: %v\nactual: %v", expectedOrder, data.Shape.SourceOrder)
+	}
+
+	output, _, err := core.TransformData(data, core.ConversionPlan{FieldOrder: core.FieldOrderInput})
+	if err != nil {
+		t.Fatalf("transform: %v", err)
+	}
+	rendered, err := formats.RenderJSONWithOptions(output, formats.JSONOptions{Envelope: formats.JSONEnvelopeArray})
+	if err != nil {
+		t.Fatalf("render json: %v", err)
+	}
+	if expected := `[{"id":1,"items":[{"sku":"a","price":2}]}]`; string(rendered) != expected {
+		t.Fatalf("unexpected json\nexpected: %s\nactual: %s", expected, rendered)
+	}
+
+	if _, err := formats.ParseJSONOptions(formats.Options{"record_root": "pages[0]"}); err == nil || err.Error() != "json record_root must not have an index or wildcard: pages[0]" {
+		t.Fatalf("expected record_root index error, got %v", err)
+	}
+}
+
+func TestJSONSourceOrderFollowsResolvedRecordRoot(t *testing.T) {
+	input := []byte(`{"data.page":[{"b":1,"a":2}],"data":{"page":[{"z":1}]}}`)
+	data, err := formats.ParseJSONWithOptions(input, formats.JSONOptions{Envelope: formats.JSONEnvelopeArray, RecordRoot: "data.page"})
+	if err != nil {
+		t.Fatalf("parse json: %v", err)
+	}
+	expectedOrder := []string{"b", "a"}
+	if !reflect.DeepEqual(data.Shape.SourceOrder, expectedOrder) {
+		t.Fatalf("unexpected source order\nexpected: %v\nactual: %v", expectedOrder, data.Shape.SourceOrder)
+	}
+}
+
+func TestJSONRoundTripKeepsExactNumbers(t *testing.T) {
+	input := []byte(`[{"id":9007199254740993,"price":0.10,"ratio":1e-7}]`)
+	data, err := formats.ParseJSONWithOptions(input, formats.JSONOptions{Envelope: formats.JSONEnvelopeArray})
+	if err != nil {
+		t.Fatalf("parse json: %v", err)
+	}
+	rendered, err := formats.RenderJSONWithOptions(data, formats.JSONOptions{Envelope: formats.JSONEnvelopeArray})
+	if err != nil {
+		t.Fatalf("render json: %v", err)
+	}
+	if string(rendered) != `[{"id":9007199254740993,"price":0.10,"ratio":1e-7}]` {
+		t.Fatalf("unexpected json: %s", string(rendered))
+	}
+
+	csvOutput, err := formats.RenderCSV(data)
+	if err != nil {
+		t.Fatalf("render csv: %v", err)
+	}
+	if string(csvOutput) != "id,price,ratio\n9007199254740993,0.10,1e-7\n" {
+		t.Fatalf("unexpected csv: %s", string(csvOutput))
+	}
+}
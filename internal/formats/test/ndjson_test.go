@@ -0,0 +1,76 @@
+package formats_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"reshape/internal/core"
+	"reshape/internal/formats"
+)
+
+func TestParseNDJSONRecords(t *testing.T) {
+	input := []byte("{\"name\":\"Ada\",\"age\":30}\n\n{\"name\":\"Linus\"}\n")
+
+	data, err := formats.ParseNDJSON(input)
+	if err != nil {
+		t.Fatalf("parse ndjson: %v", err)
+	}
+	if len(data.Values.Records) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(data.Values.Records))
+	}
+	if data.Values.Records[0]["age"] != json.Number("30") {
+		t.Fatalf("expected age 30, got %v", data.Values.Records[0]["age"])
+	}
+	if data.Values.Records[1]["name"] != "Linus" {
+		t.Fatalf("expected name Linus, got %v", data.Values.Records[1]["name"])
+	}
+}
+
+func TestParseNDJSONReportsLineNumber(t *testing.T) {
+	_, err := formats.ParseNDJSON([]byte("{\"a\":1}\n{\"a\":\n"))
+	if err == nil {
+		t.Fatalf("expected error for malformed line")
+	}
+	if !strings.Contains(err.Error(), "line 2") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	_, err = formats.ParseNDJSON([]byte("{\"a\":1}\n[1]\n"))
+	if err == nil || !strings.Contains(err.Error(), "line 2") || !strings.Contains(err.Error(), "not an object") {
+		t.Fatalf("unexpected error for non-object line: %v", err)
+	}
+}
+
+func TestRenderNDJSONStableShape(t *testing.T) {
+	one := core.CanonicalData{Values: core.DataValues{Records: []core.Record{
+		{"name": "Ada", "age": 30.0},
+	}}}
+	output, err := formats.RenderNDJSON(one)
+	if err != nil {
+		t.Fatalf("render ndjson: %v", err)
+	}
+	if string(output) != "{\"age\":30,\"name\":\"Ada\"}\n" {
+		t.Fatalf("unexpected output: %q", string(output))
+	}
+
+	two := core.CanonicalData{Values: core.DataValues{Records: []core.Record{
+		{"name": "Ada"},
+		{"name": "Linus"},
+	}}}
+	output, err = formats.RenderNDJSON(two)
+	if err != nil {
+		t.Fatalf("render ndjson: %v", err)
+	}
+	if string(output) != "{\"name\":\"Ada\"}\n{\"name\":\"Linus\"}\n" {
+		t.Fatalf("unexpected output: %q", string(output))
+	}
+
+	empty, err := formats.RenderNDJSON(core.CanonicalData{})
+	if err != nil {
+		t.Fatalf("render empty: %v", err)
+	}
+	if len(empty) != 0 {
+		t.Fatalf("expected empty output, got %q", string(empty))
+	}
+}
@@ -0,0 +1,95 @@
+package formats_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"reshape/internal/core"
+	"reshape/internal/formats"
+)
+
+func TestDefaultRegistryBuiltins(t *testing.T) {
+	registry := formats.DefaultRegistry()
+
+	if names := registry.DecoderNames(); !reflect.DeepEqual(names, []string{"csv", "json", "ndjson"}) {
+		t.Fatalf("unexpected decoder names: %v", names)
+	}
+	if names := registry.EncoderNames(); !reflect.DeepEqual(names, []string{"csv", "json", "ndjson"}) {
+		t.Fatalf("unexpected encoder names: %v", names)
+	}
+
+	format, ok := registry.LookupExtension(".CSV")
+	if !ok || format.Name != "csv" {
+		t.Fatalf("expected csv for .CSV extension, got %#v", format)
+	}
+
+	decoder, err := registry.Decoder("json")
+	if err != nil {
+		t.Fatalf("decoder: %v", err)
+	}
+	data, err := decoder.Decode([]byte(`{"name":"Ada"}`))
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	encoder, err := registry.Encoder("csv")
+	if err != nil {
+		t.Fatalf("encoder: %v", err)
+	}
+	output, err := encoder.Encode(data)
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	if string(output) != "name\nAda\n" {
+		t.Fatalf("unexpected output: %q", string(output))
+	}
+}
+
+func TestRegistryAliasesAndDirections(t *testing.T) {
+	registry := formats.NewRegistry()
+	err := registry.Register(formats.Format{
+		Name:       "tsv",
+		Aliases:    []string{"tab"},
+		Extensions: []string{".tsv"},
+		Decoder: formats.DecoderFunc(func(input []byte) (core.CanonicalData, error) {
+			return core.CanonicalData{}, nil
+		}),
+	})
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+
+	format, ok := registry.Lookup("TAB")
+	if !ok || format.Name != "tsv" {
+		t.Fatalf("expected alias lookup to resolve tsv, got %#v", format)
+	}
+	if _, err := registry.Decoder("tab"); err != nil {
+		t.Fatalf("decoder by alias: %v", err)
+	}
+	if _, err := registry.Encoder("tsv"); err == nil {
+		t.Fatalf("expected error for missing encoder")
+	}
+	if names := registry.EncoderNames(); len(names) != 0 {
+		t.Fatalf("expected no encoder names, got %v", names)
+	}
+}
+
+func TestRegistryRejectsDuplicates(t *testing.T) {
+	registry := formats.NewRegistry()
+	encoder := formats.EncoderFunc(func(data core.CanonicalData) ([]byte, error) { return nil, nil })
+	if err := registry.Register(formats.Format{Name: "a", Extensions: []string{"txt"}, Encoder: encoder}); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+
+	err := registry.Register(formats.Format{Name: "b", Aliases: []string{"a"}, Encoder: encoder})
+	if err == nil || !strings.Contains(err.Error(), "already registered") {
+		t.Fatalf("expected duplicate name error, got %v", err)
+	}
+	err = registry.Register(formats.Format{Name: "c", Extensions: []string{".txt"}, Encoder: encoder})
+	if err == nil || !strings.Contains(err.Error(), "already registered") {
+		t.Fatalf("expected duplicate extension error, got %v", err)
+	}
+	if _, ok := registry.Lookup("b"); ok {
+		t.Fatalf("rejected format must not be partially registered")
+	}
+}
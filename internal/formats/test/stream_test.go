@@ -0,0 +1,181 @@
+package formats_test
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"reshape/internal/core"
+	"reshape/internal/formats"
+)
+
+func readAllRecords(t *testing.T, reader formats.RecordReader) []core.Record {
+	t.Helper()
+	records := []core.Record{}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return records
+		}
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		records = append(records, record)
+	}
+}
+
+func TestRecordReadersMatchDecoders(t *testing.T) {
+	registry := formats.DefaultRegistry()
+	cases := []struct {
+		format  string
+		options formats.Options
+		input   string
+	}{
+		{format: "csv", input: "b,a\n1,\n2,x\n"},
+		{format: "ndjson", input: "{\"b\":1,\"a\":{\"z\":1,\"y\":2}}\n\n{\"c\":2.50}"},
+		{format: "json", input: `[{"b":1,"a":{"z":1}},{"c":[1,{"d":2}]}]`},
+		{format: "json", input: ` {"b":1,"a":{"z":1}} `},
+		{format: "json", input: `{"example.com":{"owner":"a"},"items":[{"sku":"x","tags":[{"k":1}]}]}`},
+		{format: "json", input: `[]`},
+		{
+			format:  "json",
+			options: formats.Options{"record_root": "data.page", "envelope": "wrapped", "records_key": "items"},
+			input:   `{"skip":[{"x":1}],"data":{"before":1,"page":{"total":2,"items":[{"b":1},{"a":2}],"after":{"k":[]}}},"tail":"z"}`,
+		},
+		{
+			format:  "json",
+			options: formats.Options{"record_root": `api["v1.0"]`},
+			input:   `{"api":{"v1":{"0":[{"x":1}]},"v1.0":[{"example.com":{"owner":"a"},"user.name":"b"}]}}`,
+		},
+		{
+			format:  "json",
+			options: formats.Options{"record_root": "data.page"},
+			input:   `{"data.page":[{"a":1}],"data":{"page":[{"b":2}]}}`,
+		},
+	}
+	for _, tc := range cases {
+		decoder, err := registry.DecoderWithOptions(tc.format, tc.options)
+		if err != nil {
+			t.Fatalf("decoder: %v", err)
+		}
+		expected, err := decoder.Decode([]byte(tc.input))
+		if err != nil {
+			t.Fatalf("decode %s: %v", tc.input, err)
+		}
+		reader, err := registry.RecordReader(tc.format, strings.NewReader(tc.input), tc.options)
+		if err != nil {
+			t.Fatalf("record reader: %v", err)
+		}
+		records := readAllRecords(t, reader)
+		if !reflect.DeepEqual(records, expected.Values.Records) {
+			t.Fatalf("records differ for %s\nstream: %v\nbatch:  %v", tc.input, records, expected.Values.Records)
+		}
+		if !reflect.DeepEqual(reader.SourceOrder(), expected.Shape.SourceOrder) {
+			t.Fatalf("source order differs for %s\nstream: %v\nbatch:  %v", tc.input, reader.SourceOrder(), expected.Shape.SourceOrder)
+		}
+	}
+}
+
+func TestJSONRecordReaderErrors(t *testing.T) {
+	registry := formats.DefaultRegistry()
+	cases := []struct {
+		options  formats.Options
+		input    string
+		expected string
+	}{
+		{input: `["a"]`, expected: "json array contains non-object value"},
+		{input: `"a"`, expected: "json input must be an object or array of objects"},
+		{input: `[{"a":1}] [{"a":2}]`, expected: "json input has data after the top-level value"},
+		{options: formats.Options{"record_root": "data"}, input: `{"other":[]}`, expected: "json record_root not found: data"},
+		{options: formats.Options{"record_root": "data.page"}, input: `{"data":{"page":[{"b":2}]},"data.page":[{"a":1}]}`, expected: "json record_root is ambiguous: both the nested path and the literal key exist: data.page"},
+		{options: formats.Options{"envelope": "wrapped", "records_key": "items"}, input: `{"other":[]}`, expected: "json envelope wrapped is missing records_key: items"},
+		{options: formats.Options{"envelope": "array"}, input: `{"a":1}`, expected: "json envelope array requires a top-level array"},
+	}
+	for _, tc := range cases {
+		reader, err := registry.RecordReader("json", strings.NewReader(tc.input), tc.options)
+		if err != nil {
+			t.Fatalf("record reader: %v", err)
+		}
+		for err == nil {
+			_, err = reader.Read()
+		}
+		if err == io.EOF || err.Error() != tc.expected {
+			t.Fatalf("expected %q for %s, got %v", tc.expected, tc.input, err)
+		}
+	}
+	if _, err := registry.RecordReader("json", strings.NewReader(`{}`), formats.Options{"carry_fields": "meta"}); err == nil {
+		t.Fatalf("expected carry_fields to be rejected when streaming")
+	}
+}
+
+func TestRecordWritersMatchEncoders(t *testing.T) {
+	registry := formats.DefaultRegistry()
+	shape := core.DataShape{
+		Fields: []core.FieldDefinition{{Path: "b", Label: "B"}, {Path: "a"}},
+		Order:  core.ShapeOrderDeclared,
+	}
+	recordSets := [][]core.Record{
+		{},
+		{{"a": "1", "b": "x"}},
+		{{"a": "1", "b": "x"}, {"a": nil, "b": "y,z"}},
+	}
+	cases := []struct {
+		format  string
+		options formats.Options
+	}{
+		{format: "csv"},
+		{format: "ndjson"},
+		{format: "json"},
+		{format: "json", options: formats.Options{"envelope": "array"}},
+		{format: "json", options: formats.Options{"envelope": "wrapped", "records_key": "rows"}},
+	}
+	for _, tc := range cases {
+		for _, records := range recordSets {
+			encoder, err := registry.EncoderWithOptions(tc.format, tc.options)
+			if err != nil {
+				t.Fatalf("encoder: %v", err)
+			}
+			expected, err := encoder.Encode(core.CanonicalData{Shape: shape, Values: core.DataValues{Records: records}})
+			if err != nil {
+				t.Fatalf("encode: %v", err)
+			}
+			buffer := &bytes.Buffer{}
+			writer, err := registry.RecordWriter(tc.format, buffer, &shape, tc.options)
+			if err != nil {
+				t.Fatalf("record writer: %v", err)
+			}
+			for _, record := range records {
+				if err := writer.Write(record); err != nil {
+					t.Fatalf("write: %v", err)
+				}
+			}
+			if err := writer.Close(); err != nil {
+				t.Fatalf("close: %v", err)
+			}
+			if buffer.String() != string(expected) {
+				t.Fatalf("%s %v with %d records\nstream: %q\nbatch:  %q", tc.format, tc.options, len(records), buffer.String(), expected)
+			}
+		}
+	}
+}
+
+func TestCSVRecordWriterNeedsShapeForEveryField(t *testing.T) {
+	registry := formats.DefaultRegistry()
+	if _, err := registry.RecordWriter("csv", io.Discard, nil, nil); err == nil || !strings.Contains(err.Error(), "requires streaming shape") {
+		t.Fatalf("expected missing shape error, got %v", err)
+	}
+	shape := core.DataShape{Fields: []core.FieldDefinition{{Path: "a"}, {Path: "user.id"}}}
+	writer, err := registry.RecordWriter("csv", io.Discard, &shape, nil)
+	if err != nil {
+		t.Fatalf("record writer: %v", err)
+	}
+	if err := writer.Write(core.Record{"a": "1", "user": map[string]any{"id": "2"}}); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	err = writer.Write(core.Record{"a": "1", "user": map[string]any{"id": "2", "name": "Ada"}})
+	if err == nil || err.Error() != "csv record has field not in shape: user.name" {
+		t.Fatalf("expected unknown field error, got %v", err)
+	}
+}
@@ -0,0 +1,70 @@
+package report_test
+
+import (
+	"strings"
+	"testing"
+
+	"reshape/internal/core"
+	"reshape/internal/report"
+)
+
+func TestRenderWarningsFormats(t *testing.T) {
+	warnings := []core.Warning{
+		{
+			Code:          core.WarningCodeJoinArray,
+			Message:       core.WarningMessage(core.WarningCodeJoinArray),
+			Path:          "tags",
+			AffectedCount: 1,
+			RecordIndices: []int{0},
+			Samples:       []core.WarningSample{{RecordIndex: 0, Before: []any{"a", "b"}, After: "a,b"}},
+		},
+		{
+			Code:          core.WarningCodeDropField,
+			Message:       core.WarningMessage(core.WarningCodeDropField),
+			Path:          "secret",
+			AffectedCount: 0,
+		},
+	}
+
+	cases := []struct {
+		format   report.WarningsFormat
+		expected string
+	}{
+		{report.WarningsFormatText, strings.Join([]string{
+			"warning: joined array into string (path: tags, records: 1)",
+			"warning: dropped field (path: secret, records: 0)",
+			"",
+		}, "\n")},
+		{report.WarningsFormatJSON, `[{"code":"join_array","message":"joined array into string","path":"tags","affected_count":1,"record_indices":[0],"samples":[{"record_index":0,"before":["a","b"],"after":"a,b"}]},{"code":"drop_field","message":"dropped field","path":"secret","affected_count":0}]` + "\n"},
+		{report.WarningsFormatNDJSON, strings.Join([]string{
+			`{"code":"join_array","message":"joined array into string","path":"tags","affected_count":1,"record_indices":[0],"samples":[{"record_index":0,"before":["a","b"],"after":"a,b"}]}`,
+			`{"code":"drop_field","message":"dropped field","path":"secret","affected_count":0}`,
+			"",
+		}, "\n")},
+	}
+	for _, testCase := range cases {
+		output, err := report.RenderWarnings(warnings, testCase.format)
+		if err != nil {
+			t.Fatalf("render %s: %v", testCase.format, err)
+		}
+		if string(output) != testCase.expected {
+			t.Fatalf("unexpected %s output\nexpected: %q\nactual: %q", testCase.format, testCase.expected, string(output))
+		}
+	}
+}
+
+func TestRenderWarningsEmptyJSONIsArray(t *testing.T) {
+	output, err := report.RenderWarnings(nil, report.WarningsFormatJSON)
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	if string(output) != "[]\n" {
+		t.Fatalf("unexpected output: %q", string(output))
+	}
+}
+
+func TestParseWarningsFormatRejectsUnknown(t *testing.T) {
+	if _, err := report.ParseWarningsFormat("xml"); err == nil {
+		t.Fatalf("expected error for unknown format")
+	}
+}
@@ -0,0 +1,71 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"reshape/internal/core"
+)
+
+// WarningsFormat selects how warnings are serialized.
+type WarningsFormat string
+
+const (
+	WarningsFormatText   WarningsFormat = "text"
+	WarningsFormatJSON   WarningsFormat = "json"
+	WarningsFormatNDJSON WarningsFormat = "ndjson"
+)
+
+// ParseWarningsFormat validates a warnings format name.
+func ParseWarningsFormat(value string) (WarningsFormat, error) {
+	switch format := WarningsFormat(value); format {
+	case WarningsFormatText, WarningsFormatJSON, WarningsFormatNDJSON:
+		return format, nil
+	default:
+		return "", errors.New("unsupported warnings format: " + value)
+	}
+}
+
+// RenderWarnings serializes warnings in the requested format.
+// JSON renders an array (empty when there are no warnings), NDJSON renders one
+// object per line, and text renders one human-readable line per warning.
+func RenderWarnings(warnings []core.Warning, format WarningsFormat) ([]byte, error) {
+	buffer := &bytes.Buffer{}
+	switch format {
+	case WarningsFormatJSON:
+		if warnings == nil {
+			warnings = []core.Warning{}
+		}
+		encoded, err := json.Marshal(warnings)
+		if err != nil {
+			return nil, err
+		}
+		buffer.Write(encoded)
+		buffer.WriteByte('\n')
+	case WarningsFormatNDJSON:
+		for _, warning := range warnings {
+			encoded, err := json.Marshal(warning)
+			if err != nil {
+				return nil, err
+			}
+			buffer.Write(encoded)
+			buffer.WriteByte('\n')
+		}
+	case WarningsFormatText:
+		for _, warning := range warnings {
+			message := fmt.Sprintf("warning: %s", warning.Message)
+			if warning.Path != "" && warning.Rule != "" {
+				message = fmt.Sprintf("%s (path: %s, rule: %s, records: %d)", message, warning.Path, warning.Rule, warning.AffectedCount)
+			} else if warning.Path != "" {
+				message = fmt.Sprintf("%s (path: %s, records: %d)", message, warning.Path, warning.AffectedCount)
+			}
+			buffer.WriteString(message)
+			buffer.WriteByte('\n')
+		}
+	default:
+		return nil, errors.New("unsupported warnings format: " + string(format))
+	}
+	return buffer.Bytes(), nil
+}